@@ -1,7 +1,9 @@
 package main
 
 import (
+	"flag"
 	"os"
+	"time"
 
 	admin "github.com/CS4500-F18/dare-rebr/Santorini/Admin/Referee"
 	// iplayer "github.com/CS4500-F18/dare-rebr/Santorini/Common/Player"
@@ -11,7 +13,19 @@ import (
 )
 
 func main() {
-	observer := obs.NewJSONObserver("Fido", os.Stdout)
+	tui := flag.Bool("tui", false, "draw the game in the terminal instead of printing JSON")
+	step := flag.Bool("step", false, "with -tui, wait for enter after every update")
+	delay := flag.Duration("delay", 500*time.Millisecond, "with -tui, time between updates when not stepping")
+	flag.Parse()
+
+	var observer obs.IObserver = obs.NewJSONObserver("Fido", os.Stdout)
+	if *tui {
+		terminal := obs.NewTerminalObserver("Fido", os.Stdout, os.Stdin)
+		terminal.SetColor(true)
+		terminal.SetStepping(*step)
+		terminal.SetDelay(*delay)
+		observer = terminal
+	}
 
 	name1 := "Uno"
 	name2 := "Dos"
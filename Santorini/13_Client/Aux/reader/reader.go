@@ -5,8 +5,10 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"sync"
 
 	static "github.com/CS4500-F18/dare-rebr/Santorini/Admin/Tournament/Config"
+	remote "github.com/CS4500-F18/dare-rebr/Santorini/Remote/Relay"
 )
 
 func RunTest(r io.Reader) {
@@ -15,31 +17,54 @@ func RunTest(r io.Reader) {
 	var config static.StaticConfig
 	decoder.Decode(&config)
 
-	relays, _ := config.ClientRelays()
+	clients, observers, err := config.Clients()
+	if err != nil {
+		panic(fmt.Sprintf("Failed to set up players: %v", err))
+	}
 
-	done := make(chan bool)
-	started := 0
-	for _, relay := range relays {
-		err := relay.Connect(config.IP, config.Port, done)
+	// Observers never finish a tournament on their own, so nobody waits for
+	// them
+	watching := make(chan bool, len(observers))
+	for _, observer := range observers {
+		err := observer.Connect(config.IP, config.Port, watching)
 		if err != nil {
 			panic(fmt.Sprintf("Failed to connect to %s:%v", config.IP, config.Port))
-		} else {
-			started++
 		}
 	}
 
-	if started == 0 {
-		return
+	// Register every player, in order, before any of them plays
+	connections := make([]*remote.Connection, 0)
+	if config.Multiplex {
+		mux, err := config.Multiplexer(clients)
+		if err != nil {
+			panic(fmt.Sprintf("Failed to set up TLS: %v", err))
+		}
+		multiplexed, err := mux.Connect(config.IP, config.Port)
+		if err != nil {
+			panic(fmt.Sprintf("Failed to connect to %s:%v: %v", config.IP, config.Port, err))
+		}
+		defer multiplexed.Close()
+		connections = multiplexed.Connections()
+	} else {
+		for _, client := range clients {
+			conn, err := client.Connect(config.IP, config.Port)
+			if err != nil {
+				panic(fmt.Sprintf("Failed to connect to %s:%v: %v", config.IP, config.Port, err))
+			}
+			connections = append(connections, conn)
+		}
 	}
 
-	for {
-		select {
-		case <-done:
-			os.Exit(0) // This is a hack
-			started--
-			if started <= 0 {
-				return
+	// Play until every player has the results, or has lost the server
+	var playing sync.WaitGroup
+	for _, conn := range connections {
+		playing.Add(1)
+		go func(conn *remote.Connection) {
+			defer playing.Done()
+			if _, err := conn.Serve(); err != nil {
+				fmt.Fprintf(os.Stderr, "%s stopped playing: %v\n", conn.Name(), err)
 			}
-		}
+		}(conn)
 	}
+	playing.Wait()
 }
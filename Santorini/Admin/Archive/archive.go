@@ -0,0 +1,269 @@
+package archive
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	result "github.com/CS4500-F18/dare-rebr/Santorini/Common/Tournament"
+)
+
+/*
+  An Archive keeps every tournament, series and game played, with each game's
+  placements and moves, in a single file. Each record is appended to the file
+  as a line of JSON as soon as it happens, and the file is read back into
+  memory when the archive is opened, so no database is needed and a crash
+  loses at most the line being written
+*/
+
+//Kinds of record in the archive's file
+const (
+	TOURNAMENT = "tournament"
+	SERIES     = "series"
+	GAME       = "game"
+)
+
+//A tournament, written when it begins and again when it finishes
+type Tournament struct {
+	ID       int       `json:"id"`
+	Started  time.Time `json:"started"`
+	Finished time.Time `json:"finished,omitempty"`
+
+	//Everyone who played, and those kicked out
+	Players []string `json:"players"`
+	Kicked  []string `json:"kicked"`
+
+	//The final results, after cheaters lost their wins, once it has finished
+	Results []Result `json:"results"`
+	Aborted bool     `json:"aborted"`
+}
+
+//One of a tournament's final results
+type Result struct {
+	Winner    string `json:"winner"`
+	Loser     string `json:"loser"`
+	Irregular bool   `json:"irregular"`
+}
+
+//A series between two players, as it was played
+type Series struct {
+	ID         int       `json:"id"`
+	Tournament int       `json:"tournament"`
+	Players    [2]string `json:"players"`
+	Winner     string    `json:"winner"`
+	Loser      string    `json:"loser"`
+	Irregular  bool      `json:"irregular"`
+	Finished   time.Time `json:"finished"`
+}
+
+//A game of a series, with every placement and move in the order played
+type Game struct {
+	ID         int       `json:"id"`
+	Series     int       `json:"series"`
+	Tournament int       `json:"tournament"`
+	Players    [2]string `json:"players"`
+	Winner     string    `json:"winner"`
+	Loser      string    `json:"loser"`
+	Reason     string    `json:"reason"`
+	BrokenRule bool      `json:"broken-rule"`
+
+	//Each worker placed, as ["name1", x, y]
+	Placements []json.RawMessage `json:"placements"`
+
+	//Each turn, as ["name1", move EW, move NS, build EW, build NS], ending with
+	//a winning move as ["name1", move EW, move NS] if there was one
+	Moves []json.RawMessage `json:"moves"`
+}
+
+//A line of the archive's file
+type record struct {
+	Kind       string      `json:"kind"`
+	Tournament *Tournament `json:"tournament,omitempty"`
+	Series     *Series     `json:"series,omitempty"`
+	Game       *Game       `json:"game,omitempty"`
+}
+
+type Archive struct {
+	lock sync.Mutex
+	file *os.File
+
+	tournaments []*Tournament
+	series      []Series
+	games       []Game
+
+	//Where each tournament is in tournaments, by ID
+	tournamentIdx map[int]int
+
+	//Which games each player played, as indexes into games
+	gamesBy map[string][]int
+}
+
+//Open the archive in the file at the given path, creating it if need be. A
+//line left half-written by a crash is cut off
+func Open(path string) (*Archive, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	a := &Archive{
+		file:          file,
+		tournaments:   make([]*Tournament, 0),
+		series:        make([]Series, 0),
+		games:         make([]Game, 0),
+		tournamentIdx: make(map[int]int),
+		gamesBy:       make(map[string][]int),
+	}
+
+	buf, err := ioutil.ReadAll(file)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(buf))
+	good := int64(0)
+	for {
+		var r record
+		if err := decoder.Decode(&r); err != nil {
+			break
+		}
+		a.add(r)
+		good = decoder.InputOffset()
+	}
+	if len(bytes.TrimSpace(buf[good:])) > 0 {
+		if err := file.Truncate(good); err != nil {
+			file.Close()
+			return nil, err
+		}
+	}
+	if _, err := file.Seek(0, io.SeekEnd); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return a, nil
+}
+
+//Close the archive's file
+func (a *Archive) Close() error {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	return a.file.Close()
+}
+
+//Begin archiving a tournament between the given players, returning its ID
+func (a *Archive) BeginTournament(players []string) (int, error) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	t := Tournament{
+		ID:      len(a.tournaments) + 1,
+		Started: time.Now(),
+		Players: append(make([]string, 0), players...),
+		Kicked:  make([]string, 0),
+		Results: make([]Result, 0),
+	}
+	return t.ID, a.write(record{Kind: TOURNAMENT, Tournament: &t})
+}
+
+//Archive a series played in a tournament, and its games, giving each its ID
+//and its place in the tournament
+func (a *Archive) RecordSeries(series Series, games []Game) error {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	series.ID = len(a.series) + 1
+	if series.Finished.IsZero() {
+		series.Finished = time.Now()
+	}
+	if err := a.write(record{Kind: SERIES, Series: &series}); err != nil {
+		return err
+	}
+	for _, game := range games {
+		game.ID = len(a.games) + 1
+		game.Series, game.Tournament, game.Players = series.ID, series.Tournament, series.Players
+		if err := a.write(record{Kind: GAME, Game: &game}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//Archive how the tournament with the given ID finished
+func (a *Archive) FinishTournament(id int, final result.TournamentResult) error {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	idx, ok := a.tournamentIdx[id]
+	if !ok {
+		return os.ErrNotExist
+	}
+	t := *a.tournaments[idx]
+	t.Finished = time.Now()
+	t.Kicked = append(make([]string, 0), final.Kicked...)
+	t.Aborted = final.Aborted
+	t.Results = make([]Result, 0)
+	for _, match := range final.Games {
+		t.Results = append(t.Results, Result{match.Winner, match.Loser, match.RuleBroken})
+	}
+	// On a ladder, players join after it begins
+	t.Players = append(make([]string, 0), t.Players...)
+	for _, match := range final.Games {
+		for _, name := range []string{match.Winner, match.Loser} {
+			if !contains(t.Players, name) {
+				t.Players = append(t.Players, name)
+			}
+		}
+	}
+	return a.write(record{Kind: TOURNAMENT, Tournament: &t})
+}
+
+//Append the record to the file, durably, then take it in. Holds the lock
+func (a *Archive) write(r record) error {
+	buf, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	if _, err := a.file.Write(append(buf, '\n')); err != nil {
+		return err
+	}
+	if err := a.file.Sync(); err != nil {
+		return err
+	}
+	a.add(r)
+	return nil
+}
+
+//Take a record into memory. A tournament written again replaces the first
+func (a *Archive) add(r record) {
+	switch {
+	case r.Kind == TOURNAMENT && r.Tournament != nil:
+		if idx, ok := a.tournamentIdx[r.Tournament.ID]; ok {
+			a.tournaments[idx] = r.Tournament
+		} else {
+			a.tournamentIdx[r.Tournament.ID] = len(a.tournaments)
+			a.tournaments = append(a.tournaments, r.Tournament)
+		}
+
+	case r.Kind == SERIES && r.Series != nil:
+		a.series = append(a.series, *r.Series)
+
+	case r.Kind == GAME && r.Game != nil:
+		for _, name := range r.Game.Players {
+			a.gamesBy[name] = append(a.gamesBy[name], len(a.games))
+		}
+		a.games = append(a.games, *r.Game)
+	}
+}
+
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,122 @@
+package archive
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	board "github.com/CS4500-F18/dare-rebr/Santorini/Common/Board"
+	output "github.com/CS4500-F18/dare-rebr/Santorini/Common/JSON"
+	rules "github.com/CS4500-F18/dare-rebr/Santorini/Common/Rules"
+	result "github.com/CS4500-F18/dare-rebr/Santorini/Common/Tournament"
+)
+
+func tempArchive(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "archive")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return filepath.Join(dir, "archive.jsonl"), func() { os.RemoveAll(dir) }
+}
+
+func won(winner, loser string) rules.GameResult {
+	return rules.GameResult{Winner: winner, Loser: loser, Reason: rules.WINNING_MOVE_MSG}
+}
+
+// Everything archived is there after reopening, but a half-written line
+func TestArchive_Reopen(t *testing.T) {
+	path, cleanup := tempArchive(t)
+	defer cleanup()
+
+	a, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := a.BeginTournament([]string{"fido", "rex", "spot"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	series := []result.MatchResult{
+		result.NewMatchResult("fido", "rex", false, []rules.GameResult{won("fido", "rex"), won("rex", "fido"), won("fido", "rex")}),
+		result.NewMatchResult("spot", "fido", false, []rules.GameResult{won("spot", "fido"), won("spot", "fido")}),
+		result.NewMatchResult("rex", "spot", true, []rules.GameResult{{Winner: "rex", Loser: "spot", Reason: rules.RULE_BROKEN_MSG, BrokenRule: true}}),
+	}
+	for _, match := range series {
+		players := [2]string{match.Winner, match.Loser}
+		s, games := NewRecorder().Series(id, players[0], players[1], match)
+		if err := a.RecordSeries(s, games); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := a.FinishTournament(id, result.TournamentResult{Games: series, Kicked: []string{"spot"}}); err != nil {
+		t.Fatal(err)
+	}
+	a.Close()
+
+	file, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	file.Write([]byte(`{"kind":"game","game":{"id":`))
+	file.Close()
+
+	a, err = Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+
+	if tournament, ok := a.Tournament(id); !ok || tournament.Finished.IsZero() || len(tournament.Results) != 3 || len(tournament.Kicked) != 1 {
+		t.Errorf("Wrong tournament %+v", tournament)
+	}
+	if games := a.GamesBy("fido"); len(games) != 5 || games[0].Series != 2 {
+		t.Errorf("Wrong games for fido, newest first: %+v", games)
+	}
+	if record := a.HeadToHead("fido", "rex"); record.Series != 1 || record.SeriesWins != 1 || record.GameWins != 2 || record.GameLosses != 1 {
+		t.Errorf("Wrong head to head %+v", record)
+	}
+	if recent := a.Recent(2); len(recent) != 2 || recent[0].Winner != "rex" || recent[1].Winner != "spot" {
+		t.Errorf("Wrong recent series %+v", recent)
+	}
+	if spot, ok := a.Player("spot"); !ok || spot.Tournaments != 1 || spot.SeriesWins != 1 || spot.Irregular != 1 {
+		t.Errorf("Wrong player %+v", spot)
+	}
+
+	// The cut-off line is gone, so more can be written after it
+	if _, err := a.BeginTournament([]string{"fido"}); err != nil {
+		t.Fatal(err)
+	}
+	a.Close()
+	if a, err = Open(path); err != nil || len(a.RecentTournaments(0)) != 2 {
+		t.Errorf("Archive unreadable after recovering: %v", err)
+	}
+}
+
+// The recorder keeps every placement and move of each game
+func TestRecorder(t *testing.T) {
+	r := NewRecorder()
+	b := board.IBoard(board.BaseBoard())
+	r.ReceiveBoard(b)
+	for _, place := range []struct {
+		pos   board.Pos
+		owner string
+	}{{board.Pos{X: 0, Y: 0}, "fido"}, {board.Pos{X: 5, Y: 5}, "rex"}, {board.Pos{X: 2, Y: 2}, "fido"}, {board.Pos{X: 5, Y: 0}, "rex"}} {
+		b, _ = b.PlaceWorker(place.pos, place.owner)
+		r.ReceiveBoard(b)
+	}
+	east := output.Direction{EastWest: output.EAST, NorthSouth: output.PUT}
+	r.ReceiveTurn(output.MoveBuildJSON{WorkerName: "fido1", MoveDir: east, BuildDir: east})
+	r.ReceiveBoard(b)
+	r.ReceiveWinningMove(output.MoveJSON{WorkerName: "rex2", MoveDir: east})
+	r.ReceiveEndgame(won("rex", "fido"))
+
+	games := r.Games([]rules.GameResult{won("rex", "fido")})
+	if len(games) != 1 || games[0].Winner != "rex" {
+		t.Fatalf("Wrong games %+v", games)
+	}
+	game := games[0]
+	if len(game.Placements) != 4 || string(game.Placements[1]) != `["rex1",5,5]` {
+		t.Errorf("Wrong placements %s", game.Placements)
+	}
+	if len(game.Moves) != 2 || string(game.Moves[0]) != `["fido1","EAST","PUT","EAST","PUT"]` || string(game.Moves[1]) != `["rex2","EAST","PUT"]` {
+		t.Errorf("Wrong moves %s", game.Moves)
+	}
+}
@@ -0,0 +1,181 @@
+package archive
+
+import (
+	"sort"
+	"time"
+)
+
+//Everything archived about a player, across every tournament
+type Player struct {
+	Name        string    `json:"name"`
+	FirstSeen   time.Time `json:"first-seen"`
+	LastSeen    time.Time `json:"last-seen"`
+	Tournaments int       `json:"tournaments"`
+
+	SeriesWins   int `json:"series-wins"`
+	SeriesLosses int `json:"series-losses"`
+	GameWins     int `json:"game-wins"`
+	GameLosses   int `json:"game-losses"`
+
+	//Series lost by breaking a rule
+	Irregular int `json:"irregular"`
+}
+
+//Two players' record against each other, from the first player's view
+type HeadToHead struct {
+	Players [2]string `json:"players"`
+
+	Series       int `json:"series"`
+	SeriesWins   int `json:"series-wins"`
+	SeriesLosses int `json:"series-losses"`
+
+	Games      int `json:"games"`
+	GameWins   int `json:"game-wins"`
+	GameLosses int `json:"game-losses"`
+}
+
+//The tournament with the given ID
+func (a *Archive) Tournament(id int) (Tournament, bool) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	if idx, ok := a.tournamentIdx[id]; ok {
+		return *a.tournaments[idx], true
+	}
+	return Tournament{}, false
+}
+
+//The most recent tournaments, at most n of them (0 for all), newest first
+func (a *Archive) RecentTournaments(n int) []Tournament {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	recent := make([]Tournament, 0)
+	for i := len(a.tournaments) - 1; i >= 0 && (n <= 0 || len(recent) < n); i-- {
+		recent = append(recent, *a.tournaments[i])
+	}
+	return recent
+}
+
+//The most recent series played, at most n of them (0 for all), newest first
+func (a *Archive) Recent(n int) []Series {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	recent := make([]Series, 0)
+	for i := len(a.series) - 1; i >= 0 && (n <= 0 || len(recent) < n); i-- {
+		recent = append(recent, a.series[i])
+	}
+	return recent
+}
+
+//Every game of the series with the given ID, in the order played
+func (a *Archive) SeriesGames(id int) []Game {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	games := make([]Game, 0)
+	for _, game := range a.games {
+		if game.Series == id {
+			games = append(games, game)
+		}
+	}
+	return games
+}
+
+//Every game the named player played, newest first
+func (a *Archive) GamesBy(name string) []Game {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	games := make([]Game, 0)
+	idxs := a.gamesBy[name]
+	for i := len(idxs) - 1; i >= 0; i-- {
+		games = append(games, a.games[idxs[i]])
+	}
+	return games
+}
+
+//How the first player has done against the second
+func (a *Archive) HeadToHead(first, second string) HeadToHead {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	record := HeadToHead{Players: [2]string{first, second}}
+	for _, s := range a.series {
+		if s.Winner == first && s.Loser == second {
+			record.Series++
+			record.SeriesWins++
+		} else if s.Winner == second && s.Loser == first {
+			record.Series++
+			record.SeriesLosses++
+		}
+	}
+	for _, idx := range a.gamesBy[first] {
+		game := a.games[idx]
+		if game.Winner == first && game.Loser == second {
+			record.Games++
+			record.GameWins++
+		} else if game.Winner == second && game.Loser == first {
+			record.Games++
+			record.GameLosses++
+		}
+	}
+	return record
+}
+
+//Everything archived about the named player, if they ever played
+func (a *Archive) Player(name string) (Player, bool) {
+	for _, p := range a.Players() {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return Player{}, false
+}
+
+//Every player who ever played, by name
+func (a *Archive) Players() []Player {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	players := make(map[string]*Player)
+	get := func(name string, seen time.Time) *Player {
+		p, ok := players[name]
+		if !ok {
+			p = &Player{Name: name, FirstSeen: seen, LastSeen: seen}
+			players[name] = p
+		}
+		if seen.Before(p.FirstSeen) {
+			p.FirstSeen = seen
+		}
+		if seen.After(p.LastSeen) {
+			p.LastSeen = seen
+		}
+		return p
+	}
+
+	for _, t := range a.tournaments {
+		for _, name := range t.Players {
+			get(name, t.Started).Tournaments++
+		}
+	}
+	for _, s := range a.series {
+		get(s.Winner, s.Finished).SeriesWins++
+		loser := get(s.Loser, s.Finished)
+		loser.SeriesLosses++
+		if s.Irregular {
+			loser.Irregular++
+		}
+	}
+	for _, game := range a.games {
+		if p, ok := players[game.Winner]; ok {
+			p.GameWins++
+		}
+		if p, ok := players[game.Loser]; ok {
+			p.GameLosses++
+		}
+	}
+
+	list := make([]Player, 0)
+	for _, p := range players {
+		list = append(list, *p)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
@@ -0,0 +1,112 @@
+package archive
+
+import (
+	"encoding/json"
+	"sync"
+
+	board "github.com/CS4500-F18/dare-rebr/Santorini/Common/Board"
+	output "github.com/CS4500-F18/dare-rebr/Santorini/Common/JSON"
+	rules "github.com/CS4500-F18/dare-rebr/Santorini/Common/Rules"
+	result "github.com/CS4500-F18/dare-rebr/Santorini/Common/Tournament"
+)
+
+//Watches a series, keeping each game's placements and moves to archive
+type Recorder struct {
+	lock  sync.Mutex
+	games []Game
+
+	//Where the workers placed so far in the game under way are
+	placed map[string]board.Pos
+}
+
+func NewRecorder() *Recorder {
+	return &Recorder{games: []Game{newGame()}, placed: make(map[string]board.Pos)}
+}
+
+func newGame() Game {
+	return Game{Placements: make([]json.RawMessage, 0), Moves: make([]json.RawMessage, 0)}
+}
+
+//The games of the series as played, with how each ended
+func (r *Recorder) Games(results []rules.GameResult) []Game {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	games := make([]Game, 0)
+	for i, end := range results {
+		game := newGame()
+		if i < len(r.games) {
+			game = r.games[i]
+		}
+		game.Winner, game.Loser, game.Reason, game.BrokenRule = end.Winner, end.Loser, end.Reason, end.BrokenRule
+		games = append(games, game)
+	}
+	return games
+}
+
+//The series, and its games, as the archive keeps them
+func (r *Recorder) Series(tournament int, a, b string, match result.MatchResult) (Series, []Game) {
+	series := Series{
+		Tournament: tournament,
+		Players:    [2]string{a, b},
+		Winner:     match.Winner,
+		Loser:      match.Loser,
+		Irregular:  match.RuleBroken,
+	}
+	return series, r.Games(match.GameResults)
+}
+
+func (r *Recorder) Name() string {
+	return "archive"
+}
+
+//Note each worker placed, until the first turn
+func (r *Recorder) ReceiveBoard(b board.IBoard) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	game := &r.games[len(r.games)-1]
+	if len(game.Moves) > 0 || b == nil {
+		return
+	}
+	for _, w := range b.Workers() {
+		if _, ok := r.placed[w.Name()]; !ok {
+			r.placed[w.Name()] = w.Pos()
+			buf, _ := json.Marshal([]interface{}{w.Name(), w.Pos().X, w.Pos().Y})
+			game.Placements = append(game.Placements, buf)
+		}
+	}
+}
+
+func (r *Recorder) ReceiveWinningMove(t output.MoveJSON) {
+	r.move(output.MoveTurn{WorkerName: t.WorkerName, MoveEW: t.MoveDir.EastWest, MoveNS: t.MoveDir.NorthSouth})
+}
+
+func (r *Recorder) ReceiveTurn(t output.MoveBuildJSON) {
+	r.move(output.MoveBuildTurn{
+		WorkerName: t.WorkerName,
+		MoveEW:     t.MoveDir.EastWest,
+		MoveNS:     t.MoveDir.NorthSouth,
+		BuildEW:    t.BuildDir.EastWest,
+		BuildNS:    t.BuildDir.NorthSouth,
+	})
+}
+
+func (r *Recorder) move(turn interface{}) {
+	buf, err := json.Marshal(turn)
+	if err != nil {
+		return
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	game := &r.games[len(r.games)-1]
+	game.Moves = append(game.Moves, buf)
+}
+
+//Start on the next game
+func (r *Recorder) ReceiveEndgame(end rules.GameResult) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.games = append(r.games, newGame())
+	r.placed = make(map[string]board.Pos)
+}
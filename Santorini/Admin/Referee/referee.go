@@ -1,13 +1,36 @@
 package referee
 
 import (
+	"time"
+
 	sandbox "github.com/CS4500-F18/dare-rebr/Santorini/Admin/Sandbox"
 	board "github.com/CS4500-F18/dare-rebr/Santorini/Common/Board"
 	output "github.com/CS4500-F18/dare-rebr/Santorini/Common/JSON"
 	rules "github.com/CS4500-F18/dare-rebr/Santorini/Common/Rules"
+	lib "github.com/CS4500-F18/dare-rebr/Santorini/Lib"
 	obs "github.com/CS4500-F18/dare-rebr/Santorini/Observer"
 )
 
+// Metrics gathered from every game refereed
+var (
+	gamesStarted   = lib.METRICS.Counter("santorini_games_started_total", "Games started.")
+	gamesCompleted = lib.METRICS.Counter("santorini_games_completed_total", "Games finished, by how they ended.", "ending")
+	ruleBreaks     = lib.METRICS.Counter("santorini_rule_breaks_total", "Games lost by breaking a rule, by what the player did wrong.", "reason")
+	moveLatency    = lib.METRICS.Histogram("santorini_move_latency_seconds", "How long each player takes to choose a turn.",
+		lib.LATENCY_BUCKETS, "player")
+)
+
+// The ways a player can break a rule, as counted in ruleBreaks
+const (
+	BREAK_NO_OPPONENT   = "no-opponent"
+	BREAK_NO_PLACEMENT  = "no-placement"
+	BREAK_BAD_PLACEMENT = "bad-placement"
+	BREAK_NO_TURN       = "no-turn"
+	BREAK_BAD_WORKER    = "bad-worker"
+	BREAK_BAD_MOVE      = "bad-move"
+	BREAK_BAD_BUILD     = "bad-build"
+)
+
 // The referee carries a game to its completion given that the strategies are
 // instantiated previously, and that each strategy has a player name associated
 // with it
@@ -64,6 +87,7 @@ func (r *referee) BestOf(games int) []rules.GameResult {
 		otherPlayer := opponent(turnPlayer)
 		err := player.SetOpponent(r.names[otherPlayer])
 		if err != nil {
+			ruleBreaks.Inc(BREAK_NO_OPPONENT)
 			return []rules.GameResult{{
 				Winner:     r.names[otherPlayer],
 				Loser:      r.names[turnPlayer],
@@ -73,10 +97,24 @@ func (r *referee) BestOf(games int) []rules.GameResult {
 		}
 	}
 
+	for turnPlayer, player := range r.players {
+		// Lifecycle notifications are informational, a player that fails to
+		// receive one will fail on its next request instead
+		player.StartSeries(r.names[opponent(turnPlayer)], games)
+	}
+
 	for i := 0; i < games; i++ {
+		for turnPlayer, player := range r.players {
+			player.StartGame(i, turnPlayer)
+		}
+
 		board := board.BaseBoard()
 		result := r.playSingleGame(board)
 
+		for _, player := range r.players {
+			player.EndGame(result)
+		}
+
 		results = append(results, result)
 		wins[result.Winner]++
 		if result.BrokenRule {
@@ -94,6 +132,7 @@ func (r *referee) BestOf(games int) []rules.GameResult {
 // The Referee will end the game and declare a winner if any call to a Strategy
 // object takes longer than a given timeout time
 func (r *referee) playSingleGame(b board.IBoard) rules.GameResult {
+	gamesStarted.Inc()
 	r.NotifyAll(b)
 
 	// Phase 1 (Placing Workers):
@@ -101,6 +140,7 @@ func (r *referee) playSingleGame(b board.IBoard) rules.GameResult {
 	if endGame.Winner != "" {
 		r.NotifyAll(b)
 		r.NotifyAll(endGame)
+		gamesCompleted.Inc(ending(endGame))
 		return endGame
 	}
 
@@ -108,9 +148,20 @@ func (r *referee) playSingleGame(b board.IBoard) rules.GameResult {
 	b, result := r.handleGameTurns(b)
 	r.NotifyAll(b)
 	r.NotifyAll(result)
+	gamesCompleted.Inc(ending(result))
 	return result
 }
 
+// How a game ended, as counted in gamesCompleted
+func ending(result rules.GameResult) string {
+	if result.BrokenRule {
+		return "rule-broken"
+	} else if result.Reason == rules.CANNOT_MOVE_MSG {
+		return "cannot-move"
+	}
+	return "winning-move"
+}
+
 // Runs the loop to receive worker placements from each player
 func (r *referee) startWorkerPlacement(b board.IBoard) (board.IBoard, rules.GameResult) {
 	for wIdx := 0; wIdx < board.WorkerCount; wIdx++ {
@@ -126,13 +177,16 @@ func (r *referee) startWorkerPlacement(b board.IBoard) (board.IBoard, rules.Game
 
 			workerLocation, err := r.players[turnPlayer].PlaceWorker(b)
 			if err != nil {
+				ruleBreaks.Inc(BREAK_NO_PLACEMENT)
 				return nil, breakResult
 			}
 
 			if !rules.CheckPlaceWorker(b, workerLocation) {
+				ruleBreaks.Inc(BREAK_BAD_PLACEMENT)
 				return nil, breakResult
 			} else {
 				if b, err = b.PlaceWorker(workerLocation, r.names[turnPlayer]); err != nil {
+					ruleBreaks.Inc(BREAK_BAD_PLACEMENT)
 					return nil, breakResult
 				} else {
 					r.NotifyAll(b)
@@ -150,6 +204,9 @@ func (r *referee) handleGameTurns(b board.IBoard) (board.IBoard, rules.GameResul
 	turnPlayer := 0
 	otherPlayer := (turnPlayer + 1) % 2
 
+	//what the player whose turn it is did wrong, once they break a rule
+	var broken string
+
 	//each iteration of this for loop represents a "turn" and this loop runs until the game has been won,
 	//at which point a GameResult is returned.
 	for {
@@ -160,19 +217,25 @@ func (r *referee) handleGameTurns(b board.IBoard) (board.IBoard, rules.GameResul
 
 		//returns a full turn, (build, move) for a the turnPlayer. If the time limit is exceeded,
 		//the turn is skipped
+		asked := time.Now()
 		turn, err := r.players[turnPlayer].NextTurn(b)
+		moveLatency.Observe(time.Since(asked).Seconds(), r.names[turnPlayer])
 		if err != nil {
+			broken = BREAK_NO_TURN
 			break
 		}
 
 		worker, err := b.FindWorker(r.names[turnPlayer], turn.WID)
 		if err != nil {
+			broken = BREAK_BAD_WORKER
 			break
 		}
 		moveDir := output.DirectionFrom2Pos(worker.Pos(), turn.MoveTo)
+		before := b
 
 		b, worker, err = turn.Move(r.names[turnPlayer], b)
 		if err != nil {
+			broken = BREAK_BAD_MOVE
 			break
 		}
 
@@ -180,24 +243,30 @@ func (r *referee) handleGameTurns(b board.IBoard) (board.IBoard, rules.GameResul
 		if won := rules.CheckWinPostMove(b, r.names[turnPlayer]); won {
 			message := output.MoveJSON{worker.Name(), moveDir}
 			r.NotifyAll(message)
+
+			turn.BuildAt = board.Pos{X: -1, Y: -1}
+			r.players[otherPlayer].OpponentTurn(before, turn)
 			return b, r.result(turnPlayer, otherPlayer, rules.WINNING_MOVE_MSG, false)
 		}
 
 		buildDir := output.DirectionFrom2Pos(worker.Pos(), turn.BuildAt)
 		b, worker, err = turn.Build(r.names[turnPlayer], b)
 		if err != nil {
+			broken = BREAK_BAD_BUILD
 			break
 		}
 
 		obsTurn := output.MoveBuildJSON{worker.Name(), moveDir, buildDir}
 		r.NotifyAll(obsTurn)
 		r.NotifyAll(b)
+		r.players[otherPlayer].OpponentTurn(before, turn)
 
 		//switch the active player (player whose turn it is) and the waiting (non-turn) player
 		turnPlayer = (turnPlayer + 1) % 2
 		otherPlayer = (turnPlayer + 1) % 2
 	}
 
+	ruleBreaks.Inc(broken)
 	return b, r.result(otherPlayer, turnPlayer, rules.RULE_BROKEN_MSG, true)
 }
 
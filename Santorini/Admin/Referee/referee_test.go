@@ -208,3 +208,44 @@ func TestReferee_BestOf_AllValidPlayers(t *testing.T) {
 		}
 	}
 }
+
+// A valid player that records the lifecycle notifications it receives
+type lifecyclePlayer struct {
+	iplayer.IPlayer
+	events *[]string
+}
+
+func (p lifecyclePlayer) StartSeries(opponent string, games int) {
+	*p.events = append(*p.events, "series "+opponent)
+	p.IPlayer.StartSeries(opponent, games)
+}
+func (p lifecyclePlayer) StartGame(game int, order int) {
+	*p.events = append(*p.events, "game")
+}
+func (p lifecyclePlayer) OpponentTurn(b board.IBoard, turn iplayer.Turn) {
+	*p.events = append(*p.events, "turn")
+}
+func (p lifecyclePlayer) EndGame(result rules.GameResult) {
+	*p.events = append(*p.events, "end "+result.Winner)
+}
+
+// Both players in a series should hear about the series, each game,
+// and how each game ended, in that order
+func TestReferee_BestOf_LifecycleNotifications(t *testing.T) {
+	events1, events2 := make([]string, 0), make([]string, 0)
+	p1 := lifecyclePlayer{client.ValidPlayer(PLAYER_1), &events1}
+	p2 := lifecyclePlayer{client.BrokenPlayer(PLAYER_2), &events2}
+	ref := newRef(PLAYER_1, p1, PLAYER_2, p2)
+
+	ref.BestOf(3)
+
+	expected1 := []string{"series " + PLAYER_2, "game", "end " + PLAYER_1}
+	expected2 := []string{"series " + PLAYER_1, "game", "turn", "end " + PLAYER_1}
+
+	if strings.Join(events1, ",") != strings.Join(expected1, ",") {
+		t.Errorf("Player 1 received %v, expected %v", events1, expected1)
+	}
+	if strings.Join(events2, ",") != strings.Join(expected2, ",") {
+		t.Errorf("Player 2 received %v, expected %v", events2, expected2)
+	}
+}
@@ -3,6 +3,7 @@ package sandbox
 import (
 	board "github.com/CS4500-F18/dare-rebr/Santorini/Common/Board"
 	iplayer "github.com/CS4500-F18/dare-rebr/Santorini/Common/Player"
+	rules "github.com/CS4500-F18/dare-rebr/Santorini/Common/Rules"
 	result "github.com/CS4500-F18/dare-rebr/Santorini/Common/Tournament"
 )
 
@@ -40,7 +41,33 @@ func (t NormalPlayer) SetOpponent(name string) error {
 	t.player.SetOpponent(name)
 	return nil
 }
-//receivs tournament results
-func (t NormalPlayer) ReceiveTournamentResults(results []result.MatchResult) error {
+
+//receives tournament results
+func (t NormalPlayer) ReceiveTournamentResult(result result.TournamentResult) error {
+	t.player.ReceiveTournamentResults(result.Games)
+	return nil
+}
+
+//informs the player of a new series
+func (t NormalPlayer) StartSeries(opponent string, games int) error {
+	t.player.StartSeries(opponent, games)
+	return nil
+}
+
+//informs the player of a new game
+func (t NormalPlayer) StartGame(game int, order int) error {
+	t.player.StartGame(game, order)
+	return nil
+}
+
+//informs the player of their opponent's turn
+func (t NormalPlayer) OpponentTurn(b board.IBoard, turn iplayer.Turn) error {
+	t.player.OpponentTurn(b, turn)
+	return nil
+}
+
+//informs the player of the end of a game
+func (t NormalPlayer) EndGame(result rules.GameResult) error {
+	t.player.EndGame(result)
 	return nil
 }
@@ -2,28 +2,48 @@ package sandbox
 
 import (
 	"fmt"
+	"sync"
 	"time"
 
 	board "github.com/CS4500-F18/dare-rebr/Santorini/Common/Board"
 	iplayer "github.com/CS4500-F18/dare-rebr/Santorini/Common/Player"
+	rules "github.com/CS4500-F18/dare-rebr/Santorini/Common/Rules"
 	result "github.com/CS4500-F18/dare-rebr/Santorini/Common/Tournament"
+	lib "github.com/CS4500-F18/dare-rebr/Santorini/Lib"
 )
 
 var (
 	TIMEOUT_ERROR = func(method string) error {
 		return fmt.Errorf("Player timed out on call to %s", method)
 	}
+
+	// Calls to players, local or remote, that timed out
+	TIMEOUTS = lib.METRICS.Counter("santorini_timeouts_total", "Calls to players that timed out, by the call.", "call")
 )
 
 // TimeoutPlayer wraps player calls in a timeout
 type TimeoutPlayer struct {
 	timeout int
 	player  iplayer.IPlayer
+
+	// Shared by every copy, so notifications reach the player one at a time
+	notifications *notifications
+}
+
+// The notifications being delivered to a player, one at a time, skipping
+// them while the player is still handling one that timed out
+type notifications struct {
+	// Held while the player handles a notification
+	delivering sync.Mutex
+
+	// Whether a notification timed out and is still being handled
+	lock    sync.Mutex
+	stalled bool
 }
 
 // Timeout, in TIMEOUT_UNITs, alongside the player being wrapped
 func NewTimeoutPlayer(timeout int, p iplayer.IPlayer) TimeoutPlayer {
-	return TimeoutPlayer{timeout: timeout, player: p}
+	return TimeoutPlayer{timeout: timeout, player: p, notifications: &notifications{}}
 }
 
 //Get the name of this Player
@@ -39,14 +59,16 @@ func (t TimeoutPlayer) Name() (string, error) {
 		return res, nil
 	case <-time.After(timeout):
 		quit <- true
+		TIMEOUTS.Inc("Name()")
 		return "", TIMEOUT_ERROR("Name()")
 	}
 }
 
 //Set the player's name with a timeout
 func (t TimeoutPlayer) SetName(newName string) error {
-	go t.player.SetName(newName)
-	return nil
+	return t.notify("SetName()", func() {
+		t.player.SetName(newName)
+	})
 }
 
 //Get the location to place your next worker
@@ -62,6 +84,7 @@ func (t TimeoutPlayer) PlaceWorker(b board.IBoard) (board.Pos, error) {
 		return res, nil
 	case <-time.After(timeout):
 		quit <- true
+		TIMEOUTS.Inc("PlaceWorker()")
 		return board.Pos{X: -1, Y: -1}, TIMEOUT_ERROR("PlaceWorker()")
 	}
 }
@@ -79,6 +102,7 @@ func (t TimeoutPlayer) NextTurn(b board.IBoard) (iplayer.Turn, error) {
 		return res, nil
 	case <-time.After(timeout):
 		quit <- true
+		TIMEOUTS.Inc("NextTurn()")
 		return iplayer.Turn{
 			WID:     -1,
 			MoveTo:  board.Pos{X: -1, Y: -1},
@@ -89,13 +113,103 @@ func (t TimeoutPlayer) NextTurn(b board.IBoard) (iplayer.Turn, error) {
 
 // Receive an opponent we are playing against
 func (t TimeoutPlayer) SetOpponent(name string) error {
-	go t.player.SetOpponent(name)
-	return nil
+	return t.notify("SetOpponent()", func() {
+		t.player.SetOpponent(name)
+	})
 }
 
 // Receive the results of a finished Tournament
 func (t TimeoutPlayer) ReceiveTournamentResult(result result.TournamentResult) error {
-	return nil
+	return t.notify("ReceiveTournamentResults()", func() {
+		t.player.ReceiveTournamentResults(result.Games)
+	})
+}
+
+// Inform the player of a new series
+func (t TimeoutPlayer) StartSeries(opponent string, games int) error {
+	return t.notify("StartSeries()", func() {
+		t.player.StartSeries(opponent, games)
+	})
+}
+
+// Inform the player of a new game
+func (t TimeoutPlayer) StartGame(game int, order int) error {
+	return t.notify("StartGame()", func() {
+		t.player.StartGame(game, order)
+	})
+}
+
+// Inform the player of their opponent's turn
+func (t TimeoutPlayer) OpponentTurn(b board.IBoard, turn iplayer.Turn) error {
+	return t.notify("OpponentTurn()", func() {
+		t.player.OpponentTurn(b, turn)
+	})
+}
+
+// Inform the player of the end of a game
+func (t TimeoutPlayer) EndGame(result rules.GameResult) error {
+	return t.notify("EndGame()", func() {
+		t.player.EndGame(result)
+	})
+}
+
+// Deliver a notification to the player, waiting for it to be handled so that
+// notifications arrive in order, but giving up after the timeout. A player
+// still handling a notification that timed out is sent none until it is done
+func (t TimeoutPlayer) notify(method string, call func()) error {
+	n := t.notifications
+	if n.isStalled() {
+		return TIMEOUT_ERROR(method)
+	}
+
+	timeout := time.Duration(t.timeout) * TIMEOUT_UNIT
+	done := make(chan bool, 1)
+
+	go func() {
+		n.delivering.Lock()
+		defer n.delivering.Unlock()
+		call()
+		n.handled(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-time.After(timeout):
+		if !n.stall(done) {
+			return nil
+		}
+		TIMEOUTS.Inc(method)
+		return TIMEOUT_ERROR(method)
+	}
+}
+
+func (n *notifications) isStalled() bool {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	return n.stalled
+}
+
+// Mark the player stalled, unless the notification was handled just now.
+// Returns whether it was
+func (n *notifications) stall(done <-chan bool) bool {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	select {
+	case <-done:
+		return false
+	default:
+		n.stalled = true
+		return true
+	}
+}
+
+// The player has handled a notification, so is no longer stalled
+func (n *notifications) handled(done chan<- bool) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	n.stalled = false
+	done <- true
 }
 
 // ######### Go sucks ########## //
@@ -0,0 +1,68 @@
+package sandbox
+
+import (
+	"testing"
+	"time"
+
+	iplayer "github.com/CS4500-F18/dare-rebr/Santorini/Common/Player"
+	rules "github.com/CS4500-F18/dare-rebr/Santorini/Common/Rules"
+	client "github.com/CS4500-F18/dare-rebr/Santorini/Player/Client"
+)
+
+// A valid player that hangs at the end of a game, and counts the games and
+// opponents it hears about
+type hangingPlayer struct {
+	iplayer.IPlayer
+	games     chan int
+	opponents chan string
+	hang      chan bool
+}
+
+func (p hangingPlayer) SetOpponent(name string) {
+	p.opponents <- name
+}
+
+func (p hangingPlayer) StartGame(game int, order int) {
+	p.games <- game
+}
+
+func (p hangingPlayer) EndGame(result rules.GameResult) {
+	<-p.hang
+}
+
+// A player that doesn't handle a notification in time is sent no more until
+// it has, then hears about the next series as usual
+func TestTimeoutPlayer_Stalled(t *testing.T) {
+	p := hangingPlayer{client.ValidPlayer("fido"), make(chan int, 2), make(chan string, 1), make(chan bool)}
+	timeout := NewTimeoutPlayer(50, p)
+
+	if err := timeout.StartGame(0, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := timeout.EndGame(rules.GameResult{}); err == nil {
+		t.Fatal("Hanging player handled the end of the game")
+	}
+
+	start := time.Now()
+	if err := timeout.StartGame(1, 0); err == nil {
+		t.Error("Stalled player was sent a notification")
+	}
+	if elapsed := time.Since(start); elapsed > 25*time.Millisecond {
+		t.Errorf("Took %v to skip a stalled player", elapsed)
+	}
+	if len(p.games) != 1 {
+		t.Errorf("Expected only the first game, heard about %v", len(p.games))
+	}
+
+	close(p.hang)
+	deadline := time.Now().Add(time.Second)
+	for timeout.SetOpponent("rex") != nil {
+		if time.Now().After(deadline) {
+			t.Fatal("Player that caught up was still skipped")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if opponent := <-p.opponents; opponent != "rex" {
+		t.Errorf("Expected rex as the opponent, got %q", opponent)
+	}
+}
@@ -5,6 +5,7 @@ import (
 
 	board "github.com/CS4500-F18/dare-rebr/Santorini/Common/Board"
 	iplayer "github.com/CS4500-F18/dare-rebr/Santorini/Common/Player"
+	rules "github.com/CS4500-F18/dare-rebr/Santorini/Common/Rules"
 	result "github.com/CS4500-F18/dare-rebr/Santorini/Common/Tournament"
 )
 
@@ -25,4 +26,10 @@ type WrappedPlayer interface {
 	PlaceWorker(b board.IBoard) (board.Pos, error)
 	NextTurn(b board.IBoard) (iplayer.Turn, error)
 	ReceiveTournamentResult(result result.TournamentResult) error
+
+	// Lifecycle notifications, see the Player interface (Common/Player)
+	StartSeries(opponent string, games int) error
+	StartGame(game int, order int) error
+	OpponentTurn(b board.IBoard, t iplayer.Turn) error
+	EndGame(result rules.GameResult) error
 }
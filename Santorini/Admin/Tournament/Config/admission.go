@@ -0,0 +1,63 @@
+package config
+
+import (
+	"fmt"
+	"sync"
+
+	data "github.com/CS4500-F18/dare-rebr/Santorini/Common/JSON"
+)
+
+// Who may still register: players up to the maximum, and observers, until
+// registration closes. A nil admission lets everyone in
+type admission struct {
+	//most players to admit, or 0 for no limit
+	max int
+
+	lock    sync.Mutex
+	players int
+	closed  bool
+}
+
+func newAdmission(max int) *admission {
+	return &admission{max: max}
+}
+
+// Take a place for a client with the given role, or return the Rejection
+// turning it away
+func (a *admission) admit(role string) error {
+	if a == nil {
+		return nil
+	}
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	if a.closed {
+		return data.Rejection{Reason: "registration is closed"}
+	} else if role == data.PLAYER_ROLE && a.max > 0 && a.players >= a.max {
+		return data.Rejection{Reason: fmt.Sprintf("the tournament is full, with %v players", a.max)}
+	}
+	if role == data.PLAYER_ROLE {
+		a.players++
+	}
+	return nil
+}
+
+// Give back the place of a client that was admitted but never registered
+func (a *admission) release(role string) {
+	if a == nil || role != data.PLAYER_ROLE {
+		return
+	}
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.players--
+}
+
+// Turn away everyone from now on
+func (a *admission) close() {
+	if a == nil {
+		return
+	}
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.closed = true
+}
@@ -9,3 +9,15 @@ import (
 type TournamentConfig interface {
 	GenerateComponents() ([]sandbox.WrappedPlayer, []iobs.IObserver)
 }
+
+// A TournamentConfig that reserves names for authenticated players, which
+// no other player should be renamed to
+type NameReserver interface {
+	ReservedNames() map[string]bool
+}
+
+// A TournamentConfig that can build just its first few players, so none are
+// started only to go unused
+type PlayerSource interface {
+	FirstPlayers(count int) []sandbox.WrappedPlayer
+}
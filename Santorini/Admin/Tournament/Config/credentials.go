@@ -0,0 +1,98 @@
+package config
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	data "github.com/CS4500-F18/dare-rebr/Santorini/Common/JSON"
+)
+
+// Prefix of a password stored as the hex SHA-256 digest of the password
+const SHA256_PREFIX = "sha256:"
+
+// Who may play under which names, loaded from a local JSON file:
+//
+//   {"tokens":    {"<pre-shared token>": "name", ...},
+//    "passwords": {"name": "<password or sha256:digest>", ...}}
+//
+// Every name in the file is reserved: a player can only register with it by
+// authenticating, in every tournament the server runs. Names are compared
+// case-insensitively, as the tournament lowercases them
+type Credentials struct {
+	Tokens    map[string]string `json:"tokens"`
+	Passwords map[string]string `json:"passwords"`
+}
+
+// Load Credentials from the JSON file at the given path
+func LoadCredentials(path string) (*Credentials, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	creds := &Credentials{}
+	if err := json.NewDecoder(file).Decode(creds); err != nil {
+		return nil, fmt.Errorf("Invalid credentials file %s: %v", path, err)
+	}
+	return creds, nil
+}
+
+// The lowercased names only authenticated players may use
+func (c *Credentials) ReservedNames() map[string]bool {
+	reserved := make(map[string]bool)
+	for _, name := range c.Tokens {
+		reserved[strings.ToLower(name)] = true
+	}
+	for name := range c.Passwords {
+		reserved[strings.ToLower(name)] = true
+	}
+	return reserved
+}
+
+// Check whether a player may register with the given name, and the given
+// Auth (nil if it offered none). Returns a Rejection if not
+func (c *Credentials) Check(name string, auth *data.Auth) error {
+	lower := strings.ToLower(name)
+
+	if auth == nil {
+		if c.ReservedNames()[lower] {
+			return data.Rejection{Reason: fmt.Sprintf("the name %s is reserved, authenticate to use it", name)}
+		}
+		return nil
+	}
+
+	if auth.Token != "" {
+		for token, owner := range c.Tokens {
+			if subtle.ConstantTimeCompare([]byte(token), []byte(auth.Token)) == 1 {
+				if strings.ToLower(owner) != lower {
+					return data.Rejection{Reason: fmt.Sprintf("token is not for %s", name)}
+				}
+				return nil
+			}
+		}
+		return data.Rejection{Reason: "unknown token"}
+	}
+
+	for owner, password := range c.Passwords {
+		if strings.ToLower(owner) == lower && checkPassword(password, auth.Password) {
+			return nil
+		}
+	}
+	return data.Rejection{Reason: fmt.Sprintf("wrong name or password for %s", name)}
+}
+
+// Whether the offered password matches the stored one, in plain text or as
+// a SHA-256 digest
+func checkPassword(stored, offered string) bool {
+	if strings.HasPrefix(stored, SHA256_PREFIX) {
+		digest := sha256.Sum256([]byte(offered))
+		offered = SHA256_PREFIX + hex.EncodeToString(digest[:])
+	}
+	return subtle.ConstantTimeCompare([]byte(stored), []byte(offered)) == 1
+}
@@ -0,0 +1,62 @@
+package config
+
+import (
+	"testing"
+
+	data "github.com/CS4500-F18/dare-rebr/Santorini/Common/JSON"
+)
+
+// fido has a pre-shared token, rex a hashed password (of "hunter2")
+func testCredentials() *Credentials {
+	return &Credentials{
+		Tokens:    map[string]string{"s3cret": "fido"},
+		Passwords: map[string]string{"Rex": "sha256:f52fbd32b2b3b86ff88ef6c490628285f482af15ddcb29541f94bcf526a3f6c7"},
+	}
+}
+
+func TestCredentials_Check(t *testing.T) {
+	creds := testCredentials()
+
+	tests := []struct {
+		name string
+		auth *data.Auth
+		ok   bool
+	}{
+		{"fido", &data.Auth{Token: "s3cret"}, true},
+		{"FIDO", &data.Auth{Token: "s3cret"}, true},
+		{"rex", &data.Auth{Token: "s3cret"}, false},
+		{"fido", &data.Auth{Token: "guess"}, false},
+		{"rex", &data.Auth{Password: "hunter2"}, true},
+		{"rex", &data.Auth{Password: "hunter3"}, false},
+		{"fido", nil, false},
+		{"spot", nil, true},
+	}
+
+	for _, test := range tests {
+		err := creds.Check(test.name, test.auth)
+		if (err == nil) != test.ok {
+			t.Errorf("Check(%s, %+v) = %v", test.name, test.auth, err)
+		}
+	}
+}
+
+// Impersonating a reserved name is rejected, over either protocol
+func TestRegister_Impersonation(t *testing.T) {
+	a := acceptor{timeout: 1000, credentials: testCredentials()}
+
+	hello := data.NewHello(data.PLAYER_ROLE, "fido")
+	_, err, reply := handshakeWith(a, data.Registration{Role: data.PLAYER_ROLE, Name: "fido", Tagged: true, Hello: hello})
+	if _, ok := err.(data.Rejection); !ok || reply.Type != data.MSG_REJECT {
+		t.Errorf("Impersonator was not rejected: %v, %+v", err, reply)
+	}
+
+	if _, err, _ := handshakeWith(a, data.Registration{Role: data.PLAYER_ROLE, Name: "Rex"}); err == nil {
+		t.Error("Legacy impersonator was not rejected")
+	}
+
+	hello.Auth = &data.Auth{Token: "s3cret"}
+	reg, err, reply := handshakeWith(a, data.Registration{Role: data.PLAYER_ROLE, Name: "fido", Tagged: true, Hello: hello})
+	if err != nil || reply.Type != data.MSG_WELCOME || reg.player == nil {
+		t.Errorf("Authenticated player was not welcomed: %v, %+v", err, reply)
+	}
+}
@@ -0,0 +1,162 @@
+package config
+
+import (
+	"strings"
+
+	sandbox "github.com/CS4500-F18/dare-rebr/Santorini/Admin/Sandbox"
+	result "github.com/CS4500-F18/dare-rebr/Santorini/Common/Tournament"
+	lib "github.com/CS4500-F18/dare-rebr/Santorini/Lib"
+	obs "github.com/CS4500-F18/dare-rebr/Santorini/Observer"
+)
+
+// A tournament of the players from several configs at once, e.g. house bots
+// from a StaticConfig alongside the clients a RemoteConfig registers. Players
+// keep their names in the order of the configs; one whose name is taken by an
+// earlier player, or reserved by another config, gets letters added to it.
+// With a reserve, the field is topped up from its players, in order, to the
+// minimum
+type HybridConfig struct {
+	sources []TournamentConfig
+
+	//where to find more players when there are too few, or nil
+	reserve TournamentConfig
+
+	//fewest players to play with, if there are enough in reserve
+	minPlayers int
+}
+
+func NewHybridConfig(sources ...TournamentConfig) HybridConfig {
+	return HybridConfig{sources: sources}
+}
+
+// Top the field up with the reserve's players, in order, until there are at
+// least the given number of players. Its observers aren't used
+func (c HybridConfig) WithReserve(reserve TournamentConfig, min int) HybridConfig {
+	c.reserve, c.minPlayers = reserve, min
+	return c
+}
+
+// Every player and observer from every config, one config after another,
+// then from the reserve if need be
+func (c HybridConfig) GenerateComponents() ([]sandbox.WrappedPlayer, []obs.IObserver) {
+	players := make([]sandbox.WrappedPlayer, 0)
+	observers := make([]obs.IObserver, 0)
+	names := newNames(c.sources)
+
+	for idx, source := range c.sources {
+		sourcePlayers, sourceObservers := source.GenerateComponents()
+		for _, player := range sourcePlayers {
+			players = append(players, names.claim(idx, player))
+		}
+		observers = append(observers, sourceObservers...)
+	}
+
+	if c.reserve != nil && len(players) < c.minPlayers {
+		for _, player := range c.reserves(c.minPlayers - len(players)) {
+			players = append(players, names.claim(-1, player))
+		}
+	}
+	return players, observers
+}
+
+// Up to the given number of the reserve's players. If the reserve can only
+// build all of its players, those left over are sent empty results, so any
+// engines among them exit
+func (c HybridConfig) reserves(count int) []sandbox.WrappedPlayer {
+	if source, ok := c.reserve.(PlayerSource); ok {
+		return source.FirstPlayers(count)
+	}
+
+	reserves, _ := c.reserve.GenerateComponents()
+	if len(reserves) <= count {
+		return reserves
+	}
+	for _, unused := range reserves[count:] {
+		go unused.ReceiveTournamentResult(result.TournamentResult{})
+	}
+	return reserves[:count]
+}
+
+// The lowercased names only authenticated players may use, in any config
+func (c HybridConfig) ReservedNames() map[string]bool {
+	reserved := make(map[string]bool)
+	for _, source := range c.sources {
+		for name := range reservedBy(source) {
+			reserved[name] = true
+		}
+	}
+	return reserved
+}
+
+// The names taken so far in a hybrid tournament, and those each config
+// reserves
+type names struct {
+	taken    map[string]bool
+	reserved []map[string]bool
+}
+
+func newNames(sources []TournamentConfig) names {
+	n := names{taken: make(map[string]bool), reserved: make([]map[string]bool, 0)}
+	for _, source := range sources {
+		n.reserved = append(n.reserved, reservedBy(source))
+	}
+	return n
+}
+
+// Whether a player from the config at the given index (-1 for the reserve)
+// can't have the name
+func (n names) unavailable(source int, name string) bool {
+	if n.taken[name] {
+		return true
+	}
+	for idx, reserved := range n.reserved {
+		if idx != source && reserved[name] {
+			return true
+		}
+	}
+	return false
+}
+
+// The player from the config at the given index (-1 for the reserve), having
+// taken its name, or renamed if it can't have it
+func (n names) claim(source int, player sandbox.WrappedPlayer) sandbox.WrappedPlayer {
+	original, _ := player.Name()
+	name := strings.ToLower(original)
+	if !n.unavailable(source, name) {
+		n.taken[name] = true
+		return player
+	}
+
+	for suffix := ""; ; suffix += string(lib.ALPHA[0]) {
+		for _, letter := range lib.ALPHA {
+			if candidate := name + suffix + string(letter); !n.unavailable(source, candidate) {
+				n.taken[candidate] = true
+				player.SetName(candidate)
+				return &renamedPlayer{WrappedPlayer: player, name: candidate}
+			}
+		}
+	}
+}
+
+// The names the config reserves, if it reserves any
+func reservedBy(c TournamentConfig) map[string]bool {
+	if r, ok := c.(NameReserver); ok {
+		return r.ReservedNames()
+	}
+	return make(map[string]bool)
+}
+
+// A player known by the name it was given, whether or not it has caught up
+type renamedPlayer struct {
+	sandbox.WrappedPlayer
+	name string
+}
+
+func (p *renamedPlayer) Name() (string, error) {
+	return p.name, nil
+}
+
+func (p *renamedPlayer) SetName(newName string) error {
+	p.name = newName
+	return p.WrappedPlayer.SetName(newName)
+}
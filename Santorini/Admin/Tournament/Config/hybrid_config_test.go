@@ -0,0 +1,84 @@
+package config
+
+import (
+	"testing"
+
+	sandbox "github.com/CS4500-F18/dare-rebr/Santorini/Admin/Sandbox"
+	obs "github.com/CS4500-F18/dare-rebr/Santorini/Observer"
+	client "github.com/CS4500-F18/dare-rebr/Santorini/Player/Client"
+)
+
+// A config with the named players, reserving some names
+type fakeConfig struct {
+	names    []string
+	reserved map[string]bool
+}
+
+func (c fakeConfig) GenerateComponents() ([]sandbox.WrappedPlayer, []obs.IObserver) {
+	players := make([]sandbox.WrappedPlayer, 0)
+	for _, name := range c.names {
+		players = append(players, sandbox.NewNormalPlayer(client.BrokenPlayer(name)))
+	}
+	return players, make([]obs.IObserver, 0)
+}
+
+func (c fakeConfig) ReservedNames() map[string]bool {
+	return c.reserved
+}
+
+// Earlier configs keep their names, later ones lose names that clash or are
+// reserved, and reserves top the field up to the minimum
+func TestHybridConfig(t *testing.T) {
+	remote := fakeConfig{[]string{"fido", "rex"}, map[string]bool{"fido": true, "max": true}}
+	house := fakeConfig{[]string{"fido", "Rex", "max", "spot"}, nil}
+	reserve := fakeConfig{[]string{"bot", "bot", "bot"}, nil}
+
+	c := NewHybridConfig(remote, house).WithReserve(reserve, 8)
+	players, _ := c.GenerateComponents()
+	expected := []string{"fido", "rex", "fidoa", "rexa", "maxa", "spot", "bot", "bota"}
+	if len(players) != len(expected) {
+		t.Fatalf("Expected %v players, got %v", len(expected), len(players))
+	}
+	for i, player := range players {
+		if name, _ := player.Name(); name != expected[i] {
+			t.Errorf("Player %v is %s, not %s", i, name, expected[i])
+		}
+	}
+	if reserved := c.ReservedNames(); len(reserved) != 2 || !reserved["max"] {
+		t.Errorf("Wrong reserved names %v", reserved)
+	}
+
+	// Enough players without the reserve
+	if players, _ := NewHybridConfig(remote, house).WithReserve(reserve, 4).GenerateComponents(); len(players) != 6 {
+		t.Errorf("Topped up a full field to %v players", len(players))
+	}
+}
+
+// A reserve that can build only some of its players is asked for as many as
+// are needed, and no more
+type countingConfig struct {
+	fakeConfig
+	asked chan int
+}
+
+func (c countingConfig) FirstPlayers(count int) []sandbox.WrappedPlayer {
+	c.asked <- count
+	players, _ := c.GenerateComponents()
+	return players[:count]
+}
+
+func TestHybridConfig_FirstReserves(t *testing.T) {
+	reserve := countingConfig{fakeConfig{[]string{"bot", "bot", "bot"}, nil}, make(chan int, 1)}
+	players, _ := NewHybridConfig(fakeConfig{[]string{"fido"}, nil}).WithReserve(reserve, 3).GenerateComponents()
+	if len(players) != 3 {
+		t.Errorf("Expected 3 players, got %v", len(players))
+	}
+	if asked := <-reserve.asked; asked != 2 {
+		t.Errorf("Asked the reserve for %v players, not 2", asked)
+	}
+
+	static := StaticConfig{Players: []StaticPlayer{{Kind: VALID, Name: "uno"}, {Kind: VALID, Name: "dos"}, {Kind: VALID, Name: "tres"}}}
+	if players := static.FirstPlayers(2); len(players) != 2 {
+		t.Errorf("Built %v players, not 2", len(players))
+	}
+}
@@ -0,0 +1,123 @@
+package config
+
+import (
+	"crypto/tls"
+	"errors"
+	"log"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// How long to wait after a failed Accept before trying again, at first. Each
+// wait is twice the last, up to MAX_ACCEPT_BACKOFF
+const (
+	ACCEPT_BACKOFF     = 5 * time.Millisecond
+	MAX_ACCEPT_BACKOFF = time.Second
+)
+
+// A listener shared by every tournament of a long-running server. One
+// goroutine accepts connections for as long as it is open, and hands each to
+// whichever tournament took it over last
+type Listener struct {
+	listener net.Listener
+	conns    chan net.Conn
+	closed   chan bool
+
+	lock sync.Mutex
+	// closed to tell the tournament that had the listener before to stop
+	handedOver chan bool
+	closeOnce  sync.Once
+}
+
+// Listen on the given port, over TLS if there is a configuration
+func Listen(port int, config *tls.Config) (*Listener, error) {
+	l, err := net.Listen("tcp", ":"+strconv.Itoa(port))
+	if err != nil {
+		return nil, err
+	}
+	if config != nil {
+		l = tls.NewListener(l, config)
+	}
+	return NewListener(l), nil
+}
+
+// Share an open listener between tournaments
+func NewListener(l net.Listener) *Listener {
+	listener := &Listener{
+		listener:   l,
+		conns:      make(chan net.Conn),
+		closed:     make(chan bool),
+		handedOver: make(chan bool),
+	}
+	go listener.accept(l)
+	return listener
+}
+
+// The address being listened on
+func (l *Listener) Addr() net.Addr {
+	return l.listener.Addr()
+}
+
+// Stop listening. Connections not yet taken by a tournament are closed
+func (l *Listener) Close() error {
+	var err error
+	l.closeOnce.Do(func() {
+		close(l.closed)
+		err = l.listener.Close()
+	})
+	return err
+}
+
+// Take the listener over from the tournament that had it, returning the
+// connections it accepts and a channel closed when the next one takes over
+func (l *Listener) takeOver() (<-chan net.Conn, <-chan bool) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	close(l.handedOver)
+	l.handedOver = make(chan bool)
+	return l.conns, l.handedOver
+}
+
+// Accept connections from another listener too, such as the channels of a
+// multiplexed connection, for as long as both are open
+func (l *Listener) merge(from net.Listener) {
+	go l.accept(from)
+}
+
+// Accept each connection from the given listener, until it or this Listener
+// closes. After any other failure, such as running out of file descriptors,
+// wait a little longer each time before trying again, as net/http does
+func (l *Listener) accept(from net.Listener) {
+	var wait time.Duration
+	for {
+		conn, err := from.Accept()
+		if errors.Is(err, net.ErrClosed) {
+			return
+		} else if err != nil {
+			if wait *= 2; wait == 0 {
+				wait = ACCEPT_BACKOFF
+			} else if wait > MAX_ACCEPT_BACKOFF {
+				wait = MAX_ACCEPT_BACKOFF
+			}
+			log.Printf("listener: accept failed, retrying in %v: %v", wait, err)
+			select {
+			case <-time.After(wait):
+				continue
+			case <-l.closed:
+				from.Close()
+				return
+			}
+		}
+		wait = 0
+
+		select {
+		case l.conns <- conn:
+		case <-l.closed:
+			conn.Close()
+			from.Close()
+			return
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package config
+
+import (
+	"errors"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// A net.Listener whose every Accept fails, as when out of file descriptors
+type failingListener struct {
+	net.Listener
+	accepts int32
+}
+
+func (l *failingListener) Accept() (net.Conn, error) {
+	atomic.AddInt32(&l.accepts, 1)
+	return nil, errors.New("too many open files")
+}
+
+func (l *failingListener) Close() error {
+	return nil
+}
+
+// Failed Accepts are retried with a growing wait, rather than at once, and
+// closing the listener stops the retrying
+func TestListener_Backoff(t *testing.T) {
+	from := &failingListener{}
+	l := NewListener(from)
+	time.Sleep(100 * time.Millisecond)
+	l.Close()
+
+	if accepts := atomic.LoadInt32(&from.accepts); accepts > 10 {
+		t.Errorf("Tried to accept %v times in 100ms", accepts)
+	}
+	accepts := atomic.LoadInt32(&from.accepts)
+	time.Sleep(2 * MAX_ACCEPT_BACKOFF)
+	if atomic.LoadInt32(&from.accepts) != accepts {
+		t.Error("Kept accepting after the listener closed")
+	}
+}
@@ -1,15 +1,33 @@
 package config
 
 import (
+	"crypto/tls"
+	"fmt"
+	"log"
 	"net"
-	"strconv"
+	"sync"
 	"time"
 
 	sandbox "github.com/CS4500-F18/dare-rebr/Santorini/Admin/Sandbox"
+	data "github.com/CS4500-F18/dare-rebr/Santorini/Common/JSON"
+	lib "github.com/CS4500-F18/dare-rebr/Santorini/Lib"
 	obs "github.com/CS4500-F18/dare-rebr/Santorini/Observer"
+	remoteobs "github.com/CS4500-F18/dare-rebr/Santorini/Remote/Observer"
 	remote "github.com/CS4500-F18/dare-rebr/Santorini/Remote/Player"
 )
 
+// How often players waiting for the tournament to start are told how
+// registration is going
+const STATUS_INTERVAL = 2 * time.Second
+
+// Metrics gathered while registering clients
+var (
+	activeConnections = lib.METRICS.Gauge("santorini_active_connections", "Client connections open.")
+	registered        = lib.METRICS.Counter("santorini_registrations_total", "Clients registered, by role.", "role")
+	registrationTime  = lib.METRICS.Histogram("santorini_registration_duration_seconds", "How long registration stayed open before each tournament.",
+		[]float64{1, 5, 10, 30, 60, 120, 300, 600, 1800})
+)
+
 // TourneyConfiguration for a Tournament
 type RemoteConfig struct {
 	//min players
@@ -18,34 +36,301 @@ type RemoteConfig struct {
 	//port to listen on
 	port int
 
-	//time limit to accept new players (milliseconds)
+	//time limit to accept new players (seconds)
 	acceptLimit int
 
+	//most players to accept, starting as soon as there are this many (0 for no limit)
+	maxPlayers int
+
+	//seconds after which registration closes, even without enough players (0 for never)
+	deadline int
+
+	//how often waiting players are sent the waiting room's status
+	statusInterval time.Duration
+
 	//timeout in milliseconds for underlying players
 	timeout int
+
+	//where every message to and from clients is logged, or nil
+	logger *log.Logger
+
+	//directory to record a transcript of each connection in, or "" for none
+	transcripts string
+
+	//when a player whose connection drops is forfeited, rather than resumed
+	rule remote.ForfeitRule
+
+	//who may play under reserved names, or nil to let anyone claim any name
+	credentials *Credentials
+
+	//the TLS settings clients must connect with, or nil for plain TCP
+	tls *tls.Config
+
+	//the listener shared with other tournaments, or nil to listen on the port
+	listener *Listener
+
+	//closed to stop registration early, starting with whoever has registered
+	cancel <-chan bool
+
+	//told the names of the players registered so far, as each registers, or nil
+	report func(players []string)
+
+	//the lobby registering for this tournament, on a server hosting several
+	lobby string
 }
 
 func NewRemoteConfig(players, port, limit, timeout int) RemoteConfig {
-	return RemoteConfig{players, port, limit, timeout}
+	return RemoteConfig{playerCount: players, port: port, acceptLimit: limit, timeout: timeout, statusInterval: STATUS_INTERVAL}
+}
+
+// Wait for at least the given number of players, for the given number of
+// seconds at a time
+func (c RemoteConfig) WithPlayers(min, limit int) RemoteConfig {
+	c.playerCount, c.acceptLimit = min, limit
+	return c
+}
+
+// The fewest players to start with, once the time limit lapses
+func (c RemoteConfig) MinPlayers() int {
+	return c.playerCount
+}
+
+// Start with at least the given number of players, once the time limit lapses
+func (c RemoteConfig) WithMinPlayers(min int) RemoteConfig {
+	c.playerCount = min
+	return c
+}
+
+// Give each client the given number of milliseconds to reply to each message
+func (c RemoteConfig) WithTimeout(timeout int) RemoteConfig {
+	c.timeout = timeout
+	return c
+}
+
+// Accept at most the given number of players, starting as soon as they have
+// all registered, and turning away any more
+func (c RemoteConfig) WithMaxPlayers(max int) RemoteConfig {
+	c.maxPlayers = max
+	return c
+}
+
+// Close registration after the given number of seconds, starting with
+// whoever has registered by then, even if that is fewer than the minimum
+func (c RemoteConfig) WithDeadline(seconds int) RemoteConfig {
+	c.deadline = seconds
+	return c
+}
+
+// Log every message to and from clients to the given logger
+func (c RemoteConfig) WithLogger(logger *log.Logger) RemoteConfig {
+	c.logger = logger
+	return c
+}
+
+// Record a transcript of every message over each connection to its own file
+// in the given directory
+func (c RemoteConfig) WithTranscripts(dir string) RemoteConfig {
+	c.transcripts = dir
+	return c
+}
+
+// Let players whose connections drop reconnect and resume, until the given
+// rule forfeits them. Without a grace window, every drop is a forfeit
+func (c RemoteConfig) WithForfeitRule(rule remote.ForfeitRule) RemoteConfig {
+	c.rule = rule
+	return c
+}
+
+// Only let players register with reserved names by authenticating
+// with the given Credentials
+func (c RemoteConfig) WithCredentials(credentials *Credentials) RemoteConfig {
+	c.credentials = credentials
+	return c
+}
+
+// Accept only TLS connections, with the given settings
+func (c RemoteConfig) WithTLS(config *tls.Config) RemoteConfig {
+	c.tls = config
+	return c
+}
+
+// Register clients from the given listener, rather than listening on the
+// port, so that one listener can serve many tournaments
+func (c RemoteConfig) WithListener(l *Listener) RemoteConfig {
+	c.listener = l
+	return c
+}
+
+// Stop registration as soon as the given channel is closed, starting with
+// whoever has registered by then, even if that is fewer than the minimum
+func (c RemoteConfig) WithCancel(cancel <-chan bool) RemoteConfig {
+	c.cancel = cancel
+	return c
+}
+
+// Tell the given function the names of the players registered so far, each
+// time another registers
+func (c RemoteConfig) WithRegistrations(report func(players []string)) RemoteConfig {
+	c.report = report
+	return c
+}
+
+// Tell clients they joined the named lobby, when they are welcomed
+func (c RemoteConfig) WithLobby(lobby string) RemoteConfig {
+	c.lobby = lobby
+	return c
+}
+
+// The lowercased names only authenticated players may use
+func (c RemoteConfig) ReservedNames() map[string]bool {
+	if c.credentials == nil {
+		return make(map[string]bool)
+	}
+	return c.credentials.ReservedNames()
 }
 
 // Create Tournament-usable pieces from a TourneyConfiguration
 // Wait for Players til you hit the time limit, re-run if below min
+// Observers may register alongside Players, and will watch every game
 // NOTE: On re-run, keep previous players until you hit the minimum limit.
+// NOTE: Without a shared Listener, the one opened here closes as registration
+// does, so players can't resume
 func (c RemoteConfig) GenerateComponents() ([]sandbox.WrappedPlayer, []obs.IObserver) {
-	serv, err := net.Listen("tcp", ":"+strconv.Itoa(c.port))
+	listener := c.listener
+	if listener == nil {
+		var err error
+		if listener, err = Listen(c.port, c.tls); err != nil {
+			panic(err)
+		}
+		defer listener.Close()
+	}
+	return c.acceptFrom(listener)
+}
+
+// Register clients for as long as registration stays open, handing each on
+// as soon as it registers rather than waiting for a tournament to fill, as a
+// ladder needs. Registration stays open until the cancel channel is closed,
+// then both channels are closed. The minimum, maximum, time limit and
+// deadline don't apply. Without a shared Listener, the one opened here closes
+// along with the channels
+func (c RemoteConfig) Stream() (<-chan sandbox.WrappedPlayer, <-chan obs.IObserver) {
+	players := make(chan sandbox.WrappedPlayer)
+	observers := make(chan obs.IObserver)
+	if c.listener != nil {
+		go c.streamFrom(c.listener, players, observers)
+		return players, observers
+	}
+
+	listener, err := Listen(c.port, c.tls)
 	if err != nil {
 		panic(err)
 	}
+	go func() {
+		defer listener.Close()
+		c.streamFrom(listener, players, observers)
+	}()
+	return players, observers
+}
+
+// Register clients from the given listener, handing each on as it registers,
+// until registration is cancelled
+func (c RemoteConfig) streamFrom(serv *Listener, players chan<- sandbox.WrappedPlayer, observers chan<- obs.IObserver) {
+	defer close(players)
+	defer close(observers)
+
+	registrations := make(chan registration)
+	started := make(chan bool)
+	defer close(started)
+
+	var reconnector *remote.Reconnector
+	if c.rule.Grace > 0 {
+		reconnector = remote.NewReconnector(c.rule)
+	}
+
+	admission := newAdmission(0)
+	defer admission.close()
+
+	acceptor := acceptor{timeout: c.timeout, logger: c.logger, transcripts: c.transcripts, reconnector: reconnector, credentials: c.credentials, admission: admission, lobby: c.lobby}
+	go acceptor.acceptConnections(serv, registrations, started)
+
+	names := make([]string, 0)
+	for {
+		select {
+		case <-c.cancel:
+			return
+
+		case reg := <-registrations:
+			registered.Inc(reg.Role)
+			if reg.Role == data.OBSERVER_ROLE {
+				select {
+				case observers <- remoteobs.NewRemoteObserver(reg.Name, reg.conn, c.timeout):
+				case <-c.cancel:
+					return
+				}
+				continue
+			}
+
+			select {
+			case players <- reg.player:
+			case <-c.cancel:
+				return
+			}
+			names = append(names, reg.Name)
+			if c.report != nil {
+				c.report(append(make([]string, 0), names...))
+			}
+		}
+	}
+}
+
+// Register clients from the given listener until the tournament can start:
+// when the time limit lapses with at least the minimum players, the maximum
+// registers, or the deadline passes or registration is cancelled. Waiting
+// players are told how it's going
+func (c RemoteConfig) acceptFrom(serv *Listener) ([]sandbox.WrappedPlayer, []obs.IObserver) {
+	registrations := make(chan registration)
+	started := make(chan bool)
+	defer close(started)
+
+	var reconnector *remote.Reconnector
+	if c.rule.Grace > 0 {
+		reconnector = remote.NewReconnector(c.rule)
+	}
 
-	connections := make(chan net.Conn)
+	admission := newAdmission(c.maxPlayers)
+	defer admission.close()
 
-	go acceptConnections(serv, connections)
+	acceptor := acceptor{timeout: c.timeout, logger: c.logger, transcripts: c.transcripts, reconnector: reconnector, credentials: c.credentials, admission: admission, lobby: c.lobby}
+	go acceptor.acceptConnections(serv, registrations, started)
 
 	proxies := make([]sandbox.WrappedPlayer, 0)
-	timer := time.After(time.Duration(c.acceptLimit) * time.Second)
+	waiting := make([]*remote.ProxyPlayer, 0)
+	names := make([]string, 0)
 	observers := make([]obs.IObserver, 0)
 
+	opened := time.Now()
+	defer func() {
+		registrationTime.Observe(time.Since(opened).Seconds())
+	}()
+
+	limit := time.Duration(c.acceptLimit) * time.Second
+	startAt := time.Now().Add(limit)
+	timer := time.After(limit)
+
+	var closeAt time.Time
+	var deadline <-chan time.Time
+	if c.deadline > 0 {
+		closeAt = time.Now().Add(time.Duration(c.deadline) * time.Second)
+		deadline = time.After(time.Until(closeAt))
+	}
+
+	interval := c.statusInterval
+	if interval <= 0 {
+		interval = STATUS_INTERVAL
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
 	for {
 		select {
 		case <-timer:
@@ -54,22 +339,278 @@ func (c RemoteConfig) GenerateComponents() ([]sandbox.WrappedPlayer, []obs.IObse
 				// Return everyone so far
 				return proxies, observers
 			} else {
-				timer = time.After(time.Duration(c.acceptLimit) * time.Second)
+				startAt = time.Now().Add(limit)
+				timer = time.After(limit)
+			}
+
+		case <-deadline:
+			return proxies, observers
+
+		case <-c.cancel:
+			return proxies, observers
+
+		case <-ticker.C:
+			status := data.WaitingRoom{
+				Players:    len(proxies),
+				MinPlayers: c.playerCount,
+				MaxPlayers: c.maxPlayers,
+				StartsIn:   int(time.Until(startAt) / sandbox.TIMEOUT_UNIT),
 			}
+			if c.deadline > 0 {
+				status.ClosesIn = int(time.Until(closeAt) / sandbox.TIMEOUT_UNIT)
+			}
+			broadcast(waiting, status)
 
-		case conn := <-connections:
-			player := remote.NewProxyPlayer(conn, c.timeout)
-			proxies = append(proxies, player)
+		case reg := <-registrations:
+			registered.Inc(reg.Role)
+			if reg.Role == data.OBSERVER_ROLE {
+				observer := remoteobs.NewRemoteObserver(reg.Name, reg.conn, c.timeout)
+				observers = append(observers, observer)
+			} else {
+				proxies = append(proxies, reg.player)
+				waiting = append(waiting, reg.player)
+				names = append(names, reg.Name)
+				if c.report != nil {
+					c.report(append(make([]string, 0), names...))
+				}
+			}
+			if c.maxPlayers > 0 && len(proxies) >= c.maxPlayers {
+				return proxies, observers
+			}
 		}
 	}
 }
 
-func acceptConnections(l net.Listener, c chan net.Conn) {
+// Send the waiting room's status to every waiting player at once, returning
+// when they have all been sent it or timed out
+func broadcast(players []*remote.ProxyPlayer, status data.WaitingRoom) {
+	var wg sync.WaitGroup
+	for _, player := range players {
+		wg.Add(1)
+		go func(p *remote.ProxyPlayer) {
+			defer wg.Done()
+			p.Waiting(status)
+		}(player)
+	}
+	wg.Wait()
+}
+
+// A session, the role and name it registered with, and the proxy for it
+// if it is a player
+type registration struct {
+	data.Registration
+	conn    net.Conn
+	session *lib.Session
+	player  *remote.ProxyPlayer
+
+	// Whether this took over the session of an earlier registration
+	resumed bool
+}
+
+// How new connections are registered
+type acceptor struct {
+	//timeout in milliseconds for each message
+	timeout int
+
+	//where every message is logged, or nil
+	logger *log.Logger
+
+	//directory each connection's transcript is recorded in, or ""
+	transcripts string
+
+	//where players who reconnect resume, or nil to forfeit every drop
+	reconnector *remote.Reconnector
+
+	//who may play under reserved names, or nil
+	credentials *Credentials
+
+	//who may still register, or nil to let everyone in
+	admission *admission
+
+	//the lobby clients are told they joined, or ""
+	lobby string
+}
+
+// Register each connection to the listener, passing new ones on until the
+// tournament has started. Players can still resume after that, until the
+// next tournament takes over the listener. Each connection registers on its
+// own, so one that is slow to register holds up no other
+func (a acceptor) acceptConnections(l *Listener, c chan registration, started chan bool) {
+	conns, handedOver := l.takeOver()
 	for {
-		conn, err := l.Accept()
-		if err == nil {
-			c <- conn
-			time.Sleep(1 * time.Second)
+		select {
+		case <-handedOver:
+			return
+		case <-l.closed:
+			return
+		case conn := <-conns:
+			go a.accept(l, conn, c, started)
+		}
+	}
+}
+
+// Register a single connection, passing it on if it is new and the
+// tournament hasn't started
+func (a acceptor) accept(l *Listener, conn net.Conn, c chan registration, started chan bool) {
+	conn = countConn(conn)
+	if a.transcripts != "" {
+		// Better to play unrecorded than turn the player away
+		conn, _ = lib.RecordConnTo(conn, a.transcripts, conn.RemoteAddr().String())
+	}
+	session := lib.NewSession(conn)
+	session.SetTimeout(time.Duration(a.timeout) * sandbox.TIMEOUT_UNIT)
+	session.SetLogger(a.logger, conn.RemoteAddr().String())
+
+	reg, err := a.register(session)
+	reg.conn = conn
+	if err != nil {
+		session.Close()
+		return
+	} else if reg.resumed {
+		return
+	} else if reg.Role == data.MULTIPLEX_ROLE {
+		// Each client over the connection registers on its own
+		// channel, which can sit idle for as long as it likes
+		session.SetTimeout(0)
+		session.SetLogger(nil, "")
+		l.merge(lib.NewMux(session, conn.RemoteAddr()))
+		return
+	}
+
+	select {
+	case c <- reg:
+	case <-started:
+		if reg.Tagged {
+			reject(session, data.Rejection{Reason: "registration is closed"})
 		}
+		session.Close()
+	}
+}
+
+// Read the registration a client sends first over a new session. A client
+// speaking the tagged protocol is then sent a Welcome, or a Rejection if it
+// can't be played with. The timeout is offered as the time limit for each
+// reply, and players who ask to resume are given a session token. A
+// multiplexed connection is welcomed as it is, and its clients register later
+func (a acceptor) register(session *lib.Session) (registration, error) {
+	reg := registration{session: session}
+
+	if err := session.Receive(&reg.Registration); err != nil {
+		return reg, err
+	} else if !reg.Tagged {
+		if reg.Role == data.PLAYER_ROLE {
+			// Can't authenticate, so can't use a reserved name
+			if err := a.authenticate(reg.Name, nil); err != nil {
+				return reg, err
+			}
+		}
+		if err := a.admission.admit(reg.Role); err != nil {
+			return reg, err
+		}
+		if reg.Role == data.PLAYER_ROLE {
+			reg.player = remote.NewLegacyProxyPlayer(session, reg.Name)
+		}
+		return reg, nil
+	}
+
+	features := make([]string, 0)
+	for _, feature := range data.SUPPORTED_FEATURES {
+		if feature != data.FEATURE_RESUME || a.reconnector != nil {
+			features = append(features, feature)
+		}
+	}
+	welcome, err := data.Negotiate(reg.Hello, features, a.timeout)
+	if err == nil && reg.Role == data.MULTIPLEX_ROLE {
+		// Each client over it is admitted when it registers
+		return a.welcome(reg, welcome)
+	} else if err == nil && reg.Hello.Token != "" && (a.reconnector == nil || !a.reconnector.Has(reg.Hello.Token)) {
+		err = data.Rejection{Reason: "unknown or expired session token"}
+	} else if err == nil && reg.Hello.Token == "" && reg.Role == data.PLAYER_ROLE {
+		err = a.authenticate(reg.Name, reg.Hello.Auth)
+	}
+	if err == nil && reg.Hello.Token == "" {
+		err = a.admission.admit(reg.Role)
+	}
+	if err != nil {
+		if rejection, ok := err.(data.Rejection); ok {
+			reject(session, rejection)
+		}
+		return reg, err
+	}
+
+	reg, err = a.welcome(reg, welcome)
+	if err != nil && reg.Hello.Token == "" {
+		a.admission.release(reg.Role)
+	}
+	return reg, err
+}
+
+// Send an admitted client its Welcome, and set up its proxy if it's a player
+func (a acceptor) welcome(reg registration, welcome data.Welcome) (registration, error) {
+	session := reg.session
+	var err error
+	welcome.Lobby = a.lobby
+
+	if reg.Role == data.PLAYER_ROLE && welcome.Has(data.FEATURE_RESUME) {
+		welcome.Grace = int(a.reconnector.Rule().Grace / sandbox.TIMEOUT_UNIT)
+		if reg.Hello.Token != "" {
+			welcome.Token = reg.Hello.Token
+			reg.resumed = true
+		} else {
+			reg.player = remote.NewProxyPlayer(session, reg.Name, welcome)
+			if welcome.Token, err = a.reconnector.Track(reg.player); err != nil {
+				return reg, err
+			}
+		}
+	} else if reg.Role == data.PLAYER_ROLE {
+		reg.player = remote.NewProxyPlayer(session, reg.Name, welcome)
+	}
+
+	env, err := data.NewEnvelope(data.MSG_WELCOME, 0, 0, welcome)
+	if err != nil {
+		return reg, err
+	}
+	if err := session.Send(env); err != nil {
+		return reg, err
+	}
+
+	if reg.resumed {
+		if _, ok := a.reconnector.Resume(reg.Hello.Token, session); !ok {
+			return reg, fmt.Errorf("Session expired while resuming")
+		}
+	}
+	return reg, nil
+}
+
+// A connection counted in activeConnections until it is closed
+type countedConn struct {
+	net.Conn
+	closeOnce sync.Once
+}
+
+func countConn(conn net.Conn) net.Conn {
+	activeConnections.Inc()
+	return &countedConn{Conn: conn}
+}
+
+func (c *countedConn) Close() error {
+	c.closeOnce.Do(func() { activeConnections.Dec() })
+	return c.Conn.Close()
+}
+
+// Tell a client why it was turned away
+func reject(session *lib.Session, rejection data.Rejection) error {
+	env, err := data.NewEnvelope(data.MSG_REJECT, 0, 0, rejection)
+	if err != nil {
+		return err
+	}
+	return session.Send(env)
+}
+
+// Check a player may register with the given name and Auth (nil if none)
+func (a acceptor) authenticate(name string, auth *data.Auth) error {
+	if a.credentials == nil {
+		return nil
 	}
+	return a.credentials.Check(name, auth)
 }
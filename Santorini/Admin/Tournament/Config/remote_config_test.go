@@ -0,0 +1,341 @@
+package config
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	sandbox "github.com/CS4500-F18/dare-rebr/Santorini/Admin/Sandbox"
+	board "github.com/CS4500-F18/dare-rebr/Santorini/Common/Board"
+	data "github.com/CS4500-F18/dare-rebr/Santorini/Common/JSON"
+	lib "github.com/CS4500-F18/dare-rebr/Santorini/Lib"
+	client "github.com/CS4500-F18/dare-rebr/Santorini/Player/Client"
+	remote "github.com/CS4500-F18/dare-rebr/Santorini/Remote/Player"
+	relay "github.com/CS4500-F18/dare-rebr/Santorini/Remote/Relay"
+)
+
+// Register a client sending the given Registration, returning what the server
+// registered and the message it replied with, if any
+func handshake(t *testing.T, sent data.Registration) (registration, error, data.Envelope) {
+	return handshakeWith(acceptor{timeout: 1000}, sent)
+}
+
+// Register a client with the given acceptor
+func handshakeWith(a acceptor, sent data.Registration) (registration, error, data.Envelope) {
+	server, client := net.Pipe()
+	defer client.Close()
+
+	replies := make(chan data.Envelope, 1)
+	go func() {
+		json.NewEncoder(client).Encode(sent)
+		var env data.Envelope
+		json.NewDecoder(client).Decode(&env)
+		replies <- env
+	}()
+
+	session := lib.NewSession(server)
+	session.SetTimeout(time.Second)
+	reg, err := a.register(session)
+	session.Close()
+	return reg, err, <-replies
+}
+
+func TestRegister_Legacy(t *testing.T) {
+	reg, err, reply := handshake(t, data.Registration{Role: data.PLAYER_ROLE, Name: "fido"})
+	if err != nil || reg.Name != "fido" || reg.Tagged {
+		t.Fatalf("Wrong registration %+v: %v", reg.Registration, err)
+	}
+	if reply.Type != "" {
+		t.Errorf("Legacy client was sent a %s message", reply.Type)
+	}
+}
+
+func TestRegister_Welcome(t *testing.T) {
+	hello := data.NewHello(data.PLAYER_ROLE, "fido")
+	hello.Versions = []int{data.PROTOCOL_VERSION, data.PROTOCOL_VERSION + 1}
+	hello.Features = []string{data.FEATURE_TIME_CONTROL, "teleport"}
+
+	reg, err, reply := handshake(t, data.Registration{Role: data.PLAYER_ROLE, Name: "fido", Tagged: true, Hello: hello})
+	if err != nil || !reg.Tagged || reg.Hello.Software != data.SOFTWARE {
+		t.Fatalf("Wrong registration %+v: %v", reg.Registration, err)
+	}
+
+	var welcome data.Welcome
+	if reply.Type != data.MSG_WELCOME || reply.Decode(&welcome) != nil {
+		t.Fatalf("Expected a welcome, got %+v", reply)
+	}
+	if welcome.Version != data.PROTOCOL_VERSION || len(welcome.Features) != 1 || welcome.TimeLimit != 1000 {
+		t.Errorf("Wrong welcome: %+v", welcome)
+	}
+	if welcome.Has(data.FEATURE_LIFECYCLE) || welcome.Token != "" {
+		t.Error("Granted a feature that wasn't asked for")
+	}
+}
+
+func TestRegister_Reject(t *testing.T) {
+	hello := data.NewHello(data.PLAYER_ROLE, "fido")
+	hello.Variants = []string{"god-powers"}
+
+	_, err, reply := handshake(t, data.Registration{Role: data.PLAYER_ROLE, Name: "fido", Tagged: true, Hello: hello})
+	if _, ok := err.(data.Rejection); !ok {
+		t.Fatalf("Expected a rejection, got %v", err)
+	}
+
+	var rejection data.Rejection
+	if reply.Type != data.MSG_REJECT || reply.Decode(&rejection) != nil || rejection.Reason == "" {
+		t.Errorf("Expected a reject message, got %+v", reply)
+	}
+}
+
+// A player whose connection drops mid-request reconnects, is sent the request
+// again, and answers it
+func TestAcceptor_Resume(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	rule := remote.ForfeitRule{Grace: 5 * time.Second}
+	a := acceptor{timeout: 5000, reconnector: remote.NewReconnector(rule)}
+	registrations := make(chan registration)
+	started := make(chan bool)
+	go a.acceptConnections(NewListener(l), registrations, started)
+
+	host, port, _ := net.SplitHostPort(l.Addr().String())
+	portNum, _ := strconv.Atoi(port)
+	done := make(chan bool, 1)
+	if err := relay.NewPlayerRelay(client.ValidPlayer("fido")).Connect(host, portNum, done); err != nil {
+		t.Fatal(err)
+	}
+
+	reg := <-registrations
+	close(started)
+	reg.conn.Close()
+
+	b := board.BaseBoard()
+	if pos, err := reg.player.PlaceWorker(b); err != nil || !pos.InBounds() {
+		t.Fatalf("No placement after reconnecting: %+v, %v", pos, err)
+	}
+}
+
+// Players connect over TLS, presenting a certificate the server verifies
+func TestAcceptor_TLS(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	serverFiles, _ := lib.GenerateSelfSigned(dir, "server", []string{"127.0.0.1"})
+	clientFiles, err := lib.GenerateSelfSigned(dir, "client", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serverFiles.CA, clientFiles.CA = clientFiles.Cert, serverFiles.Cert
+	serverConfig, err := serverFiles.ServerConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientConfig, err := clientFiles.ClientConfig("127.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l, err := tls.Listen("tcp", "127.0.0.1:0", serverConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	registrations := make(chan registration)
+	started := make(chan bool)
+	defer close(started)
+	go acceptor{timeout: 5000}.acceptConnections(NewListener(l), registrations, started)
+
+	host, port, _ := net.SplitHostPort(l.Addr().String())
+	portNum, _ := strconv.Atoi(port)
+	done := make(chan bool, 1)
+	r := relay.NewPlayerRelay(client.ValidPlayer("fido")).WithTLS(clientConfig)
+	if err := r.Connect(host, portNum, done); err != nil {
+		t.Fatal(err)
+	}
+
+	reg := <-registrations
+	if pos, err := reg.player.PlaceWorker(board.BaseBoard()); err != nil || !pos.InBounds() {
+		t.Fatalf("No placement over TLS: %+v, %v", pos, err)
+	}
+}
+
+func TestAdmission(t *testing.T) {
+	a := newAdmission(1)
+	if err := a.admit(data.PLAYER_ROLE); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.admit(data.PLAYER_ROLE); err == nil {
+		t.Error("Admitted a player past the maximum")
+	}
+	if err := a.admit(data.OBSERVER_ROLE); err != nil {
+		t.Errorf("Observer turned away from a full tournament: %v", err)
+	}
+
+	a.release(data.PLAYER_ROLE)
+	if err := a.admit(data.PLAYER_ROLE); err != nil {
+		t.Errorf("Released place not given out again: %v", err)
+	}
+
+	a.close()
+	if err := a.admit(data.OBSERVER_ROLE); err == nil {
+		t.Error("Admitted an observer after registration closed")
+	}
+}
+
+// Connect to the listener and register as a tagged player, returning the
+// session and the server's reply
+func joinAs(t *testing.T, l net.Listener, name string) (*lib.Session, data.Envelope) {
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	session := lib.NewSession(conn)
+	session.SetTimeout(5 * time.Second)
+
+	hello := data.NewHello(data.PLAYER_ROLE, name)
+	session.Send(data.Registration{Role: data.PLAYER_ROLE, Name: name, Tagged: true, Hello: hello})
+	var reply data.Envelope
+	if err := session.Receive(&reply); err != nil {
+		t.Fatal(err)
+	}
+	return session, reply
+}
+
+// Waiting players hear how registration is going, registration ends as soon
+// as the maximum has joined, and anyone later is turned away
+func TestAcceptFrom_MaxPlayers(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	c := NewRemoteConfig(1, 0, 60, 5000).WithMaxPlayers(2)
+	c.statusInterval = 10 * time.Millisecond
+	accepted := make(chan []sandbox.WrappedPlayer, 1)
+	go func() {
+		players, _ := c.acceptFrom(NewListener(l))
+		accepted <- players
+	}()
+
+	first, reply := joinAs(t, l, "fido")
+	defer first.Close()
+	if reply.Type != data.MSG_WELCOME {
+		t.Fatalf("Expected a welcome, got %+v", reply)
+	}
+	var status data.WaitingRoom
+	if first.Receive(&reply) != nil || reply.Type != data.MSG_WAITING || reply.Decode(&status) != nil {
+		t.Fatalf("Expected the waiting room's status, got %+v", reply)
+	}
+	if status.Players != 1 || status.MinPlayers != 1 || status.MaxPlayers != 2 || status.StartsIn <= 0 {
+		t.Errorf("Wrong status: %+v", status)
+	}
+
+	second, _ := joinAs(t, l, "rex")
+	defer second.Close()
+	select {
+	case players := <-accepted:
+		if len(players) != 2 {
+			t.Errorf("Started with %v players", len(players))
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Didn't start once the maximum had joined")
+	}
+
+	late, reply := joinAs(t, l, "spot")
+	defer late.Close()
+	if reply.Type != data.MSG_REJECT {
+		t.Errorf("Late player was sent a %s", reply.Type)
+	}
+}
+
+// Registration closes at the deadline, even without the minimum players
+func TestAcceptFrom_Deadline(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	c := NewRemoteConfig(2, 0, 1, 5000).WithDeadline(1)
+	accepted := make(chan []sandbox.WrappedPlayer, 1)
+	go func() {
+		players, _ := c.acceptFrom(NewListener(l))
+		accepted <- players
+	}()
+
+	session, _ := joinAs(t, l, "fido")
+	defer session.Close()
+	select {
+	case players := <-accepted:
+		if len(players) != 1 {
+			t.Errorf("Started with %v players", len(players))
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Still registering after the deadline")
+	}
+}
+
+// A listener the config opens itself is closed once registration is
+func TestGenerateComponents_Closes(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := l.Addr().(*net.TCPAddr).Port
+	l.Close()
+
+	NewRemoteConfig(2, port, 1, 5000).WithDeadline(1).GenerateComponents()
+	if conn, err := net.Dial("tcp", l.Addr().String()); err == nil {
+		conn.Close()
+		t.Error("Still listening after registration")
+	}
+}
+
+// A client that connects and never registers doesn't hold up anyone else
+func TestAcceptFrom_SilentClient(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	c := NewRemoteConfig(1, 0, 60, 30000).WithMaxPlayers(1)
+	accepted := make(chan []sandbox.WrappedPlayer, 1)
+	go func() {
+		players, _ := c.acceptFrom(NewListener(l))
+		accepted <- players
+	}()
+
+	silent, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer silent.Close()
+
+	session, reply := joinAs(t, l, "fido")
+	defer session.Close()
+	if reply.Type != data.MSG_WELCOME {
+		t.Fatalf("Expected a welcome, got %+v", reply)
+	}
+	select {
+	case players := <-accepted:
+		if len(players) != 1 {
+			t.Errorf("Started with %v players", len(players))
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Registration was held up by the silent client")
+	}
+}
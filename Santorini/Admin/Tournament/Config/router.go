@@ -0,0 +1,167 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	data "github.com/CS4500-F18/dare-rebr/Santorini/Common/JSON"
+)
+
+// How long a client has to send its registration before the router hangs up
+const ROUTING_TIMEOUT = 10 * time.Second
+
+// Shares one listener between several lobbies, each running tournaments of
+// its own. The router reads each client's registration to find the lobby it
+// asked for in its Hello, then hands the connection, registration and all,
+// to that lobby's Listener, so each lobby registers clients just as it would
+// with the port to itself. Clients that ask for no lobby, including legacy
+// clients and observers, join the default one
+type Router struct {
+	listener *Listener
+	timeout  time.Duration
+
+	//Every lobby, by name, and their names in the order they were added
+	lock    sync.Mutex
+	lobbies map[string]*lobbyListener
+	names   []string
+}
+
+// Route the connections the listener accepts between lobbies, from now on
+func NewRouter(l *Listener) *Router {
+	router := &Router{listener: l, timeout: ROUTING_TIMEOUT, lobbies: make(map[string]*lobbyListener), names: make([]string, 0)}
+	go router.route()
+	return router
+}
+
+// The Listener of the named lobby, adding it if it is new. The first lobby
+// added is the default
+func (r *Router) Lobby(name string) *Listener {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if lobby, ok := r.lobbies[name]; ok {
+		return lobby.shared
+	}
+	lobby := &lobbyListener{addr: r.listener.Addr(), conns: make(chan net.Conn), closed: make(chan bool)}
+	lobby.shared = NewListener(lobby)
+	r.lobbies[name] = lobby
+	r.names = append(r.names, name)
+	return lobby.shared
+}
+
+// The names of every lobby, in the order they were added
+func (r *Router) Lobbies() []string {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return append(make([]string, 0), r.names...)
+}
+
+// Hand every connection to its lobby, until the listener is closed
+func (r *Router) route() {
+	conns, _ := r.listener.takeOver()
+	for {
+		select {
+		case <-r.listener.closed:
+			r.closeLobbies()
+			return
+		case conn := <-conns:
+			go r.dispatch(conn)
+		}
+	}
+}
+
+// Read the client's registration and hand the connection to its lobby, or
+// turn it away if there is no such lobby
+func (r *Router) dispatch(conn net.Conn) {
+	var read bytes.Buffer
+	conn.SetReadDeadline(time.Now().Add(r.timeout))
+	var reg data.Registration
+	err := json.NewDecoder(io.TeeReader(conn, &read)).Decode(&reg)
+	conn.SetReadDeadline(time.Time{})
+	if err != nil {
+		conn.Close()
+		return
+	}
+
+	r.lock.Lock()
+	name := reg.Hello.Lobby
+	if name == "" && len(r.names) > 0 {
+		name = r.names[0]
+	}
+	lobby, ok := r.lobbies[name]
+	r.lock.Unlock()
+
+	if !ok {
+		if reg.Tagged {
+			rejection := data.Rejection{Reason: fmt.Sprintf("no lobby named %s", name)}
+			if env, err := data.NewEnvelope(data.MSG_REJECT, 0, 0, rejection); err == nil {
+				json.NewEncoder(conn).Encode(env)
+			}
+		}
+		conn.Close()
+		return
+	}
+
+	// The lobby reads the registration again, along with anything after it
+	replayed := &replayedConn{Conn: conn, reader: io.MultiReader(&read, conn)}
+	select {
+	case lobby.conns <- replayed:
+	case <-lobby.closed:
+		conn.Close()
+	}
+}
+
+func (r *Router) closeLobbies() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	for _, lobby := range r.lobbies {
+		lobby.shared.Close()
+	}
+}
+
+// A connection whose first bytes, already read, are read again
+type replayedConn struct {
+	net.Conn
+	reader io.Reader
+}
+
+func (c *replayedConn) Read(p []byte) (int, error) {
+	return c.reader.Read(p)
+}
+
+// The connections routed to one lobby, as a net.Listener
+type lobbyListener struct {
+	addr   net.Addr
+	conns  chan net.Conn
+	closed chan bool
+
+	closeOnce sync.Once
+
+	// The Listener the lobby's tournaments share
+	shared *Listener
+}
+
+func (l *lobbyListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case <-l.closed:
+		return nil, net.ErrClosed
+	}
+}
+
+func (l *lobbyListener) Close() error {
+	l.closeOnce.Do(func() {
+		close(l.closed)
+	})
+	return nil
+}
+
+func (l *lobbyListener) Addr() net.Addr {
+	return l.addr
+}
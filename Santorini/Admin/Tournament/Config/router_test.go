@@ -0,0 +1,77 @@
+package config
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	sandbox "github.com/CS4500-F18/dare-rebr/Santorini/Admin/Sandbox"
+	data "github.com/CS4500-F18/dare-rebr/Santorini/Common/JSON"
+	lib "github.com/CS4500-F18/dare-rebr/Santorini/Lib"
+)
+
+// Register a player asking for the given lobby, returning the reply
+func joinLobby(t *testing.T, l net.Listener, name, lobby string) data.Envelope {
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	session := lib.NewSession(conn)
+	session.SetTimeout(5 * time.Second)
+
+	hello := data.NewHello(data.PLAYER_ROLE, name)
+	hello.Lobby = lobby
+	session.Send(data.Registration{Role: data.PLAYER_ROLE, Name: name, Tagged: true, Hello: hello})
+	var reply data.Envelope
+	if err := session.Receive(&reply); err != nil {
+		t.Fatal(err)
+	}
+	return reply
+}
+
+// Each client registers in the lobby it asks for, or the first if it asks
+// for none, and is turned away if there is no such lobby
+func TestRouter(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	listener := NewListener(l)
+	defer listener.Close()
+
+	router := NewRouter(listener)
+	accepted := make(map[string]chan []sandbox.WrappedPlayer)
+	for _, lobby := range []string{"main", "blitz"} {
+		accepted[lobby] = make(chan []sandbox.WrappedPlayer, 1)
+		c := NewRemoteConfig(1, 0, 60, 5000).WithMaxPlayers(1).WithLobby(lobby)
+		go func(lobby string, l *Listener) {
+			players, _ := c.acceptFrom(l)
+			accepted[lobby] <- players
+		}(lobby, router.Lobby(lobby))
+	}
+	if lobbies := router.Lobbies(); len(lobbies) != 2 || lobbies[0] != "main" {
+		t.Errorf("Wrong lobbies %v", lobbies)
+	}
+
+	for _, join := range []struct{ name, asked, lobby string }{{"fido", "blitz", "blitz"}, {"rex", "", "main"}} {
+		var welcome data.Welcome
+		if reply := joinLobby(t, l, join.name, join.asked); reply.Type != data.MSG_WELCOME || reply.Decode(&welcome) != nil {
+			t.Fatalf("Expected a welcome for %s, got %+v", join.name, reply)
+		} else if welcome.Lobby != join.lobby {
+			t.Errorf("%s joined %s, not %s", join.name, welcome.Lobby, join.lobby)
+		}
+
+		select {
+		case players := <-accepted[join.lobby]:
+			if name, _ := players[0].Name(); len(players) != 1 || name != join.name {
+				t.Errorf("Wrong players in %s", join.lobby)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%s never registered in %s", join.name, join.lobby)
+		}
+	}
+
+	if reply := joinLobby(t, l, "spot", "bullet"); reply.Type != data.MSG_REJECT {
+		t.Errorf("Expected a rejection from a lobby that isn't there, got %+v", reply)
+	}
+}
@@ -1,13 +1,20 @@
 package config
 
 import (
+	"crypto/tls"
 	"encoding/json"
 	"fmt"
+	"io"
+	"log"
 	"os"
+	"strings"
 
 	sandbox "github.com/CS4500-F18/dare-rebr/Santorini/Admin/Sandbox"
+	data "github.com/CS4500-F18/dare-rebr/Santorini/Common/JSON"
 	iplayer "github.com/CS4500-F18/dare-rebr/Santorini/Common/Player"
+	lib "github.com/CS4500-F18/dare-rebr/Santorini/Lib"
 	obs "github.com/CS4500-F18/dare-rebr/Santorini/Observer"
+	web "github.com/CS4500-F18/dare-rebr/Santorini/Observer/Web"
 	client "github.com/CS4500-F18/dare-rebr/Santorini/Player/Client"
 	remote "github.com/CS4500-F18/dare-rebr/Santorini/Remote/Relay"
 )
@@ -17,6 +24,18 @@ const (
 	VALID    = "good"
 	BROKEN   = "breaker"
 	INFINITE = "infinite"
+	HUMAN    = "human"
+	ENGINE   = "engine"
+)
+
+// Observer Locations for writing JSON to STDOUT, drawing boards on STDOUT
+// (stepping on commands typed on STDIN, or on the file after the prefix), and
+// serving a spectator web page
+const (
+	STDOUT          = "-"
+	TERMINAL        = "terminal"
+	TERMINAL_PREFIX = "terminal:"
+	HTTP_PREFIX     = "http://"
 )
 
 // TourneyConfiguration for a Tournament
@@ -25,15 +44,37 @@ type StaticConfig struct {
 	Observers []StaticObserver `json:"observers"`
 	IP        string           `json:"ip"`
 	Port      int              `json:"port"`
+
+	// Speak the untagged protocol, for servers that predate Envelopes
+	Legacy bool `json:"legacy"`
+
+	// How each named player authenticates with the server, if at all, e.g.
+	// {"fido": {"token": "..."}} or {"fido": {"password": "..."}}
+	Credentials map[string]data.Auth `json:"credentials"`
+
+	// Connect over TLS, trusting the server certificate in "ca" and presenting
+	// the client certificate in "cert" and "key" if the server asks for one
+	TLS *lib.TLSFiles `json:"tls"`
+
+	// Directory to record a transcript of each player's connection in, to
+	// replay when debugging (optional)
+	Transcripts string `json:"transcripts"`
+
+	// The lobby every player joins, on a server hosting several (optional)
+	Lobby string `json:"lobby"`
+
+	// How many more times each player tries to connect if the server isn't
+	// there yet, backing off between attempts (optional)
+	Retries int `json:"retries"`
+
+	// Play every player over one connection to the server, rather than a
+	// connection each (optional)
+	Multiplex bool `json:"multiplex"`
 }
 
 // Create Tournament-usable pieces from a TourneyConfiguration
 func (c StaticConfig) GenerateComponents() ([]sandbox.WrappedPlayer, []obs.IObserver) {
-	players := make([]sandbox.WrappedPlayer, 0)
-	for _, p := range c.Players {
-		players = append(players, sandbox.NewTimeoutPlayer(sandbox.TIMEOUT_DEFAULT, c.playerFromSpec(p)))
-
-	}
+	players := c.FirstPlayers(len(c.Players))
 
 	observers := make([]obs.IObserver, 0)
 	for _, o := range c.Observers {
@@ -43,42 +84,204 @@ func (c StaticConfig) GenerateComponents() ([]sandbox.WrappedPlayer, []obs.IObse
 	return players, observers
 }
 
+// The first players in this config, up to the given count, built one at a
+// time so no more engines are started than are needed
+func (c StaticConfig) FirstPlayers(count int) []sandbox.WrappedPlayer {
+	players := make([]sandbox.WrappedPlayer, 0)
+	for _, p := range c.Players {
+		if len(players) >= count {
+			break
+		}
+		player, err := c.playerFromSpec(p)
+		if err != nil {
+			// Better to play without a player that can't be built than not at all
+			log.Printf("config: leaving out %s: %v", p.Name, err)
+		} else if p.Kind == HUMAN {
+			// People take as long as they take
+			players = append(players, sandbox.NewNormalPlayer(player))
+		} else {
+			players = append(players, sandbox.NewTimeoutPlayer(sandbox.TIMEOUT_DEFAULT, player))
+		}
+	}
+	return players
+}
+
 // ClientRelays returns each Player in this config in its own remote relay,
-// alongside specified observers
-func (c StaticConfig) ClientRelays() ([]remote.IRelay, []obs.IObserver) {
+// alongside each specified observer in its own remote relay. Returns an error
+// if the TLS files can't be loaded or a player can't be built
+func (c StaticConfig) ClientRelays() ([]remote.IRelay, []remote.ObserverRelay, error) {
+	players, observers, err := c.relays()
+	if err != nil {
+		return nil, nil, err
+	}
+
 	relays := make([]remote.IRelay, 0)
+	for _, relay := range players {
+		relays = append(relays, relay)
+	}
+	return relays, observers, nil
+}
+
+// Clients returns a Client playing each Player in this config, alongside each
+// specified observer in its own remote relay. Returns an error if the TLS
+// files can't be loaded or a player can't be built
+func (c StaticConfig) Clients() ([]remote.Client, []remote.ObserverRelay, error) {
+	players, observers, err := c.relays()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	clients := make([]remote.Client, 0)
+	for _, relay := range players {
+		clients = append(clients, relay.Client())
+	}
+	return clients, observers, nil
+}
+
+// Multiplexer returns a Multiplexer playing the given Clients over one
+// connection to the server, connecting as this config says to. Returns an
+// error if the TLS files can't be loaded
+func (c StaticConfig) Multiplexer(clients []remote.Client) (remote.Multiplexer, error) {
+	config, err := c.tlsConfig()
+	if err != nil {
+		return remote.Multiplexer{}, err
+	}
+	mux := remote.NewMultiplexer(clients...).WithTLS(config).WithTranscripts(c.Transcripts).WithLobby(c.Lobby)
+	return mux.WithRetry(1+c.Retries, remote.CONNECT_BACKOFF), nil
+}
+
+// The TLS settings to connect with, or nil for plain TCP
+func (c StaticConfig) tlsConfig() (*tls.Config, error) {
+	if c.TLS == nil {
+		return nil, nil
+	}
+	return c.TLS.ClientConfig(c.IP)
+}
+
+// The remote relays of every player and observer in this config
+func (c StaticConfig) relays() ([]remote.PlayerRelay, []remote.ObserverRelay, error) {
+	config, err := c.tlsConfig()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	relays := make([]remote.PlayerRelay, 0)
+	built := make([]iplayer.IPlayer, 0)
 	for _, p := range c.Players {
-		player := c.playerFromSpec(p)
-		relays = append(relays, remote.NewPlayerRelay(player))
+		player, err := c.playerFromSpec(p)
+		if err != nil {
+			// Stop any engines already started
+			for _, player := range built {
+				if closer, ok := player.(io.Closer); ok {
+					closer.Close()
+				}
+			}
+			return nil, nil, err
+		}
+		built = append(built, player)
+		relay := remote.NewPlayerRelay(player)
+		if c.Legacy {
+			relay = remote.NewLegacyPlayerRelay(player)
+		} else if auth, found := c.Credentials[p.Name]; found {
+			relay = relay.WithAuth(auth)
+		}
+		relay = relay.WithTLS(config).WithTranscripts(c.Transcripts).WithLobby(c.Lobby)
+		relays = append(relays, relay.WithRetry(1+c.Retries, remote.CONNECT_BACKOFF))
 	}
 
-	observers := make([]obs.IObserver, 0)
+	observers := make([]remote.ObserverRelay, 0)
 	for _, o := range c.Observers {
-		observers = append(observers, c.observerFromSpec(o))
+		observers = append(observers, remote.NewObserverRelay(c.observerFromSpec(o)).WithTLS(config))
 	}
 
-	return relays, observers
+	return relays, observers, nil
 }
 
-// Return a Player from the given Player JSON
-func (c StaticConfig) playerFromSpec(p StaticPlayer) iplayer.IPlayer {
+// Return a Player from the given Player JSON, or an error if it is an engine
+// that won't start or of no kind we know
+func (c StaticConfig) playerFromSpec(p StaticPlayer) (iplayer.IPlayer, error) {
 	switch p.Kind {
 	case VALID:
-		return client.ValidPlayer(p.Name)
+		return client.ValidPlayer(p.Name), nil
 
 	case BROKEN:
-		return client.BrokenPlayer(p.Name)
+		return client.BrokenPlayer(p.Name), nil
 
 	case INFINITE:
-		return client.InfinitePlacementPlayer(p.Name)
+		return client.InfinitePlacementPlayer(p.Name), nil
+
+	case HUMAN:
+		in, err := humanInput(p.Location)
+		if err != nil {
+			return nil, err
+		}
+		return client.HumanPlayer(p.Name, in, os.Stdout), nil
+
+	case ENGINE:
+		return client.EnginePlayer(p.Name, p.Location, client.ENGINE_TIMEOUT)
 	}
 
-	return nil
+	return nil, fmt.Errorf("%s is of unknown kind %q", p.Name, p.Kind)
+}
+
+// The input a human player types on: the file (or terminal device, such as
+// /dev/tty) at the given Location, or STDIN if there is none. A file that
+// can't be opened is an error, rather than STDIN, where someone else may type
+func humanInput(location string) (io.Reader, error) {
+	if location == "" {
+		return os.Stdin, nil
+	}
+	file, err := os.Open(location)
+	if err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+// STDIN, or nil if a human player is already typing on it
+func (c StaticConfig) stdinUnlessHuman() io.Reader {
+	for _, p := range c.Players {
+		if p.Kind == HUMAN && p.Location == "" {
+			return nil
+		}
+	}
+	return os.Stdin
 }
 
 // Return an Observer from the given Observer JSON
+// An "http://host:port" Location serves a spectator web page on that address,
+// "terminal" draws each board on STDOUT, and any other Location is a file to
+// write JSON to. The Observer writes to STDOUT
+// if it has no Location (or if the page/file cannot be set up)
+// "terminal" pauses and steps on what is typed on STDIN (unless a human player
+// types there), and "terminal:/dev/tty" on the given file or device instead
 func (c StaticConfig) observerFromSpec(o StaticObserver) obs.IObserver {
-	return obs.NewJSONObserver(o.Name, os.Stdout)
+	if o.Location == "" || o.Location == STDOUT {
+		return obs.NewJSONObserver(o.Name, os.Stdout)
+	}
+
+	if o.Location == TERMINAL {
+		return obs.NewTerminalObserver(o.Name, os.Stdout, c.stdinUnlessHuman())
+	}
+	if strings.HasPrefix(o.Location, TERMINAL_PREFIX) {
+		// Without its input, it just can't step
+		in, _ := humanInput(strings.TrimPrefix(o.Location, TERMINAL_PREFIX))
+		return obs.NewTerminalObserver(o.Name, os.Stdout, in)
+	}
+
+	if strings.HasPrefix(o.Location, HTTP_PREFIX) {
+		spectator, err := web.NewSpectator(o.Name, strings.TrimPrefix(o.Location, HTTP_PREFIX))
+		if err != nil {
+			return obs.NewJSONObserver(o.Name, os.Stdout)
+		}
+		return spectator
+	}
+
+	file, err := os.OpenFile(o.Location, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return obs.NewJSONObserver(o.Name, os.Stdout)
+	}
+	return obs.NewJSONObserver(o.Name, file)
 }
 
 // Player JSON
@@ -0,0 +1,44 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	obs "github.com/CS4500-F18/dare-rebr/Santorini/Observer"
+)
+
+// A terminal observer steps on STDIN, unless a human player types there
+func TestObserverFromSpec_Terminal(t *testing.T) {
+	c := StaticConfig{Players: []StaticPlayer{{Kind: VALID, Name: "fido"}}}
+	if _, ok := c.observerFromSpec(StaticObserver{Name: "rex", Location: TERMINAL}).(*obs.TerminalObserver); !ok {
+		t.Error("Expected a terminal observer")
+	}
+	if c.stdinUnlessHuman() != os.Stdin {
+		t.Error("Terminal observer should step on STDIN")
+	}
+
+	c.Players = append(c.Players, StaticPlayer{Kind: HUMAN, Name: "spot"})
+	if c.stdinUnlessHuman() != nil {
+		t.Error("Terminal observer shouldn't read what the human types")
+	}
+	c.Players[1].Location = "/dev/tty"
+	if c.stdinUnlessHuman() != os.Stdin {
+		t.Error("Human typing elsewhere should leave STDIN to the observer")
+	}
+}
+
+// A player of a kind we don't know is an error, not a missing player
+func TestPlayerFromSpec_UnknownKind(t *testing.T) {
+	if player, err := (StaticConfig{}).playerFromSpec(StaticPlayer{Kind: "wizard", Name: "fido"}); err == nil || player != nil {
+		t.Errorf("Built a wizard: %v", player)
+	}
+}
+
+// A human whose input can't be opened isn't left typing on STDIN
+func TestPlayerFromSpec_HumanInput(t *testing.T) {
+	missing := StaticPlayer{Kind: HUMAN, Name: "spot", Location: filepath.Join(t.TempDir(), "missing")}
+	if player, err := (StaticConfig{}).playerFromSpec(missing); err == nil || player != nil {
+		t.Errorf("Built a human without their input: %v", player)
+	}
+}
@@ -0,0 +1,53 @@
+package tournament
+
+import (
+	"log"
+
+	archive "github.com/CS4500-F18/dare-rebr/Santorini/Admin/Archive"
+	result "github.com/CS4500-F18/dare-rebr/Santorini/Common/Tournament"
+)
+
+//Archive the tournament, every series and game in it, and how it finished
+func (m *manager) WithArchive(a *archive.Archive) *manager {
+	m.archive = a
+	return m
+}
+
+//Begin archiving the tournament, unless it was archived before it was resumed.
+//A tournament that can't be archived is still played, so failures to archive
+//are only logged
+func (m *manager) beginArchive() {
+	if m.archive == nil || m.archived != 0 {
+		return
+	}
+	m.lock.Lock()
+	players := userNames(append(append(make([]user, 0), m.Users...), m.Excluded...))
+	m.lock.Unlock()
+
+	id, err := m.archive.BeginTournament(players)
+	if err != nil {
+		log.Printf("tournament: can't archive tournament: %v", err)
+		return
+	}
+	m.archived = id
+}
+
+//Archive a series just played, holding the lock
+func (m *manager) archiveSeries(series archive.Series, games []archive.Game) {
+	if m.archive == nil || m.archived == 0 {
+		return
+	}
+	if err := m.archive.RecordSeries(series, games); err != nil {
+		log.Printf("tournament: can't archive series: %v", err)
+	}
+}
+
+//Archive how the tournament finished
+func (m *manager) finishArchive(final result.TournamentResult) {
+	if m.archive == nil || m.archived == 0 {
+		return
+	}
+	if err := m.archive.FinishTournament(m.archived, final); err != nil {
+		log.Printf("tournament: can't archive results: %v", err)
+	}
+}
@@ -0,0 +1,191 @@
+package tournament
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	rules "github.com/CS4500-F18/dare-rebr/Santorini/Common/Rules"
+	result "github.com/CS4500-F18/dare-rebr/Santorini/Common/Tournament"
+)
+
+//How far a tournament got, written after every series so a server that dies
+//can pick up where it left off
+type Checkpoint struct {
+	Saved time.Time `json:"saved"`
+
+	//Games per series
+	Games int `json:"games"`
+
+	//Players still in the tournament, and those kicked out
+	Players  []string `json:"players"`
+	Excluded []string `json:"excluded"`
+
+	//Every series played so far
+	Matches []CheckpointMatch `json:"matches"`
+
+	//The series still to play, as pairs of names
+	Remaining [][2]string `json:"remaining"`
+
+	//The tournament's ID in the archive, or 0 if it isn't archived
+	Archived int `json:"archived"`
+}
+
+//A completed series, with everything a MatchResult holds
+type CheckpointMatch struct {
+	Winner    string             `json:"winner"`
+	Loser     string             `json:"loser"`
+	Irregular bool               `json:"irregular"`
+	Games     []rules.GameResult `json:"games"`
+}
+
+//Read the checkpoint at the given path. Returns nil and no error if there is
+//none, as after a tournament that finished
+func LoadCheckpoint(path string) (*Checkpoint, error) {
+	buf, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var checkpoint Checkpoint
+	if err := json.Unmarshal(buf, &checkpoint); err != nil {
+		return nil, err
+	}
+	return &checkpoint, nil
+}
+
+//Write a checkpoint after every series to the given path, and remove it once
+//the tournament finishes. An aborted tournament keeps its checkpoint
+func (m *manager) WithCheckpoint(path string) *manager {
+	m.checkpointPath = path
+	return m
+}
+
+//Pick the tournament up from the given checkpoint, rather than starting a new
+//one. Players must register again under the names they had: those who don't
+//forfeit as if they had broken a rule, and anyone new only gets the results
+func (m *manager) Resume(checkpoint *Checkpoint) *manager {
+	m.resumed = checkpoint
+	return m
+}
+
+//Take over the state in the checkpoint from the players who registered again
+func (m *manager) restore(checkpoint *Checkpoint) []UserPair {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.gamesPerRound = checkpoint.Games
+	m.archived = checkpoint.Archived
+	for _, match := range checkpoint.Matches {
+		m.Matches = append(m.Matches, result.NewMatchResult(match.Winner, match.Loser, match.Irregular, match.Games))
+	}
+	for _, name := range checkpoint.Excluded {
+		m.Excluded = append(m.Excluded, user{Name: name})
+	}
+
+	registered := make(map[string]user)
+	for _, u := range m.Users {
+		registered[u.Name] = u
+	}
+	m.Users = make([]user, 0)
+	for _, name := range checkpoint.Players {
+		if u, ok := registered[name]; ok {
+			m.Users = append(m.Users, u)
+			delete(registered, name)
+		} else {
+			// Already gone, so they can't be in any Users
+			m.Users = append(m.Users, user{Name: name})
+			m.handleCheater(user{Name: name})
+		}
+	}
+	for _, u := range registered {
+		m.latecomers = append(m.latecomers, u)
+	}
+
+	pairs := make([]UserPair, 0)
+	for _, names := range checkpoint.Remaining {
+		pairs = append(pairs, UserPair{m.user(names[0]), m.user(names[1])})
+	}
+	return pairs
+}
+
+//The user with the given name, in or out of the tournament
+func (m *manager) user(name string) user {
+	for _, u := range append(append(make([]user, 0), m.Users...), m.Excluded...) {
+		if u.Name == name {
+			return u
+		}
+	}
+	return user{Name: name}
+}
+
+//Write the tournament's state to the checkpoint, with the given series still
+//to play, if there is a checkpoint
+func (m *manager) saveCheckpoint(remaining []UserPair) error {
+	if m.checkpointPath == "" {
+		return nil
+	}
+
+	m.lock.Lock()
+	checkpoint := Checkpoint{
+		Saved:     time.Now(),
+		Games:     m.gamesPerRound,
+		Players:   userNames(m.Users),
+		Excluded:  userNames(m.Excluded),
+		Matches:   make([]CheckpointMatch, 0),
+		Remaining: make([][2]string, 0),
+		Archived:  m.archived,
+	}
+	for _, match := range m.Matches {
+		checkpoint.Matches = append(checkpoint.Matches, CheckpointMatch{match.Winner, match.Loser, match.RuleBroken, match.GameResults})
+	}
+	m.lock.Unlock()
+
+	for _, pair := range remaining {
+		checkpoint.Remaining = append(checkpoint.Remaining, [2]string{pair.UserA.Name, pair.UserB.Name})
+	}
+
+	buf, err := json.Marshal(checkpoint)
+	if err != nil {
+		return err
+	}
+	return writeDurably(m.checkpointPath, buf)
+}
+
+//Remove the checkpoint, once the tournament is over
+func (m *manager) clearCheckpoint() error {
+	if m.checkpointPath == "" {
+		return nil
+	}
+	if err := os.Remove(m.checkpointPath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+//Replace the file at the path with the bytes, so that a crash leaves either
+//the old file or the new one, never half of either
+func writeDurably(path string, buf []byte) error {
+	temp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temp.Name())
+
+	if _, err := temp.Write(buf); err != nil {
+		temp.Close()
+		return err
+	}
+	if err := temp.Sync(); err != nil {
+		temp.Close()
+		return err
+	}
+	if err := temp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(temp.Name(), path)
+}
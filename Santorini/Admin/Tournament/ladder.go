@@ -0,0 +1,709 @@
+package tournament
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	archive "github.com/CS4500-F18/dare-rebr/Santorini/Admin/Archive"
+	ref "github.com/CS4500-F18/dare-rebr/Santorini/Admin/Referee"
+	sandbox "github.com/CS4500-F18/dare-rebr/Santorini/Admin/Sandbox"
+	board "github.com/CS4500-F18/dare-rebr/Santorini/Common/Board"
+	output "github.com/CS4500-F18/dare-rebr/Santorini/Common/JSON"
+	rules "github.com/CS4500-F18/dare-rebr/Santorini/Common/Rules"
+	result "github.com/CS4500-F18/dare-rebr/Santorini/Common/Tournament"
+	lib "github.com/CS4500-F18/dare-rebr/Santorini/Lib"
+	obs "github.com/CS4500-F18/dare-rebr/Santorini/Observer"
+)
+
+/*
+  A Ladder runs for as long as the server does, rather than one round robin:
+    - players join at any time, and stay until they leave or are kicked
+    - idle players are paired as soon as there are two, and every pair plays
+      a series at once, each with its own Referee
+    - each series won or lost moves the players' Elo ratings, and the
+      standings are kept best rated first
+    - a player who breaks a rule (or whose connection drops) loses the
+      series, without it moving either rating, and leaves the ladder, and
+      neither they nor anyone kicked can come back under the same name
+    - idle players are sent a heartbeat, and leave once it can't reach them
+  Players who leave are sent the results so far, and everyone still on the
+  ladder is sent them when it is stopped
+*/
+
+// Every player's rating on joining the ladder for the first time
+const INITIAL_RATING = 1500
+
+// How far a single series can move a rating
+const RATING_K = 32
+
+// How often idle players are sent a heartbeat, to notice those who have gone
+const HEARTBEAT_INTERVAL = 2 * time.Second
+
+// An idle player, as a Pairing sees them
+type Candidate struct {
+	Name   string
+	Rating int
+
+	//When they last finished a series, or joined
+	IdleSince time.Time
+
+	//Who they last played, or ""
+	LastOpponent string
+}
+
+// Chooses which two of the idle players, longest idle first, play next.
+// There are always at least two
+type Pairing func(idle []Candidate) (int, int)
+
+// Pair the player idle longest with whoever is closest to their rating, but
+// not with their last opponent when there's anyone else
+func ByRating(idle []Candidate) (int, int) {
+	best := -1
+	for i := 1; i < len(idle); i++ {
+		if best == -1 || closer(idle[0], idle[i], idle[best]) {
+			best = i
+		}
+	}
+	return 0, best
+}
+
+// Whether a is a better opponent for the player than b
+func closer(player, a, b Candidate) bool {
+	rematchA, rematchB := player.LastOpponent == a.Name, player.LastOpponent == b.Name
+	if rematchA != rematchB {
+		return rematchB
+	}
+	return abs(player.Rating-a.Rating) < abs(player.Rating-b.Rating)
+}
+
+// Pair the two players idle longest, but not for a rematch when there's
+// anyone else
+func LeastRecent(idle []Candidate) (int, int) {
+	for i := 1; i < len(idle); i++ {
+		if idle[0].LastOpponent != idle[i].Name {
+			return 0, i
+		}
+	}
+	return 0, 1
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+// A player on the ladder, and their record
+type rung struct {
+	user
+	rating    float64
+	wins      int
+	losses    int
+	idleSince time.Time
+	opponent  string
+	playing   bool
+	onLadder  bool
+}
+
+// A series that has been played, for the ladder to take in
+type played struct {
+	a, b     *rung
+	games    []rules.GameResult
+	live     *liveSeries
+	recorder *archive.Recorder
+}
+
+// The ladder keeps its players, their records, and the series being played
+type ladder struct {
+	//How many games to play in each series
+	gamesPerRound int
+
+	//How idle players are paired
+	pairing Pairing
+
+	//Names no player may be renamed to, as they belong to authenticated players
+	reserved map[string]bool
+
+	//Guards everything below against readers outside the ladder
+	lock sync.Mutex
+
+	//Everyone who has ever joined, by name, and the names of those on the
+	//ladder in the order they joined
+	records map[string]*rung
+	players []string
+
+	//Completed series, and those who left after breaking a rule or being kicked
+	Matches  []result.MatchResult
+	Excluded []user
+
+	Observers []obs.IObserver
+
+	//Whether the ladder is running, and whether it has been stopped
+	started bool
+	aborted bool
+
+	//Names of the players to kick off once they are idle, and of those who
+	//broke a rule or were kicked, who may not come back
+	kicks  map[string]bool
+	barred map[string]bool
+
+	//How often idle players are sent a heartbeat
+	heartbeat time.Duration
+
+	//The series being played
+	live map[*liveSeries]bool
+
+	//Told when there may be something to do: a kick, or stopping
+	wake chan bool
+
+	//Where every series and game is archived, or nil, and the ladder's ID there
+	archive  *archive.Archive
+	archived int
+}
+
+// Return a new ladder playing the given number of games a series, pairing
+// players by rating
+func NewLadder(games int) *ladder {
+	return &ladder{
+		gamesPerRound: games,
+		pairing:       ByRating,
+		reserved:      make(map[string]bool),
+		records:       make(map[string]*rung),
+		players:       make([]string, 0),
+		Matches:       make([]result.MatchResult, 0),
+		Excluded:      make([]user, 0),
+		Observers:     make([]obs.IObserver, 0),
+		kicks:         make(map[string]bool),
+		barred:        make(map[string]bool),
+		heartbeat:     HEARTBEAT_INTERVAL,
+		live:          make(map[*liveSeries]bool),
+		wake:          make(chan bool, 1),
+	}
+}
+
+// Pair idle players with the given Pairing instead
+func (l *ladder) WithPairing(pairing Pairing) *ladder {
+	l.pairing = pairing
+	return l
+}
+
+// Archive the ladder as a tournament, with every series and game played on it
+func (l *ladder) WithArchive(a *archive.Archive) *ladder {
+	l.archive = a
+	return l
+}
+
+// Never rename a player to any of the given names
+func (l *ladder) WithReservedNames(reserved map[string]bool) *ladder {
+	l.reserved = reserved
+	return l
+}
+
+// Run the ladder with the players and observers from the channels, as they
+// arrive, until it is stopped. Returns every series played
+func (l *ladder) Run(players <-chan sandbox.WrappedPlayer, observers <-chan obs.IObserver) result.TournamentResult {
+	l.lock.Lock()
+	l.started = true
+	l.lock.Unlock()
+	l.beginArchive()
+
+	done := make(chan played)
+	gone, stopped := make(chan *rung), make(chan bool)
+	defer close(stopped)
+	heartbeat := time.NewTicker(l.heartbeat)
+	defer heartbeat.Stop()
+
+	running := 0
+	for {
+		l.applyKicks()
+		if l.Aborted() {
+			if running == 0 {
+				break
+			}
+		} else {
+			running += l.pairIdle(done)
+		}
+
+		select {
+		case player, ok := <-players:
+			if !ok {
+				players = nil
+				continue
+			}
+			l.join(player)
+
+		case observer, ok := <-observers:
+			if !ok {
+				observers = nil
+				continue
+			}
+			l.lock.Lock()
+			l.Observers = append(l.Observers, newSharedObserver(observer))
+			l.lock.Unlock()
+
+		case series := <-done:
+			running--
+			l.finish(series)
+
+		case <-heartbeat.C:
+			l.probeIdle(gone, stopped)
+
+		case r := <-gone:
+			l.drop(r)
+
+		case <-l.wake:
+		}
+	}
+
+	final := l.results()
+	l.finishArchive(final)
+
+	l.lock.Lock()
+	remaining := make([]user, 0)
+	for _, name := range l.players {
+		remaining = append(remaining, l.records[name].user)
+	}
+	watching := l.Observers
+	l.lock.Unlock()
+
+	for _, u := range remaining {
+		u.Conn.ReceiveTournamentResult(final)
+	}
+	for _, observer := range watching {
+		if closer, ok := observer.(io.Closer); ok {
+			closer.Close()
+		}
+	}
+	return final
+}
+
+// Stop pairing players, and stop the ladder once the series being played are
+// over. Everyone still on it is sent the results
+func (l *ladder) Abort() {
+	l.lock.Lock()
+	l.aborted = true
+	l.lock.Unlock()
+	l.poke()
+}
+
+// Whether the ladder has been stopped
+func (l *ladder) Aborted() bool {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.aborted
+}
+
+// Whether the ladder is running
+func (l *ladder) Started() bool {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.started
+}
+
+// Kick the named player off the ladder, once their series is over if they
+// are playing one
+func (l *ladder) Kick(name string) error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if r, ok := l.records[name]; !ok || !r.onLadder {
+		return fmt.Errorf("No player named %s", name)
+	}
+	l.kicks[name] = true
+	l.poke()
+	return nil
+}
+
+// Tell the ladder there may be something to do, without waiting
+func (l *ladder) poke() {
+	select {
+	case l.wake <- true:
+	default:
+	}
+}
+
+// The ladder's state, safe to call while it runs. The standings are the
+// leaderboard, best rated first
+func (l *ladder) Snapshot() Snapshot {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	snapshot := Snapshot{
+		Started:   l.started,
+		Aborted:   l.aborted,
+		Players:   append(make([]string, 0), l.players...),
+		Kicked:    l.kicked(),
+		Standings: l.leaderboard(),
+		Games:     make([]LiveGame, 0),
+	}
+	for live := range l.live {
+		snapshot.Games = append(snapshot.Games, live.snapshot())
+	}
+	sort.Slice(snapshot.Games, func(i, j int) bool {
+		return snapshot.Games[i].Players[0] < snapshot.Games[j].Players[0]
+	})
+	return snapshot
+}
+
+// Everyone who has played on the ladder, best rated first, holding the lock
+func (l *ladder) leaderboard() []Standing {
+	list := make([]Standing, 0)
+	for _, r := range l.records {
+		list = append(list, Standing{Name: r.Name, Wins: r.wins, Losses: r.losses, Rating: int(math.Round(r.rating))})
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Rating != list[j].Rating {
+			return list[i].Rating > list[j].Rating
+		}
+		return list[i].Name < list[j].Name
+	})
+	return list
+}
+
+// Put a new player on the ladder, renaming them if their name is invalid or
+// taken by someone on it. A player who was on it before, under the same name,
+// picks up their record where they left it, unless they broke a rule or were
+// kicked: they are only sent the results so far
+func (l *ladder) join(player sandbox.WrappedPlayer) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if originalName, _ := player.Name(); l.barred[strings.ToLower(originalName)] {
+		soFar := l.resultsHeld()
+		go player.ReceiveTournamentResult(soFar)
+		return
+	}
+
+	taken := func(name string) bool {
+		r, ok := l.records[name]
+		return ok && r.onLadder
+	}
+	originalName, _ := player.Name()
+	name := strings.ToLower(originalName)
+	if name == "" || !lib.IsLowercase(name) || taken(name) {
+		name = substituteName(func(name string) bool {
+			return l.reserved[name] || taken(name)
+		})
+		player.SetName(name)
+	}
+
+	r, ok := l.records[name]
+	if !ok {
+		r = &rung{rating: INITIAL_RATING}
+		l.records[name] = r
+	}
+	r.user = NewUser(name, player)
+	r.idleSince = time.Now()
+	r.onLadder, r.playing = true, false
+	l.players = append(l.players, name)
+}
+
+// Start a series between every pair of idle players the Pairing chooses,
+// returning how many were started
+func (l *ladder) pairIdle(done chan<- played) int {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	idle := make([]*rung, 0)
+	for _, name := range l.players {
+		if r := l.records[name]; !r.playing {
+			idle = append(idle, r)
+		}
+	}
+	sort.SliceStable(idle, func(i, j int) bool { return idle[i].idleSince.Before(idle[j].idleSince) })
+
+	started := 0
+	for len(idle) >= 2 {
+		candidates := make([]Candidate, 0)
+		for _, r := range idle {
+			candidates = append(candidates, Candidate{r.Name, int(math.Round(r.rating)), r.idleSince, r.opponent})
+		}
+		i, j := l.pairing(candidates)
+		a, b := idle[i], idle[j]
+		a.playing, b.playing = true, true
+		idle = removeRungs(idle, a, b)
+
+		live := newLiveSeries(a.Name, b.Name)
+		l.live[live] = true
+		go l.play(a, b, live, append(make([]obs.IObserver, 0), l.Observers...), done)
+		started++
+	}
+	return started
+}
+
+// The rungs but the two given
+func removeRungs(rungs []*rung, a, b *rung) []*rung {
+	rest := make([]*rung, 0)
+	for _, r := range rungs {
+		if r != a && r != b {
+			rest = append(rest, r)
+		}
+	}
+	return rest
+}
+
+// Play a series between two players, and hand it to the ladder
+func (l *ladder) play(a, b *rung, live *liveSeries, observers []obs.IObserver, done chan<- played) {
+	referee := ref.NewReferee(a.Name, a.Conn, b.Name, b.Conn)
+	for _, observer := range observers {
+		referee.AttachObserver(observer)
+	}
+	referee.AttachObserver(live)
+	recorder := archive.NewRecorder()
+	referee.AttachObserver(recorder)
+
+	games := referee.BestOf(l.gamesPerRound)
+	done <- played{a, b, games, live, recorder}
+}
+
+// Take in a series just played: its result, the players' new ratings, and
+// whoever has to leave because of it
+func (l *ladder) finish(series played) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	delete(l.live, series.live)
+	a, b := series.a, series.b
+	lastGame := series.games[len(series.games)-1]
+	match := result.NewMatchResult(lastGame.Winner, lastGame.Loser, lastGame.BrokenRule, series.games)
+	l.Matches = append(l.Matches, match)
+	l.archiveSeries(series.recorder.Series(l.archived, a.Name, b.Name, match))
+
+	winner, loser := a, b
+	if match.Winner == b.Name {
+		winner, loser = b, a
+	}
+	winner.wins++
+	loser.losses++
+	if !match.RuleBroken {
+		rate(winner, loser)
+	}
+
+	for _, r := range []*rung{a, b} {
+		r.playing = false
+		r.idleSince = time.Now()
+	}
+	a.opponent, b.opponent = b.Name, a.Name
+
+	if match.RuleBroken {
+		l.remove(loser)
+	}
+}
+
+// Move the ratings of a series' winner and loser by how unlikely the result was
+func rate(winner, loser *rung) {
+	expected := 1 / (1 + math.Pow(10, (loser.rating-winner.rating)/400))
+	winner.rating += RATING_K * (1 - expected)
+	loser.rating -= RATING_K * (1 - expected)
+}
+
+// Kick off every idle player asked to be; those playing are kicked once
+// their series is over
+func (l *ladder) applyKicks() {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	for name := range l.kicks {
+		r := l.records[name]
+		if !r.onLadder {
+			delete(l.kicks, name)
+		} else if !r.playing {
+			l.remove(r)
+			delete(l.kicks, name)
+		}
+	}
+}
+
+// Take a player off the ladder for breaking a rule or being kicked, for
+// good, holding the lock
+func (l *ladder) remove(r *rung) {
+	l.barred[r.Name] = true
+	l.Excluded = append(l.Excluded, r.user)
+	l.leave(r)
+}
+
+// Send each idle player a heartbeat, telling the ladder of any it can't
+// reach, until it stops. Players without a connection to lose aren't sent one
+func (l *ladder) probeIdle(gone chan<- *rung, stopped <-chan bool) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	status := output.WaitingRoom{Players: len(l.players), MinPlayers: 2}
+	for _, name := range l.players {
+		r := l.records[name]
+		w, ok := r.Conn.(waiter)
+		if r.playing || !ok {
+			continue
+		}
+		go func(r *rung, w waiter) {
+			if w.Waiting(status) == nil {
+				return
+			}
+			select {
+			case gone <- r:
+			case <-stopped:
+			}
+		}(r, w)
+	}
+}
+
+// A player told how long they've been waiting, such as a remote player
+type waiter interface {
+	Waiting(status output.WaitingRoom) error
+}
+
+// Take a player the heartbeat couldn't reach off the ladder, if they are
+// still idle on it. They may come back
+func (l *ladder) drop(r *rung) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if r.onLadder && !r.playing {
+		log.Printf("ladder: %s has gone", r.Name)
+		l.leave(r)
+	}
+}
+
+// Take a player off the ladder, sending them the results so far, holding
+// the lock
+func (l *ladder) leave(r *rung) {
+	for idx, name := range l.players {
+		if name == r.Name {
+			l.players = append(l.players[:idx], l.players[idx+1:]...)
+			break
+		}
+	}
+	r.onLadder = false
+	delete(l.kicks, r.Name)
+
+	soFar := l.resultsHeld()
+	go func(u user) {
+		if err := u.Conn.ReceiveTournamentResult(soFar); err != nil {
+			log.Printf("ladder: can't send results to %s: %v", u.Name, err)
+		}
+	}(r.user)
+}
+
+// Every series played so far, and who has left after breaking a rule
+func (l *ladder) results() result.TournamentResult {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.resultsHeld()
+}
+
+// The results so far, holding the lock
+func (l *ladder) resultsHeld() result.TournamentResult {
+	return result.TournamentResult{
+		Games:  append(make([]result.MatchResult, 0), l.Matches...),
+		Kicked: l.kicked(),
+	}
+}
+
+// Everyone who left the ladder and hasn't come back, holding the lock
+func (l *ladder) kicked() []string {
+	kicked := make([]string, 0)
+	for _, u := range l.Excluded {
+		if !l.records[u.Name].onLadder && !containsName(kicked, u.Name) {
+			kicked = append(kicked, u.Name)
+		}
+	}
+	return kicked
+}
+
+func containsName(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Begin archiving the ladder as a tournament. Failures to archive are only
+// logged, as with tournaments
+func (l *ladder) beginArchive() {
+	if l.archive == nil {
+		return
+	}
+	id, err := l.archive.BeginTournament(make([]string, 0))
+	if err != nil {
+		log.Printf("ladder: can't archive ladder: %v", err)
+		return
+	}
+	l.archived = id
+}
+
+// Archive a series just played, holding the lock
+func (l *ladder) archiveSeries(series archive.Series, games []archive.Game) {
+	if l.archive == nil || l.archived == 0 {
+		return
+	}
+	if err := l.archive.RecordSeries(series, games); err != nil {
+		log.Printf("ladder: can't archive series: %v", err)
+	}
+}
+
+// Archive how the ladder finished
+func (l *ladder) finishArchive(final result.TournamentResult) {
+	if l.archive == nil || l.archived == 0 {
+		return
+	}
+	if err := l.archive.FinishTournament(l.archived, final); err != nil {
+		log.Printf("ladder: can't archive results: %v", err)
+	}
+}
+
+// An observer shared by every series being played at once, which hears of
+// each update in turn, so one series' updates never interleave with
+// another's mid-update
+type sharedObserver struct {
+	observer obs.IObserver
+	lock     *sync.Mutex
+}
+
+func newSharedObserver(observer obs.IObserver) sharedObserver {
+	return sharedObserver{observer: observer, lock: &sync.Mutex{}}
+}
+
+func (o sharedObserver) Name() string {
+	return o.observer.Name()
+}
+
+func (o sharedObserver) ReceiveBoard(b board.IBoard) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	o.observer.ReceiveBoard(b)
+}
+
+func (o sharedObserver) ReceiveWinningMove(move output.MoveJSON) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	o.observer.ReceiveWinningMove(move)
+}
+
+func (o sharedObserver) ReceiveTurn(turn output.MoveBuildJSON) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	o.observer.ReceiveTurn(turn)
+}
+
+func (o sharedObserver) ReceiveEndgame(end rules.GameResult) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	o.observer.ReceiveEndgame(end)
+}
+
+// Close the observer, if it can be closed
+func (o sharedObserver) Close() error {
+	if closer, ok := o.observer.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
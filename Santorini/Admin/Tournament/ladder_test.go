@@ -0,0 +1,144 @@
+package tournament
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	sandbox "github.com/CS4500-F18/dare-rebr/Santorini/Admin/Sandbox"
+	output "github.com/CS4500-F18/dare-rebr/Santorini/Common/JSON"
+	obs "github.com/CS4500-F18/dare-rebr/Santorini/Observer"
+	client "github.com/CS4500-F18/dare-rebr/Santorini/Player/Client"
+)
+
+func TestPairing(t *testing.T) {
+	now := time.Now()
+	idle := []Candidate{
+		{Name: "fido", Rating: 1500, IdleSince: now, LastOpponent: "rex"},
+		{Name: "spot", Rating: 1300, IdleSince: now.Add(time.Second)},
+		{Name: "rex", Rating: 1510, IdleSince: now.Add(2 * time.Second)},
+		{Name: "rover", Rating: 1560, IdleSince: now.Add(3 * time.Second)},
+	}
+	if a, b := ByRating(idle); a != 0 || b != 3 {
+		t.Errorf("By rating, fido should play rover rather than rex again, not %s", idle[b].Name)
+	}
+	if a, b := LeastRecent(idle); a != 0 || b != 1 {
+		t.Errorf("By idle time, fido should play spot, not %s", idle[b].Name)
+	}
+	if a, b := ByRating(idle[:1:1]); a != 0 || b != -1 {
+		// Never asked with fewer than two, but shouldn't pick anyone
+		t.Errorf("Paired fido with %d", b)
+	}
+	if a, b := LeastRecent([]Candidate{idle[0], idle[2]}); a != 0 || b != 1 {
+		t.Error("Two players should play again if there's nobody else")
+	}
+}
+
+func TestRate(t *testing.T) {
+	winner, loser := &rung{rating: INITIAL_RATING}, &rung{rating: INITIAL_RATING}
+	rate(winner, loser)
+	if winner.rating != INITIAL_RATING+RATING_K/2 || loser.rating != INITIAL_RATING-RATING_K/2 {
+		t.Errorf("Even players should move half of K, not to %v and %v", winner.rating, loser.rating)
+	}
+
+	favourite, underdog := &rung{rating: 1800}, &rung{rating: 1400}
+	rate(favourite, underdog)
+	if gain := favourite.rating - 1800; gain <= 0 || gain >= RATING_K/2 {
+		t.Errorf("The favourite should gain less than half of K, not %v", gain)
+	}
+}
+
+// Players are paired as they join, and those who break a rule leave at once,
+// and can't come back
+func TestLadder_Run(t *testing.T) {
+	l := NewLadder(1)
+	players := make(chan sandbox.WrappedPlayer)
+	finished := make(chan bool)
+	go func() {
+		r := l.Run(players, make(chan obs.IObserver))
+		if len(r.Games) != 2 || len(r.Kicked) != 2 || r.Aborted {
+			t.Errorf("Wrong results: %+v", r)
+		}
+		close(finished)
+	}()
+
+	settle := func(expected func(Snapshot) bool) Snapshot {
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			snapshot := l.Snapshot()
+			if len(snapshot.Games) == 0 && expected(snapshot) {
+				return snapshot
+			} else if time.Now().After(deadline) {
+				t.Fatalf("Ladder never settled: %+v", snapshot)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	// Whoever is listed first in a series breaks a rule first
+	players <- sandbox.NewNormalPlayer(client.BrokenPlayer("fido"))
+	players <- sandbox.NewNormalPlayer(client.BrokenPlayer("rex"))
+	snapshot := settle(func(s Snapshot) bool { return len(s.Kicked) == 1 })
+	if len(snapshot.Players) != 1 || snapshot.Kicked[0] != "fido" {
+		t.Errorf("Wrong players after the first series: %+v", snapshot)
+	}
+
+	// Fido is only sent the results, and rex has been idle longer, so is
+	// listed first against spot
+	players <- sandbox.NewNormalPlayer(client.BrokenPlayer("fido"))
+	players <- sandbox.NewNormalPlayer(client.BrokenPlayer("spot"))
+	snapshot = settle(func(s Snapshot) bool {
+		return len(s.Standings) == 3 && len(s.Kicked) == 2
+	})
+	if len(snapshot.Players) != 1 || snapshot.Players[0] != "spot" || snapshot.Kicked[1] != "rex" {
+		t.Errorf("Wrong players after the second series: %+v", snapshot)
+	}
+	for _, standing := range snapshot.Standings {
+		if standing.Rating != INITIAL_RATING || standing.Wins+standing.Losses != map[string]int{"fido": 1, "rex": 2, "spot": 1}[standing.Name] {
+			t.Errorf("Wrong standings: %+v", snapshot.Standings)
+		}
+	}
+
+	if err := l.Kick("rex"); err == nil {
+		t.Error("Kicked a player who isn't on the ladder")
+	}
+	l.Abort()
+	select {
+	case <-finished:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Ladder never stopped")
+	}
+}
+
+// A remote player whose connection has gone
+type gonePlayer struct {
+	sandbox.WrappedPlayer
+}
+
+func (p gonePlayer) Waiting(status output.WaitingRoom) error {
+	return errors.New("connection closed")
+}
+
+// Idle players the heartbeat can't reach leave, without being kicked
+func TestLadder_Heartbeat(t *testing.T) {
+	l := NewLadder(1)
+	l.heartbeat = 10 * time.Millisecond
+	players := make(chan sandbox.WrappedPlayer)
+	go l.Run(players, make(chan obs.IObserver))
+	defer l.Abort()
+
+	players <- gonePlayer{sandbox.NewNormalPlayer(client.ValidPlayer("fido"))}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		snapshot := l.Snapshot()
+		if len(snapshot.Players) == 0 {
+			if len(snapshot.Kicked) != 0 {
+				t.Errorf("Kicked a player who went: %+v", snapshot)
+			}
+			return
+		} else if time.Now().After(deadline) {
+			t.Fatalf("Player never left: %+v", snapshot)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,170 @@
+package tournament
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	board "github.com/CS4500-F18/dare-rebr/Santorini/Common/Board"
+	output "github.com/CS4500-F18/dare-rebr/Santorini/Common/JSON"
+	rules "github.com/CS4500-F18/dare-rebr/Santorini/Common/Rules"
+	result "github.com/CS4500-F18/dare-rebr/Santorini/Common/Tournament"
+)
+
+//The state of a tournament at one moment, for anyone watching it from outside
+type Snapshot struct {
+	//Whether the players have all registered, and whether it was aborted
+	Started bool `json:"started"`
+	Aborted bool `json:"aborted"`
+
+	//Players still in the tournament, and those kicked out
+	Players []string `json:"players"`
+	Kicked  []string `json:"kicked"`
+
+	//Every player's record so far, best first
+	Standings []Standing `json:"standings"`
+
+	//The series being played
+	Games []LiveGame `json:"games"`
+}
+
+//A player's record in the series played so far
+type Standing struct {
+	Name   string `json:"name"`
+	Wins   int    `json:"wins"`
+	Losses int    `json:"losses"`
+
+	//The player's rating, on a ladder
+	Rating int `json:"rating,omitempty"`
+}
+
+//A series being played, with the latest board of the game under way
+type LiveGame struct {
+	Players []string     `json:"players"`
+	Game    int          `json:"game"`
+	Board   board.IBoard `json:"board"`
+}
+
+//The tournament's state, safe to call while it runs
+func (m *manager) Snapshot() Snapshot {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	snapshot := Snapshot{
+		Started:   m.started,
+		Aborted:   m.aborted,
+		Players:   userNames(m.Users),
+		Kicked:    userNames(m.Excluded),
+		Standings: standings(append(append(make([]user, 0), m.Users...), m.Excluded...), m.Matches),
+		Games:     make([]LiveGame, 0),
+	}
+	if m.live != nil {
+		snapshot.Games = append(snapshot.Games, m.live.snapshot())
+	}
+	return snapshot
+}
+
+//Kick the named player out as if they had broken a rule, once the series
+//being played is over. Returns an error if the tournament hasn't started,
+//or there is no such player in it
+func (m *manager) Kick(name string) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if !m.started {
+		return fmt.Errorf("The tournament hasn't started")
+	}
+	for _, u := range m.Users {
+		if u.Name == name {
+			m.kicks[name] = true
+			return nil
+		}
+	}
+	return fmt.Errorf("No player named %s", name)
+}
+
+//Kick out every player asked to be since the last series
+func (m *manager) applyKicks() {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	for name := range m.kicks {
+		for _, u := range m.Users {
+			if u.Name == name {
+				m.handleCheater(u)
+				break
+			}
+		}
+		delete(m.kicks, name)
+	}
+}
+
+//Each user's wins and losses in the given matches, best first
+func standings(users []user, matches []result.MatchResult) []Standing {
+	records := make(map[string]*Standing)
+	list := make([]Standing, 0)
+	for _, u := range users {
+		records[u.Name] = &Standing{Name: u.Name}
+	}
+	for _, match := range matches {
+		if record, ok := records[match.Winner]; ok {
+			record.Wins++
+		}
+		if record, ok := records[match.Loser]; ok {
+			record.Losses++
+		}
+	}
+
+	for _, record := range records {
+		list = append(list, *record)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Wins != list[j].Wins {
+			return list[i].Wins > list[j].Wins
+		}
+		if list[i].Losses != list[j].Losses {
+			return list[i].Losses < list[j].Losses
+		}
+		return list[i].Name < list[j].Name
+	})
+	return list
+}
+
+//Watches the series being played, keeping the latest board
+type liveSeries struct {
+	players []string
+
+	lock  sync.Mutex
+	game  int
+	board board.IBoard
+}
+
+func newLiveSeries(a, b string) *liveSeries {
+	return &liveSeries{players: []string{a, b}, game: 1}
+}
+
+func (l *liveSeries) snapshot() LiveGame {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return LiveGame{Players: l.players, Game: l.game, Board: l.board}
+}
+
+func (l *liveSeries) Name() string {
+	return "live"
+}
+
+func (l *liveSeries) ReceiveBoard(b board.IBoard) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.board = b
+}
+
+func (l *liveSeries) ReceiveWinningMove(t output.MoveJSON) {}
+
+func (l *liveSeries) ReceiveTurn(t output.MoveBuildJSON) {}
+
+func (l *liveSeries) ReceiveEndgame(end rules.GameResult) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.game++
+}
@@ -1,8 +1,13 @@
 package tournament
 
 import (
+	"io"
+	"log"
 	"strings"
+	"sync"
+	"time"
 
+	archive "github.com/CS4500-F18/dare-rebr/Santorini/Admin/Archive"
 	ref "github.com/CS4500-F18/dare-rebr/Santorini/Admin/Referee"
 	sandbox "github.com/CS4500-F18/dare-rebr/Santorini/Admin/Sandbox"
 	cfg "github.com/CS4500-F18/dare-rebr/Santorini/Admin/Tournament/Config"
@@ -11,6 +16,10 @@ import (
 	obs "github.com/CS4500-F18/dare-rebr/Santorini/Observer"
 )
 
+// How long each tournament took to play, once everyone had registered
+var tournamentTime = lib.METRICS.Histogram("santorini_tournament_duration_seconds", "How long each tournament took to play.",
+	[]float64{1, 10, 30, 60, 300, 600, 1800, 3600, 7200})
+
 /*
   A Tournament Manager should:
     - accept new Users (name, strategy)
@@ -42,6 +51,9 @@ type manager struct {
 	//The Map of taken names
 	existingNames map[string]bool
 
+	//Names no player may be renamed to, as they belong to authenticated players
+	reserved map[string]bool
+
 	//The Users within a running Tournament
 	Users []user
 
@@ -53,6 +65,35 @@ type manager struct {
 
 	//Users who have misbehaved during a game
 	Excluded []user
+
+	//Guards Users, Matches and Excluded against readers outside the
+	//tournament, and the state below
+	lock sync.Mutex
+
+	//Whether the players have been registered, and whether no more series
+	//should be started
+	started bool
+	aborted bool
+
+	//Names of the users to kick out before the next series
+	kicks map[string]bool
+
+	//The series being played, or nil
+	live *liveSeries
+
+	//Where to write a Checkpoint after each series, or "" for nowhere
+	checkpointPath string
+
+	//The Checkpoint to pick up from, or nil to start a new tournament
+	resumed *Checkpoint
+
+	//Players who registered for a resumed tournament they weren't in
+	latecomers []user
+
+	//Where every series and game is archived, or nil, and the tournament's
+	//ID there
+	archive  *archive.Archive
+	archived int
 }
 
 //Return a new Tournament Manager with the given configuration values
@@ -61,19 +102,28 @@ func NewManager(games int) *manager {
 		gamesPerRound: games,
 		Users:         make([]user, 0),
 		existingNames: make(map[string]bool),
+		reserved:      make(map[string]bool),
 		Matches:       make([]result.MatchResult, 0),
 		Observers:     make([]obs.IObserver, 0),
 		Excluded:      make([]user, 0),
+		kicks:         make(map[string]bool),
+		latecomers:    make([]user, 0),
 	}
 }
 
 //Load players and observers from a configuration
 func (m *manager) RunWithConfig(c cfg.TournamentConfig) result.TournamentResult {
 	players, observers := c.GenerateComponents()
+	if r, ok := c.(cfg.NameReserver); ok {
+		m.reserved = r.ReservedNames()
+	}
 
 	for _, player := range players {
 		m.acceptPlayer(player)
 	}
+	m.lock.Lock()
+	m.started = true
+	m.lock.Unlock()
 
 	for _, observer := range observers {
 		m.AttachObserver(observer)
@@ -84,26 +134,93 @@ func (m *manager) RunWithConfig(c cfg.TournamentConfig) result.TournamentResult
 
 //Run a Tournament between all Users added to the Tournament
 func (m *manager) run() result.TournamentResult {
-	potentialGames := generateTuples(m.Users)
+	started := time.Now()
+	defer func() {
+		tournamentTime.Observe(time.Since(started).Seconds())
+	}()
+
+	var potentialGames []UserPair
+	if m.resumed != nil {
+		potentialGames = m.restore(m.resumed)
+	} else {
+		potentialGames = generateTuples(m.Users)
+	}
+	m.beginArchive()
+	m.checkpoint(potentialGames)
 
-	for _, pair := range potentialGames {
+	aborted := m.Aborted()
+	for i, pair := range potentialGames {
+		if aborted = m.Aborted(); aborted {
+			break
+		}
+		m.applyKicks()
 		if m.runnablePair(pair.UserA, pair.UserB) {
 			m.runSeries(pair.UserA, pair.UserB)
 		}
+		m.checkpoint(potentialGames[i+1:])
+	}
+	m.applyKicks()
+	if !aborted {
+		if err := m.clearCheckpoint(); err != nil {
+			log.Printf("tournament: can't remove checkpoint: %v", err)
+		}
 	}
 
 	result := result.TournamentResult{
-		Games:  m.Matches,
-		Kicked: userNames(m.Excluded),
+		Games:   m.Matches,
+		Kicked:  userNames(m.Excluded),
+		Aborted: aborted,
 	}
 
-	for _, user := range append(m.Users, m.Excluded...) {
-		user.Conn.ReceiveTournamentResult(result)
+	m.finishArchive(result)
+
+	for _, user := range append(append(append(make([]user, 0), m.Users...), m.Excluded...), m.latecomers...) {
+		// Players who left before a resumed tournament can't be told
+		if user.Conn != nil {
+			user.Conn.ReceiveTournamentResult(result)
+		}
+	}
+
+	// Observers with a connection of their own are done watching
+	for _, observer := range m.Observers {
+		if closer, ok := observer.(io.Closer); ok {
+			closer.Close()
+		}
 	}
 
 	return result
 }
 
+//Save a checkpoint with the given series still to play. A server that can't
+//save one can still finish the tournament, so it only complains
+func (m *manager) checkpoint(remaining []UserPair) {
+	if err := m.saveCheckpoint(remaining); err != nil {
+		log.Printf("tournament: can't save checkpoint: %v", err)
+	}
+}
+
+//Whether the players have all registered, and the tournament is under way
+func (m *manager) Started() bool {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return m.started
+}
+
+//Stop the tournament once the series being played is over, or before the
+//first if it hasn't started. Everyone is still sent the results so far
+func (m *manager) Abort() {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.aborted = true
+}
+
+//Whether the tournament has been aborted
+func (m *manager) Aborted() bool {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return m.aborted
+}
+
 //A Pair of two users representing a potential game matchup
 type UserPair struct {
 	UserA, UserB user
@@ -131,9 +248,21 @@ func (m *manager) runnablePair(a, b user) bool {
 func (m *manager) runSeries(a, b user) {
 	referee := ref.NewReferee(a.Name, a.Conn, b.Name, b.Conn)
 	m.AttachObservers(referee)
+
+	live := newLiveSeries(a.Name, b.Name)
+	referee.AttachObserver(live)
+	recorder := archive.NewRecorder()
+	referee.AttachObserver(recorder)
+	m.lock.Lock()
+	m.live = live
+	m.lock.Unlock()
+
 	gameSet := referee.BestOf(m.gamesPerRound)
 	lastGame := gameSet[len(gameSet)-1]
 
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.live = nil
 	if lastGame.BrokenRule {
 		if lastGame.Loser == a.Name {
 			m.handleCheater(a)
@@ -143,8 +272,11 @@ func (m *manager) runSeries(a, b user) {
 	}
 	matchResult := result.NewMatchResult(lastGame.Winner, lastGame.Loser, lastGame.BrokenRule, gameSet)
 	m.Matches = append(m.Matches, matchResult)
+	m.archiveSeries(recorder.Series(m.archived, a.Name, b.Name, matchResult))
 
 	m.DetachObservers(referee)
+	referee.DetachObserver(live)
+	referee.DetachObserver(recorder)
 }
 
 //Add an Observer to the tournament
@@ -182,16 +314,24 @@ func (m *manager) acceptPlayer(player sandbox.WrappedPlayer) {
 	lowercase := strings.ToLower(originalName)
 
 	if !m.validName(lowercase) || !m.addUnique(lowercase, player) {
-		newName := ""
+		newName := substituteName(func(name string) bool {
+			return m.reserved[name] || m.existingNames[name]
+		})
+		m.addUnique(newName, player)
+		player.SetName(newName)
+	}
+}
 
-		for i := 0; true; i++ {
-			newName = newName + string(lib.ALPHA[i%len(lib.ALPHA)]) // repeat a-z
-			if m.addUnique(newName, player) {
-				player.SetName(newName)
-				return
-			}
+//The first of "a", "ab", "abc"... (repeating a-z) that isn't taken
+func substituteName(taken func(name string) bool) string {
+	newName := ""
+	for i := 0; true; i++ {
+		newName = newName + string(lib.ALPHA[i%len(lib.ALPHA)]) // repeat a-z
+		if !taken(newName) {
+			break
 		}
 	}
+	return newName
 }
 
 //Is the given player name valid?
@@ -205,6 +345,8 @@ func (m *manager) validName(name string) bool {
 //has a unique name, or return false if not unique
 func (m *manager) addUnique(name string, player sandbox.WrappedPlayer) bool {
 	if _, ok := m.existingNames[name]; !ok {
+		m.lock.Lock()
+		defer m.lock.Unlock()
 		m.Users = append(m.Users, NewUser(name, player))
 		m.existingNames[name] = true
 		return true
@@ -212,7 +354,8 @@ func (m *manager) addUnique(name string, player sandbox.WrappedPlayer) bool {
 	return false
 }
 
-//Remove a rule-violating player from the manager's users and match history
+//Remove a rule-violating player from the manager's users and match history,
+//holding the lock
 func (m *manager) handleCheater(target user) {
 	m.removeCheater(target)
 	m.removeFromHistory(target)
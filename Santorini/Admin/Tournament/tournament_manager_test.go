@@ -0,0 +1,155 @@
+package tournament
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	archive "github.com/CS4500-F18/dare-rebr/Santorini/Admin/Archive"
+	sandbox "github.com/CS4500-F18/dare-rebr/Santorini/Admin/Sandbox"
+	rules "github.com/CS4500-F18/dare-rebr/Santorini/Common/Rules"
+	client "github.com/CS4500-F18/dare-rebr/Santorini/Player/Client"
+)
+
+// A player kicked before their series is out of the tournament without playing
+func TestManager_Kick(t *testing.T) {
+	m := NewManager(1)
+	if err := m.Kick("rex"); err == nil {
+		t.Error("Kicked a player before the tournament started")
+	}
+
+	for _, name := range []string{"fido", "rex", "spot"} {
+		m.acceptPlayer(sandbox.NewNormalPlayer(client.BrokenPlayer(name)))
+	}
+	m.started = true
+	if err := m.Kick("nobody"); err == nil {
+		t.Error("Kicked a player who isn't in the tournament")
+	}
+	if err := m.Kick("rex"); err != nil {
+		t.Fatal(err)
+	}
+
+	result := m.run()
+	if len(result.Games) != 1 || result.Games[0].Winner == "rex" || result.Games[0].Loser == "rex" {
+		t.Errorf("Kicked player played: %+v", result.Games)
+	}
+
+	snapshot := m.Snapshot()
+	if len(snapshot.Standings) != 3 || len(snapshot.Games) != 0 {
+		t.Errorf("Wrong snapshot: %+v", snapshot)
+	}
+	kicked := false
+	for _, name := range snapshot.Kicked {
+		kicked = kicked || name == "rex"
+	}
+	if !kicked {
+		t.Errorf("Kicked player not listed: %v", snapshot.Kicked)
+	}
+}
+
+// An aborted tournament keeps its checkpoint, with every series still to play
+func TestManager_CheckpointAborted(t *testing.T) {
+	dir, err := ioutil.TempDir("", "checkpoint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "checkpoint.json")
+
+	m := NewManager(1).WithCheckpoint(path)
+	for _, name := range []string{"fido", "rex", "spot"} {
+		m.acceptPlayer(sandbox.NewNormalPlayer(client.BrokenPlayer(name)))
+	}
+	m.Abort()
+	m.run()
+
+	checkpoint, err := LoadCheckpoint(path)
+	if err != nil || checkpoint == nil {
+		t.Fatalf("No checkpoint: %v", err)
+	}
+	if len(checkpoint.Players) != 3 || len(checkpoint.Remaining) != 3 || len(checkpoint.Matches) != 0 {
+		t.Errorf("Wrong checkpoint: %+v", checkpoint)
+	}
+}
+
+// A resumed tournament plays only the series left, forfeits players who
+// didn't come back, and removes its checkpoint when it finishes
+func TestManager_Resume(t *testing.T) {
+	dir, err := ioutil.TempDir("", "checkpoint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "checkpoint.json")
+
+	games := []rules.GameResult{{Winner: "spot", Loser: "fido", Reason: rules.WINNING_MOVE_MSG}}
+	checkpoint := &Checkpoint{
+		Games:     1,
+		Players:   []string{"fido", "rex", "spot"},
+		Excluded:  []string{},
+		Matches:   []CheckpointMatch{{Winner: "spot", Loser: "fido", Games: games}},
+		Remaining: [][2]string{{"fido", "rex"}, {"rex", "spot"}},
+	}
+	m := NewManager(3).WithCheckpoint(path).Resume(checkpoint)
+	for _, name := range []string{"fido", "rex", "rover"} {
+		m.acceptPlayer(sandbox.NewNormalPlayer(client.BrokenPlayer(name)))
+	}
+	m.started = true
+	result := m.run()
+
+	// Breaking the rules, fido also loses the win handed over by spot
+	if len(result.Games) != 1 || result.Games[0].Winner != "rex" || result.Games[0].Loser != "fido" {
+		t.Errorf("Expected only fido and rex's series, got %+v", result.Games)
+	}
+	kicked := make(map[string]bool)
+	for _, name := range result.Kicked {
+		kicked[name] = true
+	}
+	if !kicked["spot"] || !kicked["fido"] || kicked["rex"] {
+		t.Errorf("Wrong players kicked: %v", result.Kicked)
+	}
+	for _, u := range m.Users {
+		if u.Name == "rover" {
+			t.Error("Newcomer joined a resumed tournament")
+		}
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("Checkpoint left after the tournament finished: %v", err)
+	}
+}
+
+// Every series played is archived with its games, and the tournament with its
+// final results
+func TestManager_Archive(t *testing.T) {
+	dir, err := ioutil.TempDir("", "archive")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	ar, err := archive.Open(filepath.Join(dir, "archive.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ar.Close()
+
+	m := NewManager(1).WithArchive(ar)
+	for _, name := range []string{"fido", "rex", "spot"} {
+		m.acceptPlayer(sandbox.NewNormalPlayer(client.BrokenPlayer(name)))
+	}
+	r := m.run()
+
+	tournaments := ar.RecentTournaments(0)
+	if len(tournaments) != 1 || tournaments[0].Finished.IsZero() || len(tournaments[0].Kicked) != len(r.Kicked) {
+		t.Fatalf("Wrong tournaments archived: %+v", tournaments)
+	}
+	series := ar.Recent(0)
+	if len(series) == 0 {
+		t.Fatal("No series archived")
+	}
+	for _, s := range series {
+		if games := ar.SeriesGames(s.ID); len(games) == 0 || games[0].Tournament != tournaments[0].ID {
+			t.Errorf("Wrong games archived for series %+v: %+v", s, games)
+		}
+	}
+}
@@ -72,7 +72,7 @@ func (b *board) UnmarshalJSON(buf []byte) error {
 		}
 	}
 
-	copy(b.workers[:len(b.workers)], workers[:len(b.workers)])
+	copy(b.workers, workers)
 
 	return nil
 }
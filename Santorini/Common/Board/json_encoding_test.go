@@ -2,6 +2,7 @@ package board
 
 import (
 	"bytes"
+	"encoding/json"
 	"testing"
 
 	"github.com/CS4500-F18/dare-rebr/Santorini/Lib"
@@ -46,6 +47,19 @@ func TestJSONStreams_Encoder(t *testing.T) {
 	}
 }
 
+// A board mid-placement, with fewer workers than a full game, decodes too
+func TestUnmarshal_FewerWorkers(t *testing.T) {
+	in := `[["0uno1", "0"], ["0", "0dos1"]]`
+
+	b := BaseBoard()
+	if err := json.Unmarshal([]byte(in), &b); err != nil {
+		t.Fatalf("Failed to decode board: %v", err)
+	}
+	if workers := b.Workers(); len(workers) != 2 || workers[0].Pos() != (Pos{X: 0, Y: 0}) || workers[1].Pos() != (Pos{X: 1, Y: 1}) {
+		t.Errorf("Wrong workers: %v", workers)
+	}
+}
+
 func sliceEqualityString(a, b []string) bool {
 	if (a == nil) != (b == nil) {
 		return false
@@ -0,0 +1,90 @@
+package json
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// The version of the tagged protocol spoken by this code
+const PROTOCOL_VERSION = 1
+
+// Types of message sent in an Envelope
+const (
+	// client -> server, payload is the client's Hello
+	MSG_REGISTER = "register"
+
+	// server -> client
+	MSG_RENAME        = "playing-as"    // payload is the new name
+	MSG_OPPONENT      = "opponent"      // payload is the opponent's name
+	MSG_PLACE_REQUEST = "place-request" // payload is the workers placed so far
+	MSG_TURN_REQUEST  = "turn-request"  // payload is the Board
+	MSG_RESULTS       = "results"       // payload is the tournament results
+
+	// server -> client, payloads are the lifecycle messages
+	MSG_SERIES_START  = SERIES_START
+	MSG_GAME_START    = GAME_START
+	MSG_OPPONENT_TURN = OPPONENT_TURN
+	MSG_GAME_END      = GAME_END
+
+	// client -> server, in reply to a request
+	MSG_PLACEMENT = "placement" // payload is the Pos to place at
+	MSG_TURN      = "turn"      // payload is a move/build, or a lone move
+	MSG_GIVE_UP   = "give-up"   // no payload
+)
+
+// Every message of the tagged protocol, in either direction.
+//  - Version is the PROTOCOL_VERSION of the sender
+//  - Game counts the games the client has been told about (0 before the first)
+//  - Seq counts the messages the server has sent on this connection; a reply
+//    carries the Seq of the request it answers
+type Envelope struct {
+	Version int             `json:"version"`
+	Type    string          `json:"type"`
+	Game    int             `json:"game"`
+	Seq     int             `json:"seq"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+// Wrap a payload (which may be nil) in an Envelope
+func NewEnvelope(kind string, game, seq int, payload interface{}) (Envelope, error) {
+	env := Envelope{Version: PROTOCOL_VERSION, Type: kind, Game: game, Seq: seq}
+	if payload == nil {
+		return env, nil
+	}
+
+	buf, err := json.Marshal(payload)
+	if err != nil {
+		return env, err
+	}
+	env.Payload = buf
+	return env, nil
+}
+
+// Build the reply to this Envelope
+func (e Envelope) Reply(kind string, payload interface{}) (Envelope, error) {
+	return NewEnvelope(kind, e.Game, e.Seq, payload)
+}
+
+// Decode this Envelope's payload into the target
+func (e Envelope) Decode(target interface{}) error {
+	if len(e.Payload) == 0 {
+		return fmt.Errorf("No payload in %s message", e.Type)
+	}
+	return json.Unmarshal(e.Payload, target)
+}
+
+// Check that this Envelope is of the given type, and answers the given Seq
+func (e Envelope) Expect(seq int, kinds ...string) error {
+	if e.Version != PROTOCOL_VERSION {
+		return fmt.Errorf("Unsupported protocol version: %v", e.Version)
+	}
+	if e.Seq != seq {
+		return fmt.Errorf("Reply to message %v when %v was expected", e.Seq, seq)
+	}
+	for _, kind := range kinds {
+		if e.Type == kind {
+			return nil
+		}
+	}
+	return fmt.Errorf("Unexpected %s message", e.Type)
+}
@@ -0,0 +1,170 @@
+package json
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Types of message in the handshake that opens the tagged protocol
+const (
+	// server -> client, payload is the Welcome
+	MSG_WELCOME = "welcome"
+	// server -> client, payload is the Rejection; the server then hangs up
+	MSG_REJECT = "reject"
+	// server -> client while registration is open, payload is the WaitingRoom
+	MSG_WAITING = "waiting"
+)
+
+// Optional parts of the protocol a client and server can agree on
+const (
+	// series-start, game-start, opponent-turn and game-end messages
+	FEATURE_LIFECYCLE = "lifecycle"
+	// a give-up reply to a turn request, to resign the game
+	FEATURE_RESIGN = "resign"
+	// the Welcome tells the client its time limit for each reply
+	FEATURE_TIME_CONTROL = "time-control"
+	// the Welcome holds a session token, with which a client whose connection
+	// drops can register again within the grace window and carry on
+	FEATURE_RESUME = "resume"
+	// waiting messages until the tournament starts
+	FEATURE_WAITING_ROOM = "waiting-room"
+)
+
+// The only rules this server plays by
+const STANDARD_VARIANT = "standard"
+
+// The client software identity of this code
+const SOFTWARE = "dare-rebr"
+
+// Every feature this code supports, as client or server
+var SUPPORTED_FEATURES = []string{FEATURE_LIFECYCLE, FEATURE_RESIGN, FEATURE_TIME_CONTROL, FEATURE_RESUME, FEATURE_WAITING_ROOM}
+
+// The features a client speaking the untagged protocol gets, without a handshake.
+// Lifecycle messages aren't among them: an untagged client can't tell them from
+// the messages it already knows
+var LEGACY_FEATURES = []string{FEATURE_RESIGN}
+
+// What a client offers when it registers with the tagged protocol
+type Hello struct {
+	Role     string   `json:"role"`
+	Name     string   `json:"name"`
+	Versions []int    `json:"versions"`
+	Features []string `json:"features"`
+	Variants []string `json:"variants"`
+	Software string   `json:"software"`
+
+	// The session token of an earlier connection, to resume it
+	Token string `json:"token,omitempty"`
+
+	// Proof the client may use its name, if it has any
+	Auth *Auth `json:"auth,omitempty"`
+
+	// The lobby to join on a server hosting several, or "" for the default
+	Lobby string `json:"lobby,omitempty"`
+}
+
+// A client's proof that it may use its name: either the pre-shared token
+// issued for that name, or the name's password
+type Auth struct {
+	Token    string `json:"token,omitempty"`
+	Password string `json:"password,omitempty"`
+}
+
+// A Hello offering everything this code supports
+func NewHello(role, name string) Hello {
+	return Hello{
+		Role:     role,
+		Name:     name,
+		Versions: []int{PROTOCOL_VERSION},
+		Features: SUPPORTED_FEATURES,
+		Variants: []string{STANDARD_VARIANT},
+		Software: SOFTWARE,
+	}
+}
+
+// What the server settles on for a client
+type Welcome struct {
+	Version  int      `json:"version"`
+	Features []string `json:"features"`
+	Variant  string   `json:"variant"`
+	Software string   `json:"software"`
+
+	// Milliseconds the client has to reply to each request, with time-control
+	TimeLimit int `json:"time-limit,omitempty"`
+
+	// The session token to resume with, and the milliseconds the client has
+	// to reconnect after a drop, with resume
+	Token string `json:"token,omitempty"`
+	Grace int    `json:"grace,omitempty"`
+
+	// The lobby the client joined, on a server hosting several
+	Lobby string `json:"lobby,omitempty"`
+}
+
+// Whether the given feature was agreed on
+func (w Welcome) Has(feature string) bool {
+	return contains(w.Features, feature)
+}
+
+// Why the server turned a client away
+type Rejection struct {
+	Reason string `json:"reason"`
+}
+
+func (r Rejection) Error() string {
+	return "Rejected by server: " + r.Reason
+}
+
+// How registration is going, as a registered player waits for the tournament
+type WaitingRoom struct {
+	Players    int `json:"players"`
+	MinPlayers int `json:"min-players"`
+	// 0 if there is no limit
+	MaxPlayers int `json:"max-players,omitempty"`
+
+	// Milliseconds until the tournament starts, if it has enough players by
+	// then, and until registration closes regardless, if it ever does
+	StartsIn int `json:"starts-in"`
+	ClosesIn int `json:"closes-in,omitempty"`
+}
+
+// Choose the newest common version, the common features, and the standard
+// variant for a client's Hello, given the features the server supports and
+// its time limit in milliseconds. Returns a Rejection if there is no
+// common version or variant
+func Negotiate(hello Hello, features []string, timeLimit int) (Welcome, error) {
+	welcome := Welcome{Features: make([]string, 0), Variant: STANDARD_VARIANT, Software: SOFTWARE}
+
+	for _, v := range hello.Versions {
+		if v <= PROTOCOL_VERSION && v > welcome.Version {
+			welcome.Version = v
+		}
+	}
+	if welcome.Version == 0 {
+		return welcome, Rejection{fmt.Sprintf("no common protocol version, server speaks %v", PROTOCOL_VERSION)}
+	}
+
+	if len(hello.Variants) > 0 && !contains(hello.Variants, STANDARD_VARIANT) {
+		return welcome, Rejection{fmt.Sprintf("server only plays %s, not %s",
+			STANDARD_VARIANT, strings.Join(hello.Variants, ", "))}
+	}
+
+	for _, feature := range hello.Features {
+		if contains(features, feature) && !welcome.Has(feature) {
+			welcome.Features = append(welcome.Features, feature)
+		}
+	}
+	if welcome.Has(FEATURE_TIME_CONTROL) {
+		welcome.TimeLimit = timeLimit
+	}
+	return welcome, nil
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
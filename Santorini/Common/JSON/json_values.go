@@ -203,6 +203,12 @@ type MoveTurn struct {
 	MoveNS string
 }
 
+// Convert a move to an array of its fields
+func (m MoveTurn) MarshalJSON() ([]byte, error) {
+	tmp := []interface{}{m.WorkerName, m.MoveEW, m.MoveNS}
+	return json.Marshal(tmp)
+}
+
 // Create a MoveTurn from JSON bytes
 func (m *MoveTurn) UnmarshalJSON(buf []byte) error {
 	tmp := []interface{}{&m.WorkerName, &m.MoveEW, &m.MoveNS}
@@ -271,9 +277,9 @@ type Rename struct {
 }
 
 // Convert JSON bytes to a struct wrapping a string
-func (n Rename) UnmarshalJSON(buf []byte) error {
-	var throwaway string
-	tmp := []interface{}{&throwaway, &n.Name}
+func (n *Rename) UnmarshalJSON(buf []byte) error {
+	var keyword string
+	tmp := []interface{}{&keyword, &n.Name}
 	wantLen := len(tmp)
 	if err := json.Unmarshal(buf, &tmp); err != nil {
 		return err
@@ -281,10 +287,10 @@ func (n Rename) UnmarshalJSON(buf []byte) error {
 	if g, e := len(tmp), wantLen; g != e {
 		return fmt.Errorf("wrong number of fields in Rename: %d != %d", g, e)
 	}
-	if tmp[0] != "playing-as" {
+	if keyword != "playing-as" {
 		// should have "playing-as", but we ignore it
 		// once we confirm presence
-		return fmt.Errorf("Invalid first argument to Rename: %s", tmp[0])
+		return fmt.Errorf("Invalid first argument to Rename: %s", keyword)
 	}
 	return nil
 }
@@ -0,0 +1,154 @@
+package json
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	common "github.com/CS4500-F18/dare-rebr/Santorini/Common/Board"
+	iplayer "github.com/CS4500-F18/dare-rebr/Santorini/Common/Player"
+	rules "github.com/CS4500-F18/dare-rebr/Santorini/Common/Rules"
+)
+
+// Lifecycle messages are JSON arrays whose first element is one of these keywords
+const (
+	SERIES_START  = "series-start"
+	GAME_START    = "game-start"
+	OPPONENT_TURN = "opponent-turn"
+	GAME_END      = "game-end"
+)
+
+// Get the keyword of a lifecycle message, or an error if the given JSON bytes
+// are not a lifecycle message
+func LifecycleKeyword(buf []byte) (string, error) {
+	var tmp []json.RawMessage
+	if err := json.Unmarshal(buf, &tmp); err != nil {
+		return "", err
+	}
+	if len(tmp) == 0 {
+		return "", errors.New("Empty array is not a lifecycle message")
+	}
+
+	var keyword string
+	if err := json.Unmarshal(tmp[0], &keyword); err != nil {
+		return "", err
+	}
+
+	switch keyword {
+	case SERIES_START, GAME_START, OPPONENT_TURN, GAME_END:
+		return keyword, nil
+	}
+	return "", fmt.Errorf("Unknown lifecycle keyword: %s", keyword)
+}
+
+// Decode a tagged message's fields, checking its keyword and field count
+func unmarshalTagged(buf []byte, keyword string, fields ...interface{}) error {
+	var throwaway string
+	tmp := append([]interface{}{&throwaway}, fields...)
+	wantLen := len(tmp)
+	if err := json.Unmarshal(buf, &tmp); err != nil {
+		return err
+	}
+	if g, e := len(tmp), wantLen; g != e {
+		return fmt.Errorf("wrong number of fields in %s: %d != %d", keyword, g, e)
+	}
+	if throwaway != keyword {
+		return fmt.Errorf("Invalid first argument to %s: %s", keyword, throwaway)
+	}
+	return nil
+}
+
+// The start of a series against an opponent
+type SeriesStart struct {
+	Opponent string
+	Games    int
+}
+
+func (s SeriesStart) MarshalJSON() ([]byte, error) {
+	return json.Marshal([]interface{}{SERIES_START, s.Opponent, s.Games})
+}
+
+func (s *SeriesStart) UnmarshalJSON(buf []byte) error {
+	return unmarshalTagged(buf, SERIES_START, &s.Opponent, &s.Games)
+}
+
+// The start of a single game within a series
+type GameStart struct {
+	// Index of the game within the series
+	Game int
+
+	// 0 if the receiving player goes first, 1 otherwise
+	Order int
+}
+
+func (g GameStart) MarshalJSON() ([]byte, error) {
+	return json.Marshal([]interface{}{GAME_START, g.Game, g.Order})
+}
+
+func (g *GameStart) UnmarshalJSON(buf []byte) error {
+	return unmarshalTagged(buf, GAME_START, &g.Game, &g.Order)
+}
+
+// A Turn taken by the receiving player's opponent, and the Board it was taken on
+type OpponentTurn struct {
+	Player string
+	Board  common.IBoard
+	Turn   iplayer.Turn
+}
+
+// Sends the Board before the turn, followed by the turn as a move/build
+// (or a lone move, if the turn won the game)
+func (o OpponentTurn) MarshalJSON() ([]byte, error) {
+	if _, err := o.Board.FindWorker(o.Player, o.Turn.WID); err != nil {
+		return nil, err
+	}
+
+	var action interface{}
+	if o.Turn.BuildAt.InBounds() {
+		action = MoveBuildFromTurn(o.Player, o.Board, o.Turn)
+	} else {
+		action = MoveFromTurn(o.Player, o.Board, o.Turn)
+	}
+
+	return json.Marshal([]interface{}{OPPONENT_TURN, o.Board, action})
+}
+
+func (o *OpponentTurn) UnmarshalJSON(buf []byte) error {
+	b := common.BaseBoard()
+	var action json.RawMessage
+	if err := unmarshalTagged(buf, OPPONENT_TURN, &b, &action); err != nil {
+		return err
+	}
+	o.Board = b
+
+	var mbt MoveBuildTurn
+	if err := json.Unmarshal(action, &mbt); err == nil {
+		o.Player, _, _ = common.ParseWorkerName(mbt.WorkerName)
+		o.Turn, err = mbt.ToTurn(b)
+		return err
+	}
+
+	var mt MoveTurn
+	if err := json.Unmarshal(action, &mt); err != nil {
+		return err
+	}
+	o.Player, _, _ = common.ParseWorkerName(mt.WorkerName)
+	turn, err := mt.ToTurn(b)
+	o.Turn = turn
+	return err
+}
+
+// The end of a single game
+type GameEnd struct {
+	Result rules.GameResult
+}
+
+func (g GameEnd) MarshalJSON() ([]byte, error) {
+	r := g.Result
+	return json.Marshal([]interface{}{GAME_END, r.Winner, r.Loser, r.Reason, r.BrokenRule})
+}
+
+func (g *GameEnd) UnmarshalJSON(buf []byte) error {
+	r := &g.Result
+	return unmarshalTagged(buf, GAME_END, &r.Winner, &r.Loser, &r.Reason, &r.BrokenRule)
+}
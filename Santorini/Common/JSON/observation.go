@@ -0,0 +1,151 @@
+package json
+
+import (
+	"encoding/json"
+	"fmt"
+
+	common "github.com/CS4500-F18/dare-rebr/Santorini/Common/Board"
+	rules "github.com/CS4500-F18/dare-rebr/Santorini/Common/Rules"
+)
+
+// Roles a client can declare when registering with a server
+const (
+	PLAYER_ROLE   = "player"
+	OBSERVER_ROLE = "observer"
+
+	// A connection carrying several clients, each in lib.Frames tagged with
+	// its own player ID, that register over it as if connected on their own.
+	// Only over the tagged protocol
+	MULTIPLEX_ROLE = "multiplex"
+)
+
+// The first message a client sends a server. Players send their name as a
+// JSON string, while observers send ["observer", name]. Clients speaking the
+// tagged protocol instead send a "register" Envelope holding their Hello
+type Registration struct {
+	Role string
+	Name string
+
+	// Whether the client speaks the tagged protocol, and what it offered
+	Tagged bool
+	Hello  Hello
+}
+
+func (r Registration) MarshalJSON() ([]byte, error) {
+	if r.Tagged {
+		r.Hello.Role, r.Hello.Name = r.Role, r.Name
+		env, err := NewEnvelope(MSG_REGISTER, 0, 0, r.Hello)
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(env)
+	}
+
+	if r.Role == OBSERVER_ROLE {
+		return json.Marshal([]string{OBSERVER_ROLE, r.Name})
+	}
+	return json.Marshal(r.Name)
+}
+
+func (r *Registration) UnmarshalJSON(buf []byte) error {
+	if err := json.Unmarshal(buf, &r.Name); err == nil {
+		r.Role = PLAYER_ROLE
+		return nil
+	}
+
+	var env Envelope
+	if err := json.Unmarshal(buf, &env); err == nil && env.Type == MSG_REGISTER {
+		if err := env.Decode(&r.Hello); err != nil {
+			return err
+		}
+		r.Role, r.Name, r.Tagged = r.Hello.Role, r.Hello.Name, true
+		if r.Role == "" {
+			r.Role = PLAYER_ROLE
+		}
+		return nil
+	}
+
+	var tmp []string
+	if err := json.Unmarshal(buf, &tmp); err != nil {
+		return err
+	}
+	if len(tmp) != 2 || tmp[0] != OBSERVER_ROLE {
+		return fmt.Errorf("Invalid registration: %s", string(buf))
+	}
+	r.Role = OBSERVER_ROLE
+	r.Name = tmp[1]
+	return nil
+}
+
+// Observation messages are JSON arrays whose first element is one of these keywords
+const (
+	OBSERVE_BOARD        = "board"
+	OBSERVE_TURN         = "turn"
+	OBSERVE_WINNING_MOVE = "winning-move"
+	OBSERVE_ENDGAME      = "endgame"
+)
+
+// A single update sent to a remote observer. Kind decides which of the
+// other fields is meaningful
+type Observation struct {
+	Kind   string
+	Board  common.IBoard
+	Turn   MoveBuildJSON
+	Move   MoveJSON
+	Result rules.GameResult
+}
+
+//   ["board", Board]
+//   ["turn", Worker, Direction, Direction]
+//   ["winning-move", Worker, Direction]
+//   ["endgame", Winner, Loser, Reason, BrokenRule]
+func (o Observation) MarshalJSON() ([]byte, error) {
+	var tmp []interface{}
+	switch o.Kind {
+	case OBSERVE_BOARD:
+		tmp = []interface{}{o.Kind, o.Board}
+	case OBSERVE_TURN:
+		tmp = []interface{}{o.Kind, o.Turn.WorkerName, o.Turn.MoveDir, o.Turn.BuildDir}
+	case OBSERVE_WINNING_MOVE:
+		tmp = []interface{}{o.Kind, o.Move.WorkerName, o.Move.MoveDir}
+	case OBSERVE_ENDGAME:
+		r := o.Result
+		tmp = []interface{}{o.Kind, r.Winner, r.Loser, r.Reason, r.BrokenRule}
+	default:
+		return nil, fmt.Errorf("Unknown observation kind: %s", o.Kind)
+	}
+	return json.Marshal(tmp)
+}
+
+func (o *Observation) UnmarshalJSON(buf []byte) error {
+	var tmp []json.RawMessage
+	if err := json.Unmarshal(buf, &tmp); err != nil {
+		return err
+	}
+	if len(tmp) == 0 {
+		return fmt.Errorf("Empty observation")
+	}
+	if err := json.Unmarshal(tmp[0], &o.Kind); err != nil {
+		return err
+	}
+
+	switch o.Kind {
+	case OBSERVE_BOARD:
+		b := common.BaseBoard()
+		if err := unmarshalTagged(buf, o.Kind, &b); err != nil {
+			return err
+		}
+		o.Board = b
+		return nil
+	case OBSERVE_TURN:
+		t := &o.Turn
+		return unmarshalTagged(buf, o.Kind, &t.WorkerName, &t.MoveDir, &t.BuildDir)
+	case OBSERVE_WINNING_MOVE:
+		m := &o.Move
+		return unmarshalTagged(buf, o.Kind, &m.WorkerName, &m.MoveDir)
+	case OBSERVE_ENDGAME:
+		r := &o.Result
+		return unmarshalTagged(buf, o.Kind, &r.Winner, &r.Loser, &r.Reason, &r.BrokenRule)
+	}
+	return fmt.Errorf("Unknown observation kind: %s", o.Kind)
+}
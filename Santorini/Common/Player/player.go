@@ -2,6 +2,7 @@ package player
 
 import (
 	"github.com/CS4500-F18/dare-rebr/Santorini/Common/Board"
+	rules "github.com/CS4500-F18/dare-rebr/Santorini/Common/Rules"
 	result "github.com/CS4500-F18/dare-rebr/Santorini/Common/Tournament"
 )
 
@@ -31,6 +32,22 @@ type IPlayer interface {
 	//Receives the results of the tournament for this player.
 	ReceiveTournamentResults(result []result.MatchResult)
 
+	//Informs the player that a series of (at most) the given number of games
+	//against the given opponent is about to begin
+	StartSeries(opponent string, games int)
+
+	//Informs the player that the given game (0-indexed) of the current series
+	//is starting, and the order this player goes in (0 places and moves first)
+	StartGame(game int, order int)
+
+	//Informs the player of the Turn their opponent took on the given IBoard.
+	//The IBoard is the state before the Turn was enacted. If the Turn won the
+	//game, its BuildAt is out of bounds
+	OpponentTurn(b board.IBoard, t Turn)
+
+	//Informs the player that the current game has ended, and why
+	EndGame(result rules.GameResult)
+
 	//Returns the string name of the opponent player
 	Opponent() string
 }
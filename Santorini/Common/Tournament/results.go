@@ -36,6 +36,9 @@ type TournamentResult struct {
 
 	// The users kicked for breaking rules
 	Kicked []string
+
+	// Whether the tournament was stopped before every game was played
+	Aborted bool
 }
 
 func (t TournamentResult) MarshalJSON() ([]byte, error) {
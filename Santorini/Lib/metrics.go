@@ -0,0 +1,254 @@
+package lib
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Counters, gauges and histograms, exposed in the Prometheus text format.
+// Each metric may have labels, given as values in the order the metric was
+// created with, so one metric holds a series per combination of values
+
+// Every metric Santorini gathers
+var METRICS = NewRegistry()
+
+// Latency buckets, in seconds, from a millisecond to a minute
+var LATENCY_BUCKETS = []float64{.001, .005, .01, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60}
+
+// A set of metrics to expose together
+type Registry struct {
+	lock     sync.Mutex
+	families []*family
+}
+
+func NewRegistry() *Registry {
+	return &Registry{families: make([]*family, 0)}
+}
+
+// A count that only goes up
+type Counter struct{ *family }
+
+// A value that goes up and down
+type Gauge struct{ *family }
+
+// Observations counted into buckets by value
+type Histogram struct{ *family }
+
+// Create a counter with the given name, help text and label names
+func (r *Registry) Counter(name, help string, labels ...string) Counter {
+	return Counter{r.add(name, help, "counter", nil, labels)}
+}
+
+// Create a gauge with the given name, help text and label names
+func (r *Registry) Gauge(name, help string, labels ...string) Gauge {
+	return Gauge{r.add(name, help, "gauge", nil, labels)}
+}
+
+// Create a histogram with the given name, help text, bucket upper bounds
+// (ascending) and label names
+func (r *Registry) Histogram(name, help string, buckets []float64, labels ...string) Histogram {
+	return Histogram{r.add(name, help, "histogram", buckets, labels)}
+}
+
+func (r *Registry) add(name, help, kind string, buckets []float64, labels []string) *family {
+	f := &family{name: name, help: help, kind: kind, buckets: buckets, labels: labels, series: make(map[string]*series)}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.families = append(r.families, f)
+	return f
+}
+
+// Write every metric in the Prometheus text format
+func (r *Registry) Write(w io.Writer) error {
+	r.lock.Lock()
+	families := append(make([]*family, 0), r.families...)
+	r.lock.Unlock()
+
+	buffered := bufio.NewWriter(w)
+	for _, f := range families {
+		f.write(buffered)
+	}
+	return buffered.Flush()
+}
+
+// Serve the metrics, for Prometheus to scrape
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	r.Write(w)
+}
+
+// Serve the metrics at /metrics on the given port of localhost (0 for any),
+// returning the listener to close when done
+func ServeMetrics(registry *Registry, port int) (net.Listener, error) {
+	listener, err := net.Listen("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(port)))
+	if err != nil {
+		return nil, err
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", registry)
+	go http.Serve(listener, mux)
+	return listener, nil
+}
+
+// Add one to the series with the given label values
+func (c Counter) Inc(values ...string) {
+	c.Add(1, values...)
+}
+
+// Add the given (positive) amount to the series with the given label values
+func (c Counter) Add(amount float64, values ...string) {
+	c.update(values, func(s *series) { s.value += amount })
+}
+
+// The current count of the series with the given label values
+func (c Counter) Value(values ...string) float64 {
+	return c.read(values, func(s *series) float64 { return s.value })
+}
+
+func (g Gauge) Set(value float64, values ...string) {
+	g.update(values, func(s *series) { s.value = value })
+}
+
+func (g Gauge) Inc(values ...string) {
+	g.update(values, func(s *series) { s.value++ })
+}
+
+func (g Gauge) Dec(values ...string) {
+	g.update(values, func(s *series) { s.value-- })
+}
+
+// The current value of the series with the given label values
+func (g Gauge) Value(values ...string) float64 {
+	return g.read(values, func(s *series) float64 { return s.value })
+}
+
+// Count an observation in the series with the given label values
+func (h Histogram) Observe(value float64, values ...string) {
+	h.update(values, func(s *series) {
+		for i, bound := range h.buckets {
+			if value <= bound {
+				s.buckets[i]++
+			}
+		}
+		s.sum += value
+		s.count++
+	})
+}
+
+// How many observations the series with the given label values has had
+func (h Histogram) Count(values ...string) float64 {
+	return h.read(values, func(s *series) float64 { return float64(s.count) })
+}
+
+// A metric and all its series
+type family struct {
+	name, help, kind string
+	labels           []string
+	buckets          []float64
+
+	lock   sync.Mutex
+	series map[string]*series
+}
+
+// The value of a metric for one combination of label values
+type series struct {
+	values  []string
+	value   float64
+	buckets []uint64
+	sum     float64
+	count   uint64
+}
+
+func (f *family) update(values []string, change func(*series)) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	key := strings.Join(values, "\xff")
+	s, found := f.series[key]
+	if !found {
+		s = &series{values: append(make([]string, 0), values...), buckets: make([]uint64, len(f.buckets))}
+		f.series[key] = s
+	}
+	change(s)
+}
+
+func (f *family) read(values []string, get func(*series) float64) float64 {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if s, found := f.series[strings.Join(values, "\xff")]; found {
+		return get(s)
+	}
+	return 0
+}
+
+func (f *family) write(w io.Writer) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	fmt.Fprintf(w, "# HELP %s %s\n", f.name, escape(f.help, false))
+	fmt.Fprintf(w, "# TYPE %s %s\n", f.name, f.kind)
+
+	keys := make([]string, 0)
+	for key := range f.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		s := f.series[key]
+		if f.kind != "histogram" {
+			fmt.Fprintf(w, "%s%s %s\n", f.name, f.labelSet(s.values, ""), formatValue(s.value))
+			continue
+		}
+		for i, bound := range f.buckets {
+			fmt.Fprintf(w, "%s_bucket%s %d\n", f.name, f.labelSet(s.values, formatValue(bound)), s.buckets[i])
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", f.name, f.labelSet(s.values, "+Inf"), s.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", f.name, f.labelSet(s.values, ""), formatValue(s.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", f.name, f.labelSet(s.values, ""), s.count)
+	}
+}
+
+// The {name="value",...} part of a sample, with the bucket's le if any
+func (f *family) labelSet(values []string, le string) string {
+	pairs := make([]string, 0)
+	for i, name := range f.labels {
+		value := ""
+		if i < len(values) {
+			value = values[i]
+		}
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, name, escape(value, true)))
+	}
+	if le != "" {
+		pairs = append(pairs, fmt.Sprintf(`le="%s"`, le))
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func formatValue(v float64) string {
+	if math.IsInf(v, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// Escape backslashes and newlines, and double quotes in label values
+func escape(s string, quotes bool) string {
+	s = strings.Replace(s, `\`, `\\`, -1)
+	s = strings.Replace(s, "\n", `\n`, -1)
+	if quotes {
+		s = strings.Replace(s, `"`, `\"`, -1)
+	}
+	return s
+}
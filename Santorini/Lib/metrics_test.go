@@ -0,0 +1,78 @@
+package lib
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestRegistry_Write(t *testing.T) {
+	r := NewRegistry()
+	games := r.Counter("games_total", "Games played.", "ending")
+	conns := r.Gauge("connections", "Open connections.")
+	latency := r.Histogram("latency_seconds", "Move latency.", []float64{.1, 1}, "player")
+
+	games.Inc("winning-move")
+	games.Inc("winning-move")
+	games.Inc(`rule "broken"`)
+	conns.Inc()
+	conns.Inc()
+	conns.Dec()
+	latency.Observe(.05, "a")
+	latency.Observe(.5, "a")
+	latency.Observe(2, "a")
+
+	if games.Value("winning-move") != 2 || conns.Value() != 1 || latency.Count("a") != 3 {
+		t.Errorf("Unexpected values %v, %v, %v", games.Value("winning-move"), conns.Value(), latency.Count("a"))
+	}
+
+	var buf bytes.Buffer
+	if err := r.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	expected := `# HELP games_total Games played.
+# TYPE games_total counter
+games_total{ending="rule \"broken\""} 1
+games_total{ending="winning-move"} 2
+# HELP connections Open connections.
+# TYPE connections gauge
+connections 1
+# HELP latency_seconds Move latency.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{player="a",le="0.1"} 1
+latency_seconds_bucket{player="a",le="1"} 2
+latency_seconds_bucket{player="a",le="+Inf"} 3
+latency_seconds_sum{player="a"} 2.55
+latency_seconds_count{player="a"} 3
+`
+	if buf.String() != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s", expected, buf.String())
+	}
+}
+
+func TestServeMetrics(t *testing.T) {
+	r := NewRegistry()
+	r.Counter("scrapes_total", "Scrapes.").Inc()
+
+	listener, err := ServeMetrics(r, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	resp, err := http.Get("http://" + listener.Addr().String() + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(resp.Body)
+
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/plain") {
+		t.Errorf("Unexpected content type %s", resp.Header.Get("Content-Type"))
+	}
+	if !strings.Contains(string(body), "scrapes_total 1\n") {
+		t.Errorf("Scrape missing the counter:\n%s", body)
+	}
+}
@@ -0,0 +1,278 @@
+package lib
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"net"
+	"strconv"
+	"sync"
+)
+
+// Several conversations sharing one connection. Each message on a channel
+// travels in a Frame tagged with the channel's player ID, and a Frame marked
+// closed hangs that channel up. Each channel is a net.Conn of its own, with
+// deadlines, so a Session can run over it just as over a TCP connection, and
+// a channel whose reader is slow holds up no other
+
+// A message on one channel of a multiplexed connection, or the news that the
+// channel was closed
+type Frame struct {
+	Player  int             `json:"player"`
+	Message json.RawMessage `json:"message,omitempty"`
+	Closed  bool            `json:"closed,omitempty"`
+}
+
+// The channels of one connection. The side that opens channels calls Open,
+// and the other side Accepts them, as a net.Listener
+type Mux struct {
+	session *Session
+	addr    net.Addr
+
+	lock     sync.Mutex
+	channels map[int]*muxChannel
+	next     int
+
+	// Channels the other side opened, in order, not yet Accepted, and a
+	// signal that there are more
+	pending  []net.Conn
+	accepted chan bool
+
+	closed    chan bool
+	closeOnce sync.Once
+}
+
+// Multiplex the given session, whose connection has the given remote address.
+// The Mux owns the session from now on, and closes it once the Mux is closed
+func NewMux(session *Session, addr net.Addr) *Mux {
+	m := &Mux{
+		session:  session,
+		addr:     addr,
+		channels: make(map[int]*muxChannel),
+		next:     1,
+		accepted: make(chan bool, 1),
+		closed:   make(chan bool),
+	}
+	go m.demux()
+	return m
+}
+
+// Open a new channel, with the next player ID
+func (m *Mux) Open() (net.Conn, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	select {
+	case <-m.closed:
+		return nil, net.ErrClosed
+	default:
+	}
+	for m.channels[m.next] != nil {
+		m.next++
+	}
+	conn := m.open(m.next)
+	m.next++
+	return conn, nil
+}
+
+// The next channel the other side opens
+func (m *Mux) Accept() (net.Conn, error) {
+	for {
+		m.lock.Lock()
+		if len(m.pending) > 0 {
+			conn := m.pending[0]
+			m.pending = m.pending[1:]
+			m.lock.Unlock()
+			return conn, nil
+		}
+		m.lock.Unlock()
+
+		select {
+		case <-m.accepted:
+		case <-m.closed:
+			return nil, net.ErrClosed
+		}
+	}
+}
+
+// The address of the multiplexed connection
+func (m *Mux) Addr() net.Addr {
+	return m.addr
+}
+
+// Hang up every channel, and the connection they share
+func (m *Mux) Close() error {
+	var err error
+	m.closeOnce.Do(func() {
+		close(m.closed)
+		err = m.session.Close()
+
+		m.lock.Lock()
+		defer m.lock.Unlock()
+		for _, channel := range m.channels {
+			channel.end(false)
+		}
+	})
+	return err
+}
+
+// Pass each Frame on to its channel, opening channels we haven't heard of,
+// until the connection fails
+func (m *Mux) demux() {
+	defer m.Close()
+	for {
+		var frame Frame
+		if err := m.session.Receive(&frame); err != nil {
+			return
+		}
+
+		m.lock.Lock()
+		channel, found := m.channels[frame.Player]
+		if !found && !frame.Closed {
+			m.pending = append(m.pending, m.open(frame.Player))
+			channel = m.channels[frame.Player]
+			select {
+			case m.accepted <- true:
+			default:
+			}
+		}
+		m.lock.Unlock()
+
+		if channel == nil {
+			continue
+		} else if frame.Closed {
+			channel.end(true)
+		} else {
+			channel.deliver(frame.Message)
+		}
+	}
+}
+
+// Start a channel with the given player ID, returning our end of it. The
+// lock must be held
+func (m *Mux) open(id int) net.Conn {
+	local, inner := net.Pipe()
+	channel := &muxChannel{id: id, inner: inner}
+	channel.ready = sync.NewCond(&channel.lock)
+	m.channels[id] = channel
+
+	go channel.pump()
+	go m.forward(channel)
+
+	addr := muxAddr(m.addr.String() + "#" + strconv.Itoa(id))
+	return &muxConn{Conn: local, addr: addr}
+}
+
+// Send each line written to the channel in a Frame, until it is closed,
+// then tell the other side it was
+func (m *Mux) forward(c *muxChannel) {
+	reader := bufio.NewReader(c.inner)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if msg := bytes.TrimSpace(line); len(msg) > 0 {
+			if m.session.Send(Frame{Player: c.id, Message: msg}) != nil {
+				break
+			}
+		}
+		if err != nil {
+			break
+		}
+	}
+
+	if c.end(false) {
+		select {
+		case <-m.closed:
+		default:
+			m.session.Send(Frame{Player: c.id, Closed: true})
+		}
+	}
+}
+
+// One channel: the end the Mux reads from and writes to, and the messages
+// from the other side not yet read
+type muxChannel struct {
+	id    int
+	inner net.Conn
+
+	lock  sync.Mutex
+	ready *sync.Cond
+	queue [][]byte
+	// Whether the other side closed the channel, and whether it has ended
+	hungUp bool
+	ended  bool
+}
+
+// Queue a message from the other side to be read
+func (c *muxChannel) deliver(msg []byte) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.ended || c.hungUp {
+		return
+	}
+	c.queue = append(c.queue, append(msg, '\n'))
+	c.ready.Signal()
+}
+
+// Write each queued message to the channel, in order, until it ends. If the
+// other side hung up, whatever it sent first is still read
+func (c *muxChannel) pump() {
+	for {
+		c.lock.Lock()
+		for len(c.queue) == 0 && !c.ended && !c.hungUp {
+			c.ready.Wait()
+		}
+		if c.ended || len(c.queue) == 0 {
+			c.lock.Unlock()
+			c.inner.Close()
+			return
+		}
+		msg := c.queue[0]
+		c.queue = c.queue[1:]
+		c.lock.Unlock()
+
+		if _, err := c.inner.Write(msg); err != nil {
+			return
+		}
+	}
+}
+
+// End the channel, because the other side hung up or (if not) ours did or
+// the connection failed. Returns true the first time, if the other side
+// hadn't hung up, as it then needs telling
+func (c *muxChannel) end(hungUp bool) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	defer c.ready.Signal()
+
+	if hungUp {
+		first := !c.hungUp && !c.ended
+		c.hungUp = true
+		return first
+	}
+	first := !c.ended && !c.hungUp
+	if !c.ended {
+		c.ended = true
+		c.inner.Close()
+	}
+	return first
+}
+
+// Our end of a channel, known by the connection's address and its player ID
+type muxConn struct {
+	net.Conn
+	addr muxAddr
+}
+
+func (c *muxConn) RemoteAddr() net.Addr {
+	return c.addr
+}
+
+type muxAddr string
+
+func (a muxAddr) Network() string {
+	return "mux"
+}
+
+func (a muxAddr) String() string {
+	return string(a)
+}
@@ -0,0 +1,94 @@
+package lib
+
+import (
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+// Two muxes over either end of a pipe
+func muxPair() (*Mux, *Mux) {
+	a, b := net.Pipe()
+	return NewMux(NewSession(a), a.RemoteAddr()), NewMux(NewSession(b), b.RemoteAddr())
+}
+
+// Messages on each channel reach the other side's channel with the same
+// player ID, and nowhere else
+func TestMux_Channels(t *testing.T) {
+	client, server := muxPair()
+	defer client.Close()
+	defer server.Close()
+
+	first, err := client.Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, _ := client.Open()
+	go NewSession(second).Send("rex")
+	go NewSession(first).Send("fido")
+
+	names := make(map[string]string)
+	for i := 0; i < 2; i++ {
+		conn, err := server.Accept()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var name string
+		session := NewSession(conn)
+		session.SetTimeout(time.Second)
+		if err := session.Receive(&name); err != nil {
+			t.Fatal(err)
+		}
+		names[conn.RemoteAddr().String()] = name
+		session.Send([]string{"hello", name})
+	}
+	if names["pipe#1"] != "fido" || names["pipe#2"] != "rex" {
+		t.Errorf("Wrong channels: %v", names)
+	}
+
+	var reply []string
+	if err := NewSession(second).ReceiveWithin(&reply, time.Second); err != nil || reply[1] != "rex" {
+		t.Errorf("Wrong reply on the second channel: %v (%v)", reply, err)
+	}
+}
+
+// Closing a channel ends it on the other side once everything sent on it
+// has been read, and closing the mux ends every channel
+func TestMux_Close(t *testing.T) {
+	client, server := muxPair()
+	defer server.Close()
+
+	conn, _ := client.Open()
+	kept, _ := client.Open()
+	NewSession(conn).Send("fido")
+	NewSession(kept).Send("rex")
+	conn.Close()
+
+	// Channels are accepted as their first messages arrive, in any order
+	accepted, _ := server.Accept()
+	other, _ := server.Accept()
+	if accepted.RemoteAddr().String() != "pipe#1" {
+		accepted, other = other, accepted
+	}
+	session := NewSession(accepted)
+	var name string
+	if err := session.ReceiveWithin(&name, time.Second); err != nil || name != "fido" {
+		t.Fatalf("Expected fido, got %q (%v)", name, err)
+	}
+	if err := session.ReceiveWithin(&name, time.Second); err != io.EOF {
+		t.Errorf("Expected the channel to end, got %v", err)
+	}
+
+	client.Close()
+	io.ReadAll(other)
+	if _, err := kept.Write([]byte(`"spot"` + "\n")); err == nil {
+		t.Error("Wrote to a channel of a closed mux")
+	}
+	if _, err := client.Open(); err == nil {
+		t.Error("Opened a channel on a closed mux")
+	}
+	if _, err := server.Accept(); err == nil {
+		t.Error("Accepted a channel once the connection was closed")
+	}
+}
@@ -0,0 +1,153 @@
+package lib
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"log"
+	"net"
+	"sync"
+	"time"
+)
+
+// How long closing a Session may spend sending what is left
+const CLOSE_TIMEOUT = time.Second
+
+// A JSON conversation over a single connection. It owns the only encoder and
+// decoder for the connection, so nothing the decoder reads ahead is lost
+// between messages. Each Send and Receive is bounded by the timeout, if any
+// and if the connection has deadlines, and every message can be logged as it
+// passes. Send is safe to call from several goroutines, Receive is not
+type Session struct {
+	conn    io.ReadWriter
+	writer  *bufio.Writer
+	encoder *json.Encoder
+	decoder *json.Decoder
+
+	// How long each Send and Receive may take, or 0 for no limit
+	timeout time.Duration
+
+	// Where messages are logged, and how this session is labelled there
+	logger *log.Logger
+	label  string
+
+	sendLock  sync.Mutex
+	closeOnce sync.Once
+	closeErr  error
+}
+
+// The deadlines of a net.Conn
+type deadliner interface {
+	SetReadDeadline(t time.Time) error
+	SetWriteDeadline(t time.Time) error
+}
+
+// Start a session over the given connection, usually a net.Conn
+func NewSession(conn io.ReadWriter) *Session {
+	writer := bufio.NewWriter(conn)
+	s := &Session{
+		conn:    conn,
+		writer:  writer,
+		encoder: json.NewEncoder(writer),
+		decoder: json.NewDecoder(conn),
+	}
+	if c, ok := conn.(net.Conn); ok && c.RemoteAddr() != nil {
+		s.label = c.RemoteAddr().String()
+	}
+	return s
+}
+
+// Bound each Send and Receive by the given duration (0 for no limit)
+func (s *Session) SetTimeout(timeout time.Duration) {
+	s.timeout = timeout
+}
+
+// Log every message sent (">") and received ("<") to the given logger, under
+// the given label. A nil logger stops logging
+func (s *Session) SetLogger(logger *log.Logger, label string) {
+	s.logger = logger
+	s.label = label
+}
+
+// Encode a message and send it right away
+func (s *Session) Send(msg interface{}) error {
+	s.sendLock.Lock()
+	defer s.sendLock.Unlock()
+
+	if d, ok := s.conn.(deadliner); ok && s.timeout > 0 {
+		d.SetWriteDeadline(time.Now().Add(s.timeout))
+		defer d.SetWriteDeadline(time.Time{})
+	}
+
+	if err := s.encoder.Encode(msg); err != nil {
+		s.writer.Reset(s.conn)
+		return err
+	}
+	if s.logger != nil {
+		buf, _ := json.Marshal(msg)
+		s.logger.Printf("%s > %s", s.label, buf)
+	}
+	return s.writer.Flush()
+}
+
+// Receive the next message into the target, within the session's timeout
+func (s *Session) Receive(target interface{}) error {
+	return s.ReceiveWithin(target, s.timeout)
+}
+
+// Receive the next message into the target, within the given duration
+// (0 for no limit). A message that doesn't fit the target is still consumed
+func (s *Session) ReceiveWithin(target interface{}, timeout time.Duration) error {
+	if d, ok := s.conn.(deadliner); ok && timeout > 0 {
+		d.SetReadDeadline(time.Now().Add(timeout))
+		defer d.SetReadDeadline(time.Time{})
+	}
+
+	var raw json.RawMessage
+	if err := s.decoder.Decode(&raw); err != nil {
+		return err
+	}
+	if s.logger != nil {
+		s.logger.Printf("%s < %s", s.label, raw)
+	}
+	return json.Unmarshal(raw, target)
+}
+
+// Flush anything unsent, within CLOSE_TIMEOUT, and close the connection, if
+// it can be closed. A Send stuck on a peer that isn't reading gives up rather
+// than holding the close up. Closing more than once does nothing, and returns
+// the first result
+func (s *Session) Close() error {
+	s.closeOnce.Do(func() {
+		d, deadlines := s.conn.(deadliner)
+		if deadlines {
+			d.SetWriteDeadline(time.Now().Add(CLOSE_TIMEOUT))
+		} else {
+			// Nothing else will stop a stuck Send
+			s.closeConn()
+		}
+
+		s.sendLock.Lock()
+		if deadlines {
+			// A Send may have cleared the deadline as it gave up
+			d.SetWriteDeadline(time.Now().Add(CLOSE_TIMEOUT))
+			s.writer.Flush()
+		}
+		s.sendLock.Unlock()
+
+		if s.logger != nil {
+			s.logger.Printf("%s closed", s.label)
+		}
+		if deadlines {
+			s.closeConn()
+		}
+	})
+	return s.closeErr
+}
+
+// Close the connection, if it can be closed
+func (s *Session) closeConn() {
+	if c, ok := s.conn.(io.Closer); ok {
+		s.closeErr = c.Close()
+	}
+}
@@ -0,0 +1,81 @@
+package lib
+
+import (
+	"bytes"
+	"log"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Two messages arriving in one read should both be received
+func TestSession_BackToBack(t *testing.T) {
+	in := bytes.NewBufferString(`"uno" ["dos", 2]` + "\n")
+	s := NewSession(in)
+
+	var first string
+	var second []interface{}
+	if err := s.Receive(&first); err != nil || first != "uno" {
+		t.Fatalf("First message was %q: %v", first, err)
+	}
+	if err := s.Receive(&second); err != nil || len(second) != 2 {
+		t.Fatalf("Second message was %v: %v", second, err)
+	}
+}
+
+// Messages are logged both ways, and a slow peer times out
+func TestSession_LogAndTimeout(t *testing.T) {
+	server, client := net.Pipe()
+	defer client.Close()
+
+	var out bytes.Buffer
+	s := NewSession(server)
+	s.SetLogger(log.New(&out, "", 0), "fido")
+	s.SetTimeout(50 * time.Millisecond)
+
+	go NewSession(client).Send([]string{"playing-as", "fido"})
+	var msg []string
+	if err := s.Receive(&msg); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Send("hello"); err == nil {
+		t.Error("Send to a peer that never reads should time out")
+	}
+
+	if !strings.Contains(out.String(), `fido < ["playing-as","fido"]`) {
+		t.Errorf("Missing received message in log: %q", out.String())
+	}
+
+	s.Close()
+	if err := s.Close(); err != nil {
+		t.Errorf("Second close should return the first result: %v", err)
+	}
+}
+
+// Closing doesn't wait for a Send stuck on a peer that never reads
+func TestSession_CloseWhileSending(t *testing.T) {
+	server, client := net.Pipe()
+	defer client.Close()
+
+	s := NewSession(server)
+	sent := make(chan error, 1)
+	go func() {
+		sent <- s.Send("hello")
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	closed := make(chan bool)
+	go func() {
+		s.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(5 * CLOSE_TIMEOUT):
+		t.Fatal("Close waited on a stuck Send")
+	}
+	if err := <-sent; err == nil {
+		t.Error("Stuck Send should fail once closed")
+	}
+}
@@ -0,0 +1,140 @@
+package lib
+
+import (
+	"crypto/tls"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+// A server and client with self-signed certificates that trust each other
+func mutualTLS(t *testing.T) (TLSFiles, TLSFiles, func()) {
+	dir, err := ioutil.TempDir("", "tls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := GenerateSelfSigned(dir, "server", []string{"127.0.0.1", "localhost"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err := GenerateSelfSigned(dir, "client", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.CA, client.CA = client.Cert, server.Cert
+	return server, client, func() { os.RemoveAll(dir) }
+}
+
+// Accept one connection over TLS, echo one message back and report how the
+// handshake went
+func echoOnce(t *testing.T, files TLSFiles) (string, chan error) {
+	config, err := files.ServerConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := tls.Listen("tcp", "127.0.0.1:0", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result := make(chan error, 1)
+	go func() {
+		defer l.Close()
+		conn, err := l.Accept()
+		if err != nil {
+			result <- err
+			return
+		}
+		session := NewSession(conn)
+		session.SetTimeout(5 * time.Second)
+		defer session.Close()
+
+		var msg string
+		if err := session.Receive(&msg); err != nil {
+			result <- err
+			return
+		}
+		result <- session.Send(msg)
+	}()
+	return l.Addr().String(), result
+}
+
+func TestTLS_Mutual(t *testing.T) {
+	serverFiles, clientFiles, cleanup := mutualTLS(t)
+	defer cleanup()
+	address, result := echoOnce(t, serverFiles)
+
+	config, err := clientFiles.ClientConfig("127.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := Dial(address, config, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	session := NewSession(conn)
+	session.SetTimeout(5 * time.Second)
+	defer session.Close()
+
+	var echo string
+	if err := session.Send("hello"); err != nil {
+		t.Fatal(err)
+	}
+	if err := session.Receive(&echo); err != nil || echo != "hello" {
+		t.Fatalf("Expected an echo, got %q: %v", echo, err)
+	}
+	if err := <-result; err != nil {
+		t.Error(err)
+	}
+}
+
+// A server that verifies client certificates turns away a client without one
+func TestTLS_NoClientCert(t *testing.T) {
+	serverFiles, clientFiles, cleanup := mutualTLS(t)
+	defer cleanup()
+	address, result := echoOnce(t, serverFiles)
+
+	config, err := TLSFiles{CA: clientFiles.CA}.ClientConfig("127.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := Dial(address, config, time.Second)
+	if err == nil {
+		session := NewSession(conn)
+		session.SetTimeout(5 * time.Second)
+		session.Send("hello")
+		var echo string
+		err = session.Receive(&echo)
+		session.Close()
+	}
+	if err == nil {
+		t.Error("Client without a certificate was served")
+	}
+	if err := <-result; err == nil {
+		t.Error("Server accepted a client without a certificate")
+	}
+}
+
+// A client that doesn't trust the server's certificate won't talk to it
+func TestTLS_UntrustedServer(t *testing.T) {
+	serverFiles, _, cleanup := mutualTLS(t)
+	defer cleanup()
+	serverFiles.CA = ""
+	address, _ := echoOnce(t, serverFiles)
+
+	config, err := TLSFiles{}.ClientConfig("127.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conn, err := Dial(address, config, time.Second); err == nil {
+		conn.Close()
+		t.Error("Trusted a self-signed server certificate")
+	}
+}
+
+func TestTLS_ServerNeedsCert(t *testing.T) {
+	if _, err := (TLSFiles{CA: "ca.pem"}).ServerConfig(); err == nil {
+		t.Error("Server configured without a certificate")
+	}
+}
@@ -0,0 +1,313 @@
+package lib
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Transcripts record every message over a connection, in both directions, as
+// JSON lines of TranscriptEntry. A Replayer plays one side of a recorded
+// transcript back over a new connection, checking the other side says what
+// it said before
+
+// Directions of a message in a transcript, from the recording side's view
+const (
+	SENT     = ">"
+	RECEIVED = "<"
+)
+
+// One message over a recorded connection. A direction that stops being valid
+// JSON is recorded once with the Error, and no more after that
+type TranscriptEntry struct {
+	Time      time.Time       `json:"time"`
+	Direction string          `json:"dir"`
+	Message   json.RawMessage `json:"msg,omitempty"`
+	Error     string          `json:"error,omitempty"`
+}
+
+// A connection that records the messages over it, as each is read or written
+type recordedConn struct {
+	net.Conn
+
+	lock           sync.Mutex
+	sent, received *direction
+	transcript     io.WriteCloser
+	encoder        *json.Encoder
+
+	closeOnce sync.Once
+	closeErr  error
+}
+
+// The bytes going one way that aren't yet a whole message
+type direction struct {
+	name    string
+	pending []byte
+
+	// Whether the bytes stopped being JSON, so aren't recorded any more
+	broken bool
+}
+
+// Record every message over the connection to the transcript, which is closed
+// along with the connection
+func RecordConn(conn net.Conn, transcript io.WriteCloser) net.Conn {
+	return &recordedConn{
+		Conn:       conn,
+		sent:       &direction{name: SENT},
+		received:   &direction{name: RECEIVED},
+		transcript: transcript,
+		encoder:    json.NewEncoder(transcript),
+	}
+}
+
+// Record every message over the connection to a new transcript file in the
+// given directory, named after the label and the time
+func RecordConnTo(conn net.Conn, dir, label string) (net.Conn, error) {
+	transcript, err := OpenTranscript(dir, label)
+	if err != nil {
+		return conn, err
+	}
+	return RecordConn(conn, transcript), nil
+}
+
+// Create a new transcript file in the given directory, named after the label
+// and the time
+func OpenTranscript(dir, label string) (io.WriteCloser, error) {
+	name := strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`/\:*?"<>| `, r) {
+			return '_'
+		}
+		return r
+	}, label)
+	path := filepath.Join(dir, fmt.Sprintf("%s-%s.jsonl", name, time.Now().Format("20060102-150405.000000000")))
+	return os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+}
+
+// Read every entry of a transcript
+func ReadTranscript(r io.Reader) ([]TranscriptEntry, error) {
+	entries := make([]TranscriptEntry, 0)
+	decoder := json.NewDecoder(bufio.NewReader(r))
+	for {
+		var entry TranscriptEntry
+		if err := decoder.Decode(&entry); err == io.EOF {
+			return entries, nil
+		} else if err != nil {
+			return entries, err
+		}
+		entries = append(entries, entry)
+	}
+}
+
+// Read every entry of the transcript file at the given path
+func LoadTranscript(path string) ([]TranscriptEntry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return ReadTranscript(file)
+}
+
+// Record every whole message the bytes going the given way complete
+func (r *recordedConn) record(d *direction, p []byte) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if d.broken {
+		return
+	}
+
+	d.pending = append(d.pending, p...)
+	for len(bytes.TrimSpace(d.pending)) > 0 {
+		decoder := json.NewDecoder(bytes.NewReader(d.pending))
+		var msg json.RawMessage
+		err := decoder.Decode(&msg)
+		if err == io.ErrUnexpectedEOF {
+			return
+		} else if err != nil {
+			r.encoder.Encode(TranscriptEntry{Time: time.Now(), Direction: d.name, Error: err.Error()})
+			d.broken, d.pending = true, nil
+			return
+		}
+		r.encoder.Encode(TranscriptEntry{Time: time.Now(), Direction: d.name, Message: msg})
+		d.pending = d.pending[decoder.InputOffset():]
+	}
+	d.pending = nil
+}
+
+func (r *recordedConn) Read(p []byte) (int, error) {
+	n, err := r.Conn.Read(p)
+	if n > 0 {
+		r.record(r.received, p[:n])
+	}
+	return n, err
+}
+
+func (r *recordedConn) Write(p []byte) (int, error) {
+	n, err := r.Conn.Write(p)
+	if n > 0 {
+		r.record(r.sent, p[:n])
+	}
+	return n, err
+}
+
+// Close the connection and the transcript
+func (r *recordedConn) Close() error {
+	r.closeOnce.Do(func() {
+		r.closeErr = r.Conn.Close()
+		r.lock.Lock()
+		defer r.lock.Unlock()
+		r.transcript.Close()
+	})
+	return r.closeErr
+}
+
+// The first message the other side didn't send as recorded
+type Divergence struct {
+	// Which entry of the transcript it was
+	Index int
+
+	Expected json.RawMessage
+	Got      json.RawMessage
+
+	// Why nothing was received, if nothing was
+	Err error
+}
+
+func (d Divergence) Error() string {
+	if d.Err != nil {
+		return fmt.Sprintf("entry %d: expected %s, but got %v", d.Index, d.Expected, d.Err)
+	}
+	return fmt.Sprintf("entry %d: expected %s, but got %s", d.Index, d.Expected, d.Got)
+}
+
+// Plays the recording side of a transcript: sends what it sent, and expects
+// to receive what it received, in the same order
+type Replayer struct {
+	entries []TranscriptEntry
+
+	// How long to wait for each message, or 0 for no limit
+	timeout time.Duration
+
+	// Fields whose values may differ from the recording, e.g. session tokens
+	ignored map[string]bool
+
+	// Types of message that may come and go, e.g. waiting room updates
+	skipped map[string]bool
+}
+
+func NewReplayer(entries []TranscriptEntry) Replayer {
+	return Replayer{entries: entries, ignored: make(map[string]bool), skipped: make(map[string]bool)}
+}
+
+// A copy of this Replayer waiting at most the given duration for each message
+func (r Replayer) WithTimeout(timeout time.Duration) Replayer {
+	r.timeout = timeout
+	return r
+}
+
+// A copy of this Replayer that lets the named fields, in any object in a
+// message, differ from the recording
+func (r Replayer) Ignoring(fields ...string) Replayer {
+	ignored := make(map[string]bool)
+	for field := range r.ignored {
+		ignored[field] = true
+	}
+	for _, field := range fields {
+		ignored[field] = true
+	}
+	r.ignored = ignored
+	return r
+}
+
+// A copy of this Replayer that neither sends nor expects messages with the
+// given "type"s, which depend on timing rather than the conversation
+func (r Replayer) Skipping(kinds ...string) Replayer {
+	skipped := make(map[string]bool)
+	for kind := range r.skipped {
+		skipped[kind] = true
+	}
+	for _, kind := range kinds {
+		skipped[kind] = true
+	}
+	r.skipped = skipped
+	return r
+}
+
+// Replay the transcript over the connection, returning a Divergence at the
+// first message received that differs from the recording, or nil once every
+// message is replayed. The connection is left open
+func (r Replayer) Replay(conn net.Conn) error {
+	session := NewSession(conn)
+	session.SetTimeout(r.timeout)
+
+	for i, entry := range r.entries {
+		if entry.Message == nil || r.skip(entry.Message) {
+			continue
+		}
+
+		if entry.Direction == SENT {
+			if err := session.Send(entry.Message); err != nil {
+				return err
+			}
+			continue
+		}
+
+		var got json.RawMessage
+		for {
+			if err := session.Receive(&got); err != nil {
+				return Divergence{Index: i, Expected: entry.Message, Err: err}
+			} else if !r.skip(got) {
+				break
+			}
+		}
+		if !r.same(entry.Message, got) {
+			return Divergence{Index: i, Expected: entry.Message, Got: got}
+		}
+	}
+	return nil
+}
+
+// Whether the message has a "type" being skipped
+func (r Replayer) skip(msg json.RawMessage) bool {
+	var typed struct {
+		Type string `json:"type"`
+	}
+	return len(r.skipped) > 0 && json.Unmarshal(msg, &typed) == nil && r.skipped[typed.Type]
+}
+
+// Whether two messages hold the same JSON, but for ignored fields
+func (r Replayer) same(a, b json.RawMessage) bool {
+	var x, y interface{}
+	if json.Unmarshal(a, &x) != nil || json.Unmarshal(b, &y) != nil {
+		return false
+	}
+	return reflect.DeepEqual(r.strip(x), r.strip(y))
+}
+
+// The value with every ignored field removed, at any depth
+func (r Replayer) strip(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, field := range v {
+			if r.ignored[key] {
+				delete(v, key)
+			} else {
+				v[key] = r.strip(field)
+			}
+		}
+	case []interface{}:
+		for i := range v {
+			v[i] = r.strip(v[i])
+		}
+	}
+	return value
+}
@@ -0,0 +1,78 @@
+package lib
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"testing"
+	"time"
+)
+
+type closingBuffer struct{ bytes.Buffer }
+
+func (b *closingBuffer) Close() error { return nil }
+
+// Messages both ways are recorded in order, as are bytes that aren't JSON
+func TestRecordConn(t *testing.T) {
+	server, client := net.Pipe()
+	transcript := &closingBuffer{}
+	recorded := RecordConn(server, transcript)
+
+	go func() {
+		json.NewEncoder(client).Encode([]string{"hello"})
+		buf := make([]byte, 64)
+		client.Read(buf)
+		client.Write([]byte("}oops"))
+		client.Close()
+	}()
+
+	session := NewSession(recorded)
+	var hello []string
+	session.Receive(&hello)
+	session.Send(map[string]int{"seq": 1})
+	ioutil.ReadAll(recorded)
+	recorded.Close()
+
+	entries, err := ReadTranscript(&transcript.Buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("Expected 3 entries, got %+v", entries)
+	}
+	if entries[0].Direction != RECEIVED || string(entries[0].Message) != `["hello"]` {
+		t.Errorf("Wrong first entry %+v", entries[0])
+	}
+	if entries[1].Direction != SENT || string(entries[1].Message) != `{"seq":1}` {
+		t.Errorf("Wrong second entry %+v", entries[1])
+	}
+	if entries[2].Direction != RECEIVED || entries[2].Error == "" {
+		t.Errorf("Expected the bad JSON to be an error, got %+v", entries[2])
+	}
+}
+
+// A replay ignores what it's told to, and stops at the first difference
+func TestReplayer(t *testing.T) {
+	entries := []TranscriptEntry{
+		{Direction: SENT, Message: json.RawMessage(`{"type":"welcome","token":"abc"}`)},
+		{Direction: RECEIVED, Message: json.RawMessage(`{"type":"turn","seq":1}`)},
+		{Direction: RECEIVED, Message: json.RawMessage(`{"type":"turn","seq":2}`)},
+	}
+
+	server, client := net.Pipe()
+	go func() {
+		peer := NewSession(client)
+		var welcome json.RawMessage
+		peer.Receive(&welcome)
+		peer.Send(map[string]interface{}{"type": "waiting"})
+		peer.Send(map[string]interface{}{"type": "turn", "seq": 1, "token": "xyz"})
+		peer.Send(map[string]interface{}{"type": "turn", "seq": 3})
+	}()
+
+	err := NewReplayer(entries).WithTimeout(time.Second).Ignoring("token").Skipping("waiting").Replay(server)
+	divergence, ok := err.(Divergence)
+	if !ok || divergence.Index != 2 || string(divergence.Got) != `{"seq":3,"type":"turn"}` {
+		t.Errorf("Expected the third entry to diverge, got %v", err)
+	}
+}
@@ -0,0 +1,186 @@
+package lib
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// A minimal server side of the WebSocket protocol (RFC 6455), enough to push
+// text messages to a browser and notice when it goes away
+
+// GUID every WebSocket server appends to the client's key during the handshake
+const WEBSOCKET_GUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// WebSocket frame opcodes
+const (
+	wsText  = 0x1
+	wsClose = 0x8
+	wsPing  = 0x9
+	wsPong  = 0xA
+)
+
+// The largest frame we will accept from a client
+const wsMaxPayload = 1 << 16
+
+var WEBSOCKET_HANDSHAKE_ERR = errors.New("Not a WebSocket handshake")
+
+// A WebSocket connection upgraded from an HTTP request
+type WebSocket struct {
+	conn   net.Conn
+	reader *bufio.Reader
+
+	// Guards writes, which may come from both the reader (pongs) and writers
+	lock sync.Mutex
+}
+
+// The Sec-WebSocket-Accept value for a client's Sec-WebSocket-Key
+func WebSocketAccept(key string) string {
+	hash := sha1.Sum([]byte(key + WEBSOCKET_GUID))
+	return base64.StdEncoding.EncodeToString(hash[:])
+}
+
+// Complete the WebSocket handshake for the given request, taking over its connection
+func UpgradeWebSocket(w http.ResponseWriter, r *http.Request) (*WebSocket, error) {
+	key := r.Header.Get("Sec-WebSocket-Key")
+	upgrade := strings.ToLower(r.Header.Get("Upgrade"))
+	connection := strings.ToLower(r.Header.Get("Connection"))
+	if key == "" || upgrade != "websocket" || !strings.Contains(connection, "upgrade") {
+		http.Error(w, WEBSOCKET_HANDSHAKE_ERR.Error(), http.StatusBadRequest)
+		return nil, WEBSOCKET_HANDSHAKE_ERR
+	}
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "WebSockets unsupported", http.StatusInternalServerError)
+		return nil, errors.New("ResponseWriter cannot be hijacked")
+	}
+	conn, buffered, err := hijacker.Hijack()
+	if err != nil {
+		return nil, err
+	}
+
+	response := "HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + WebSocketAccept(key) + "\r\n\r\n"
+	if _, err := conn.Write([]byte(response)); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return &WebSocket{conn: conn, reader: buffered.Reader}, nil
+}
+
+// Send a single text message
+func (ws *WebSocket) WriteText(msg []byte) error {
+	return ws.writeFrame(wsText, msg)
+}
+
+// Read until the next text message, answering pings along the way.
+// Returns io.EOF once the client closes the connection
+func (ws *WebSocket) ReadText() ([]byte, error) {
+	for {
+		opcode, payload, err := ws.readFrame()
+		if err != nil {
+			return nil, err
+		}
+
+		switch opcode {
+		case wsText:
+			return payload, nil
+		case wsPing:
+			if err := ws.writeFrame(wsPong, payload); err != nil {
+				return nil, err
+			}
+		case wsClose:
+			ws.writeFrame(wsClose, nil)
+			return nil, io.EOF
+		}
+	}
+}
+
+// Close the underlying connection
+func (ws *WebSocket) Close() error {
+	return ws.conn.Close()
+}
+
+// Write a single, final, unmasked frame (servers never mask)
+func (ws *WebSocket) writeFrame(opcode byte, payload []byte) error {
+	ws.lock.Lock()
+	defer ws.lock.Unlock()
+
+	header := []byte{0x80 | opcode}
+	length := len(payload)
+	switch {
+	case length < 126:
+		header = append(header, byte(length))
+	case length <= 0xFFFF:
+		header = append(header, 126, 0, 0)
+		binary.BigEndian.PutUint16(header[2:], uint16(length))
+	default:
+		header = append(header, 127, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(header[2:], uint64(length))
+	}
+
+	if _, err := ws.conn.Write(header); err != nil {
+		return err
+	}
+	_, err := ws.conn.Write(payload)
+	return err
+}
+
+// Read a single frame from the client, unmasking its payload
+// NOTE fragmented messages are treated as separate frames
+func (ws *WebSocket) readFrame() (byte, []byte, error) {
+	var header [2]byte
+	if _, err := io.ReadFull(ws.reader, header[:]); err != nil {
+		return 0, nil, err
+	}
+	opcode := header[0] & 0x0F
+	masked := header[1]&0x80 != 0
+	length := uint64(header[1] & 0x7F)
+
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(ws.reader, ext[:]); err != nil {
+			return 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(ws.reader, ext[:]); err != nil {
+			return 0, nil, err
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+	if length > wsMaxPayload {
+		return 0, nil, errors.New("WebSocket frame too large")
+	}
+
+	var mask [4]byte
+	if masked {
+		if _, err := io.ReadFull(ws.reader, mask[:]); err != nil {
+			return 0, nil, err
+		}
+	}
+
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(ws.reader, payload); err != nil {
+		return 0, nil, err
+	}
+	if masked {
+		for i := range payload {
+			payload[i] ^= mask[i%4]
+		}
+	}
+	return opcode, payload, nil
+}
@@ -0,0 +1,117 @@
+package web
+
+// The page served by a Spectator: a list of games, and a board for the one
+// being watched
+const INDEX_PAGE = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Santorini Spectator</title>
+<style>
+  body { font-family: sans-serif; margin: 2em; }
+  #games li { cursor: pointer; margin: 0.2em 0; }
+  #games li.watching { font-weight: bold; }
+  table { border-collapse: collapse; }
+  td { width: 3.5em; height: 3.5em; border: 1px solid #444; text-align: center; vertical-align: middle; }
+  td.h0 { background: #f7f7f7; } td.h1 { background: #d9e6f2; }
+  td.h2 { background: #a9c8e6; } td.h3 { background: #6fa3d6; }
+  td.h4 { background: #1d3f66; color: #fff; }
+  .worker { font-weight: bold; color: #a00; }
+  #log { font-family: monospace; white-space: pre; max-height: 20em; overflow-y: auto; }
+</style>
+</head>
+<body>
+<h1>Santorini Spectator</h1>
+<h2>Games</h2>
+<ul id="games"><li>No games yet</li></ul>
+<h2 id="title">Pick a game to watch</h2>
+<table id="board"></table>
+<p id="status"></p>
+<div id="log"></div>
+<script>
+var socket = null;
+var watching = null;
+
+function refreshGames() {
+  fetch("/games").then(function (r) { return r.json(); }).then(function (games) {
+    var list = document.getElementById("games");
+    list.innerHTML = "";
+    if (!games || games.length === 0) {
+      list.innerHTML = "<li>No games yet</li>";
+      return;
+    }
+    games.forEach(function (g) {
+      var item = document.createElement("li");
+      var label = "#" + g.id + ": " + g.players.join(" vs ");
+      label += g.over ? " (" + g.winner + " won)" : " (playing)";
+      item.textContent = label;
+      if (g.id === watching) { item.className = "watching"; }
+      item.onclick = function () { watch(g); };
+      list.appendChild(item);
+    });
+  });
+}
+
+function watch(g) {
+  if (socket) { socket.close(); }
+  watching = g.id;
+  document.getElementById("title").textContent = "Game #" + g.id + ": " + g.players.join(" vs ");
+  document.getElementById("board").innerHTML = "";
+  document.getElementById("status").textContent = "";
+  document.getElementById("log").textContent = "";
+
+  var scheme = location.protocol === "https:" ? "wss://" : "ws://";
+  socket = new WebSocket(scheme + location.host + "/watch?game=" + g.id);
+  socket.onmessage = function (event) { receive(JSON.parse(event.data)); };
+  refreshGames();
+}
+
+function receive(update) {
+  var log = document.getElementById("log");
+  switch (update[0]) {
+  case "board":
+    drawBoard(update[1]);
+    break;
+  case "turn":
+    log.textContent += update[1] + " moved " + update[2].join(" ") + ", built " + update[3].join(" ") + "\n";
+    break;
+  case "winning-move":
+    log.textContent += update[1] + " moved " + update[2].join(" ") + " and won\n";
+    break;
+  case "endgame":
+    document.getElementById("status").textContent = update[1] + " beat " + update[2] + ": " + update[3];
+    refreshGames();
+    break;
+  }
+  log.scrollTop = log.scrollHeight;
+}
+
+function drawBoard(rows) {
+  var table = document.getElementById("board");
+  table.innerHTML = "";
+  rows.forEach(function (row) {
+    var tr = document.createElement("tr");
+    row.forEach(function (cell) {
+      var td = document.createElement("td");
+      var text = String(cell);
+      var height = text.charAt(0);
+      td.className = "h" + height;
+      td.appendChild(document.createTextNode(height));
+      if (text.length > 1) {
+        var worker = document.createElement("div");
+        worker.className = "worker";
+        worker.textContent = text.substring(1);
+        td.appendChild(worker);
+      }
+      tr.appendChild(td);
+    });
+    table.appendChild(tr);
+  });
+}
+
+refreshGames();
+setInterval(refreshGames, 2000);
+</script>
+</body>
+</html>
+`
@@ -0,0 +1,343 @@
+package web
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+
+	board "github.com/CS4500-F18/dare-rebr/Santorini/Common/Board"
+	data "github.com/CS4500-F18/dare-rebr/Santorini/Common/JSON"
+	rules "github.com/CS4500-F18/dare-rebr/Santorini/Common/Rules"
+	lib "github.com/CS4500-F18/dare-rebr/Santorini/Lib"
+)
+
+/*
+  The Spectator is an Observer that serves a web page for watching games live
+  in a browser. It serves:
+    - /            the page itself
+    - /games       a JSON list of games being played (or recently finished)
+    - /watch?game= a WebSocket streaming every update of a game, starting
+                   with everything that has happened in it so far
+
+  Updates are sent as the same tagged messages remote observers receive
+  (see Common/JSON), so ["board", Board], ["turn", ...], etc.
+
+  Games are told apart by the players in them: an update belongs to the
+  unfinished game involving its player, so one Spectator can watch several
+  games at once, as long as no player is in two games at once.
+*/
+
+// How many finished games are kept around to be watched
+const FINISHED_GAMES_KEPT = 20
+
+// How many updates may be waiting to be sent to a viewer before it is
+// considered too slow, and disconnected
+const VIEWER_QUEUE_SIZE = 256
+
+//NOTE implements IObserver interface
+type Spectator struct {
+	name     string
+	listener net.Listener
+
+	// Guards games and everything within them
+	lock   sync.Mutex
+	games  []*game
+	nextID int
+}
+
+// A single game being watched
+type game struct {
+	ID      int      `json:"id"`
+	Players []string `json:"players"`
+	Over    bool     `json:"over"`
+	Winner  string   `json:"winner,omitempty"`
+
+	// Every update so far, encoded, for viewers who join late
+	history [][]byte
+
+	viewers map[*viewer]bool
+}
+
+// A browser watching a game
+type viewer struct {
+	ws    *lib.WebSocket
+	queue chan []byte
+}
+
+// Create a Spectator and start serving its page on the given address
+// (for example "localhost:8000")
+func NewSpectator(name, addr string) (*Spectator, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Spectator{name: name, listener: listener, games: make([]*game, 0)}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", s.serveIndex)
+	mux.HandleFunc("/games", s.serveGames)
+	mux.HandleFunc("/watch", s.serveWatch)
+	go http.Serve(listener, mux)
+
+	return s, nil
+}
+
+// The address the page is served on
+func (s *Spectator) Addr() string {
+	return s.listener.Addr().String()
+}
+
+// Stop serving the page, disconnecting every viewer
+func (s *Spectator) Shutdown() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for _, g := range s.games {
+		for v := range g.viewers {
+			g.dropViewer(v)
+		}
+	}
+	return s.listener.Close()
+}
+
+/*########## OBSERVER ##########*/
+
+func (s *Spectator) Name() string {
+	return s.name
+}
+
+//Receive an updated board
+func (s *Spectator) ReceiveBoard(b board.IBoard) {
+	players := b.Players()
+	if len(players) == 0 {
+		// Nobody has placed a worker yet, so we can't tell whose game this is
+		return
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	g := s.gameFor(players...)
+	g.publish(data.Observation{Kind: data.OBSERVE_BOARD, Board: b})
+}
+
+//Receive the final Move that wins a Game
+func (s *Spectator) ReceiveWinningMove(move data.MoveJSON) {
+	player, _, err := board.ParseWorkerName(move.WorkerName)
+	if err != nil {
+		return
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.gameFor(player).publish(data.Observation{Kind: data.OBSERVE_WINNING_MOVE, Move: move})
+}
+
+//Receive a full Turn performed
+func (s *Spectator) ReceiveTurn(turn data.MoveBuildJSON) {
+	player, _, err := board.ParseWorkerName(turn.WorkerName)
+	if err != nil {
+		return
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.gameFor(player).publish(data.Observation{Kind: data.OBSERVE_TURN, Turn: turn})
+}
+
+//Receive an endgame state
+func (s *Spectator) ReceiveEndgame(end rules.GameResult) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	g := s.gameFor(end.Winner, end.Loser)
+	g.publish(data.Observation{Kind: data.OBSERVE_ENDGAME, Result: end})
+	g.Over = true
+	g.Winner = end.Winner
+	s.forgetOldGames()
+}
+
+// Find the unfinished game involving any of the given players, starting a new
+// game if there is none
+// NOTE the lock must be held
+func (s *Spectator) gameFor(players ...string) *game {
+	for _, g := range s.games {
+		if g.Over {
+			continue
+		}
+		for _, player := range players {
+			if lib.StringPresent(g.Players, player) {
+				g.addPlayers(players)
+				return g
+			}
+		}
+	}
+
+	s.nextID++
+	g := &game{
+		ID:      s.nextID,
+		Players: make([]string, 0),
+		history: make([][]byte, 0),
+		viewers: make(map[*viewer]bool),
+	}
+	g.addPlayers(players)
+	s.games = append(s.games, g)
+	return g
+}
+
+// Forget the oldest finished games once there are too many
+// NOTE the lock must be held
+func (s *Spectator) forgetOldGames() {
+	finished := 0
+	for _, g := range s.games {
+		if g.Over {
+			finished++
+		}
+	}
+
+	kept := make([]*game, 0)
+	for _, g := range s.games {
+		if g.Over && finished > FINISHED_GAMES_KEPT && len(g.viewers) == 0 {
+			finished--
+			continue
+		}
+		kept = append(kept, g)
+	}
+	s.games = kept
+}
+
+// Record any new players in this game
+func (g *game) addPlayers(players []string) {
+	for _, player := range players {
+		if player != "" && !lib.StringPresent(g.Players, player) {
+			g.Players = append(g.Players, player)
+		}
+	}
+}
+
+// Record an update, and send it to everyone watching
+// NOTE the Spectator's lock must be held
+func (g *game) publish(update data.Observation) {
+	msg, err := json.Marshal(update)
+	if err != nil {
+		return
+	}
+	g.history = append(g.history, msg)
+
+	for v := range g.viewers {
+		select {
+		case v.queue <- msg:
+		default:
+			g.dropViewer(v)
+		}
+	}
+}
+
+// Stop sending updates to a viewer, and disconnect them
+// NOTE the Spectator's lock must be held
+func (g *game) dropViewer(v *viewer) {
+	if _, ok := g.viewers[v]; ok {
+		delete(g.viewers, v)
+		close(v.queue)
+	}
+}
+
+/*########## HTTP ##########*/
+
+// Serve the page for watching games
+func (s *Spectator) serveIndex(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write([]byte(INDEX_PAGE))
+}
+
+// Serve the list of games as JSON
+func (s *Spectator) serveGames(w http.ResponseWriter, r *http.Request) {
+	s.lock.Lock()
+	buf, err := json.Marshal(s.games)
+	s.lock.Unlock()
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(buf)
+}
+
+// Upgrade to a WebSocket, and stream a game's updates over it
+func (s *Spectator) serveWatch(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.URL.Query().Get("game"))
+	if err != nil {
+		http.Error(w, "Missing or invalid game ID", http.StatusBadRequest)
+		return
+	}
+
+	s.lock.Lock()
+	g := s.findGame(id)
+	s.lock.Unlock()
+	if g == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	ws, err := lib.UpgradeWebSocket(w, r)
+	if err != nil {
+		return
+	}
+
+	v := &viewer{ws: ws, queue: make(chan []byte, VIEWER_QUEUE_SIZE)}
+
+	s.lock.Lock()
+	history := g.history
+	g.viewers[v] = true
+	s.lock.Unlock()
+
+	go s.readUntilClosed(g, v)
+
+	for _, msg := range history {
+		if err := ws.WriteText(msg); err != nil {
+			s.leave(g, v)
+			break
+		}
+	}
+	for msg := range v.queue {
+		if err := ws.WriteText(msg); err != nil {
+			s.leave(g, v)
+		}
+	}
+	ws.Close()
+}
+
+// Wait for the viewer to go away
+func (s *Spectator) readUntilClosed(g *game, v *viewer) {
+	for {
+		if _, err := v.ws.ReadText(); err != nil {
+			s.leave(g, v)
+			return
+		}
+	}
+}
+
+// Remove a viewer from the game they were watching
+func (s *Spectator) leave(g *game, v *viewer) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	g.dropViewer(v)
+}
+
+// Find the game with the given ID, or nil if there is none
+// NOTE the lock must be held
+func (s *Spectator) findGame(id int) *game {
+	for _, g := range s.games {
+		if g.ID == id {
+			return g
+		}
+	}
+	return nil
+}
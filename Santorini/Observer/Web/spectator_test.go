@@ -0,0 +1,142 @@
+package web
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+
+	board "github.com/CS4500-F18/dare-rebr/Santorini/Common/Board"
+	data "github.com/CS4500-F18/dare-rebr/Santorini/Common/JSON"
+	rules "github.com/CS4500-F18/dare-rebr/Santorini/Common/Rules"
+	lib "github.com/CS4500-F18/dare-rebr/Santorini/Lib"
+)
+
+// A board with a single worker placed for each of the given players
+func boardFor(players ...string) board.IBoard {
+	b := board.IBoard(board.BaseBoard())
+	for idx, player := range players {
+		b, _ = b.PlaceWorker(board.Pos{X: idx, Y: idx}, player)
+	}
+	return b
+}
+
+func newSpectator(t *testing.T) *Spectator {
+	s, err := NewSpectator("fido", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// The accept key from the example handshake in RFC 6455
+func TestWebSocketAccept(t *testing.T) {
+	if accept := lib.WebSocketAccept("dGhlIHNhbXBsZSBub25jZQ=="); accept != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Errorf("Wrong accept key: %s", accept)
+	}
+}
+
+// Updates for different players should be split into different games,
+// and a game should be over once its endgame arrives
+func TestSpectator_ConcurrentGames(t *testing.T) {
+	s := newSpectator(t)
+	defer s.Shutdown()
+
+	s.ReceiveBoard(board.BaseBoard())
+	s.ReceiveBoard(boardFor("uno"))
+	s.ReceiveBoard(boardFor("tres"))
+	s.ReceiveBoard(boardFor("uno", "dos"))
+	s.ReceiveTurn(data.MoveBuildJSON{WorkerName: "tres1"})
+	s.ReceiveEndgame(rules.GameResult{Winner: "dos", Loser: "uno"})
+
+	if len(s.games) != 2 {
+		t.Fatalf("Expected 2 games, got %v", len(s.games))
+	}
+
+	first, second := s.games[0], s.games[1]
+	if strings.Join(first.Players, ",") != "uno,dos" || !first.Over || first.Winner != "dos" {
+		t.Errorf("First game is wrong: %+v", first)
+	}
+	if strings.Join(second.Players, ",") != "tres" || second.Over || len(second.history) != 2 {
+		t.Errorf("Second game is wrong: %+v", second)
+	}
+
+	// uno's next game is a new one
+	s.ReceiveBoard(boardFor("uno"))
+	if len(s.games) != 3 {
+		t.Errorf("Expected a new game after the first ended, got %v games", len(s.games))
+	}
+}
+
+// A viewer should receive everything that happened in a game before they
+// joined, and then every new update
+func TestSpectator_Watch(t *testing.T) {
+	s := newSpectator(t)
+	defer s.Shutdown()
+
+	s.ReceiveBoard(boardFor("uno", "dos"))
+
+	resp, err := http.Get("http://" + s.Addr() + "/games")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var games []game
+	json.NewDecoder(resp.Body).Decode(&games)
+	resp.Body.Close()
+	if len(games) != 1 || games[0].ID != 1 {
+		t.Fatalf("Expected game 1 to be listed, got %+v", games)
+	}
+
+	conn, err := net.Dial("tcp", s.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.Write([]byte("GET /watch?game=1 HTTP/1.1\r\nHost: " + s.Addr() + "\r\n" +
+		"Upgrade: websocket\r\nConnection: Upgrade\r\n" +
+		"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\nSec-WebSocket-Version: 13\r\n\r\n"))
+
+	reader := bufio.NewReader(conn)
+	status, _ := reader.ReadString('\n')
+	if !strings.Contains(status, "101") {
+		t.Fatalf("Expected to switch protocols, got %s", status)
+	}
+	for line, _ := reader.ReadString('\n'); line != "\r\n"; line, _ = reader.ReadString('\n') {
+	}
+
+	if kind := readKind(t, reader); kind != data.OBSERVE_BOARD {
+		t.Errorf("Expected the board so far first, got %s", kind)
+	}
+
+	s.ReceiveEndgame(rules.GameResult{Winner: "dos", Loser: "uno"})
+	if kind := readKind(t, reader); kind != data.OBSERVE_ENDGAME {
+		t.Errorf("Expected the endgame next, got %s", kind)
+	}
+}
+
+// Read a single small unmasked text frame, and return the kind of update in it
+func readKind(t *testing.T, r *bufio.Reader) string {
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(r, header); err != nil {
+		t.Fatal(err)
+	}
+	length := int(header[1] & 0x7F)
+	if length == 126 {
+		ext := make([]byte, 2)
+		io.ReadFull(r, ext)
+		length = int(ext[0])<<8 | int(ext[1])
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		t.Fatal(err)
+	}
+
+	var update data.Observation
+	if err := json.Unmarshal(payload, &update); err != nil {
+		t.Fatal(err)
+	}
+	return update.Kind
+}
@@ -0,0 +1,112 @@
+package observer
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	board "github.com/CS4500-F18/dare-rebr/Santorini/Common/Board"
+)
+
+// ANSI escape codes used to highlight cells
+const (
+	ansiReset  = "\x1b[0m"
+	ansiMoved  = "\x1b[1;33m" // bold yellow
+	ansiBuilt  = "\x1b[1;36m" // bold cyan
+	ansiWorker = "\x1b[1m"    // bold
+)
+
+// Markers for highlighted cells, used with or without color
+const (
+	MOVED_MARKER = "*"
+	BUILT_MARKER = "+"
+	DOME_LABEL   = "^^"
+)
+
+// Cells on a Board to draw attention to, such as the last move and build
+// Positions out of bounds are not highlighted
+type Highlight struct {
+	Moved board.Pos
+	Built board.Pos
+}
+
+// A Highlight with nothing highlighted
+func NoHighlight() Highlight {
+	return Highlight{Moved: board.Pos{X: -1, Y: -1}, Built: board.Pos{X: -1, Y: -1}}
+}
+
+// Render a Board as an ASCII grid, with X across and Y down. Each cell shows
+// its height (or a dome), the worker on it, and a marker if it is highlighted:
+//
+//        0      1
+//     +------+------+
+//   0 | 1 *  | 0    |
+//     | uno1 |      |
+//     +------+------+
+func RenderBoard(b board.IBoard, h Highlight, color bool) string {
+	width, height := b.Dimensions()
+
+	cellWidth := 4
+	for _, w := range b.Workers() {
+		if len(w.Name()) > cellWidth {
+			cellWidth = len(w.Name())
+		}
+	}
+
+	separator := "    +" + strings.Repeat(strings.Repeat("-", cellWidth+2)+"+", width) + "\n"
+
+	var out strings.Builder
+	out.WriteString("    ")
+	for x := 0; x < width; x++ {
+		out.WriteString(fmt.Sprintf(" %-*d", cellWidth+2, x))
+	}
+	out.WriteString("\n" + separator)
+
+	for y := 0; y < height; y++ {
+		top := fmt.Sprintf("%3d |", y)
+		bottom := "    |"
+
+		for x := 0; x < width; x++ {
+			pos := board.Pos{X: x, Y: y}
+			tile, err := b.TileAt(pos)
+			if err != nil {
+				continue
+			}
+
+			label := strconv.Itoa(tile.FloorCount())
+			if tile.FloorCount() >= board.MaxBuildingHeight {
+				label = DOME_LABEL
+			}
+
+			start, end := "", ""
+			if pos == h.Moved {
+				label += " " + MOVED_MARKER
+				start = ansiMoved
+			} else if pos == h.Built {
+				label += " " + BUILT_MARKER
+				start = ansiBuilt
+			}
+
+			name := ""
+			if worker := b.WorkerAt(pos); worker != nil {
+				name = worker.Name()
+				if start == "" {
+					start = ansiWorker
+				}
+			}
+
+			if color && start != "" {
+				end = ansiReset
+			} else {
+				start = ""
+			}
+
+			top += fmt.Sprintf(" %s%-*s%s |", start, cellWidth, label, end)
+			bottom += fmt.Sprintf(" %s%-*s%s |", start, cellWidth, name, end)
+		}
+
+		out.WriteString(top + "\n" + bottom + "\n" + separator)
+	}
+
+	return out.String()
+}
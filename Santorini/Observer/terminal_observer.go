@@ -0,0 +1,253 @@
+package observer
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	board "github.com/CS4500-F18/dare-rebr/Santorini/Common/Board"
+	output "github.com/CS4500-F18/dare-rebr/Santorini/Common/JSON"
+	rules "github.com/CS4500-F18/dare-rebr/Santorini/Common/Rules"
+)
+
+// Commands a user can type while watching in a TerminalObserver
+const (
+	// Step to the next update (an empty line)
+	STEP_CMD = ""
+	// Stop stepping, and play on until paused again
+	CONTINUE_CMD = "c"
+)
+
+const (
+	STEP_PROMPT     = "[enter] step, [c] continue: "
+	CONTINUE_PROMPT = "(playing, press enter to pause)\n"
+	clearScreen     = "\x1b[H\x1b[2J"
+)
+
+//An Observer that draws each board as an ASCII grid for a person to watch,
+//highlighting the last move and build, and keeping score of the series.
+//
+//If given an input to read commands from, it can step through a game one
+//update at a time: while stepping, it waits for a command after each update;
+//while playing, any line typed pauses it and goes back to stepping.
+//NOTE this blocks the Referee while waiting, so is meant for local games
+type TerminalObserver struct {
+	name   string
+	output io.Writer
+
+	// Held through each update, and each change of settings, so updates
+	// from several Referees don't interleave
+	lock sync.Mutex
+
+	// Lines typed by the user, or nil if there is no input
+	commands chan string
+
+	// Whether to wait for a command after each update
+	stepping bool
+
+	// How long to wait after each update while not stepping
+	delay time.Duration
+
+	// Whether to use ANSI colors and clear the screen between boards
+	color bool
+
+	// The last board seen, and what to highlight on the next one
+	last      board.IBoard
+	highlight Highlight
+	status    string
+
+	// The players in the current series, and their wins so far
+	series []string
+	score  map[string]int
+}
+
+// Create an observer that draws to the given output, reading step commands
+// from the given input (which may be nil, to never step)
+func NewTerminalObserver(name string, out io.Writer, in io.Reader) *TerminalObserver {
+	o := &TerminalObserver{
+		name:      name,
+		output:    out,
+		highlight: NoHighlight(),
+		series:    make([]string, 0),
+		score:     make(map[string]int),
+	}
+
+	if in != nil {
+		o.commands = make(chan string)
+		go readCommands(in, o.commands)
+	}
+	return o
+}
+
+// Wait for a command after every update (only if there is an input)
+func (o *TerminalObserver) SetStepping(stepping bool) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	o.stepping = stepping && o.commands != nil
+}
+
+// Wait the given duration after each update while not stepping
+func (o *TerminalObserver) SetDelay(delay time.Duration) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	o.delay = delay
+}
+
+// Use ANSI colors for highlights, and clear the screen between boards
+func (o *TerminalObserver) SetColor(color bool) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	o.color = color
+}
+
+func (o *TerminalObserver) Name() string {
+	return o.name
+}
+
+//Draw the updated board, highlighting the last turn taken
+func (o *TerminalObserver) ReceiveBoard(b board.IBoard) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	if players := b.Players(); len(players) == board.PlayerCount {
+		o.startSeries(players[0], players[1])
+	}
+
+	frame := ""
+	if o.color {
+		frame += clearScreen
+	}
+	frame += o.header() + "\n" + RenderBoard(b, o.highlight, o.color)
+	if o.status != "" {
+		frame += o.status + "\n"
+	}
+	io.WriteString(o.output, frame)
+
+	o.last = b
+	o.highlight = NoHighlight()
+	o.status = ""
+	o.pause()
+}
+
+//Highlight the winning move on the next board
+func (o *TerminalObserver) ReceiveWinningMove(move output.MoveJSON) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	if moved, ok := o.moveTarget(move.WorkerName, move.MoveDir); ok {
+		o.highlight.Moved = moved
+	}
+	o.status = fmt.Sprintf("%s moved %s and won", move.WorkerName, describe(move.MoveDir))
+}
+
+//Highlight the move and build on the next board
+func (o *TerminalObserver) ReceiveTurn(turn output.MoveBuildJSON) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	if moved, ok := o.moveTarget(turn.WorkerName, turn.MoveDir); ok {
+		o.highlight.Moved = moved
+		o.highlight.Built = output.PosFromDirection(moved, turn.BuildDir)
+	}
+	o.status = fmt.Sprintf("%s moved %s, built %s",
+		turn.WorkerName, describe(turn.MoveDir), describe(turn.BuildDir))
+}
+
+//Announce the end of a game, and the series score
+func (o *TerminalObserver) ReceiveEndgame(end rules.GameResult) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	o.startSeries(end.Winner, end.Loser)
+	o.score[end.Winner]++
+
+	if end.BrokenRule {
+		fmt.Fprintf(o.output, "%s lost: %s\n", end.Loser, end.Reason)
+	} else {
+		fmt.Fprintf(o.output, "%s won\n", end.Winner)
+	}
+	fmt.Fprintln(o.output, o.header())
+	o.pause()
+}
+
+// Start keeping score of a new series, unless it's between the current players
+func (o *TerminalObserver) startSeries(a, b string) {
+	if len(o.series) == 2 &&
+		((o.series[0] == a && o.series[1] == b) || (o.series[0] == b && o.series[1] == a)) {
+		return
+	}
+	o.series = []string{a, b}
+	o.score = map[string]int{a: 0, b: 0}
+}
+
+// The series score, e.g. "== uno 1 - 0 dos =="
+func (o *TerminalObserver) header() string {
+	if len(o.series) != 2 {
+		return "== " + o.name + " =="
+	}
+	a, b := o.series[0], o.series[1]
+	return fmt.Sprintf("== %s %d - %d %s ==", a, o.score[a], o.score[b], b)
+}
+
+// Where the named worker moves to in the given direction, from the last board
+func (o *TerminalObserver) moveTarget(workerName string, dir output.Direction) (board.Pos, bool) {
+	if o.last == nil {
+		return board.Pos{}, false
+	}
+	player, id, err := board.ParseWorkerName(workerName)
+	if err != nil {
+		return board.Pos{}, false
+	}
+	worker, err := o.last.FindWorker(player, id)
+	if err != nil {
+		return board.Pos{}, false
+	}
+	return output.PosFromDirection(worker.Pos(), dir), true
+}
+
+// Wait before the next update, according to the stepping mode
+func (o *TerminalObserver) pause() {
+	if o.commands == nil {
+		time.Sleep(o.delay)
+		return
+	}
+
+	if !o.stepping {
+		select {
+		case <-o.commands:
+			o.stepping = true
+		case <-time.After(o.delay):
+			return
+		}
+	}
+
+	io.WriteString(o.output, STEP_PROMPT)
+	cmd, ok := <-o.commands
+	if !ok {
+		// Input closed, play on without stepping
+		o.commands = nil
+		o.stepping = false
+		return
+	}
+	if cmd == CONTINUE_CMD {
+		o.stepping = false
+		io.WriteString(o.output, CONTINUE_PROMPT)
+	}
+}
+
+// Send each line of input, trimmed, to the commands channel until the input ends
+func readCommands(in io.Reader, commands chan string) {
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		commands <- strings.TrimSpace(scanner.Text())
+	}
+	close(commands)
+}
+
+// Describe a Direction for people, e.g. "EAST-NORTH"
+func describe(d output.Direction) string {
+	return d.EastWest + "-" + d.NorthSouth
+}
@@ -0,0 +1,107 @@
+package observer
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	board "github.com/CS4500-F18/dare-rebr/Santorini/Common/Board"
+	output "github.com/CS4500-F18/dare-rebr/Santorini/Common/JSON"
+	rules "github.com/CS4500-F18/dare-rebr/Santorini/Common/Rules"
+)
+
+// A board with uno's workers at (0, 0) and (1, 1), and dos's at (4, 4) and (5, 5)
+func setupBoard() board.IBoard {
+	b := board.IBoard(board.BaseBoard())
+	b, _ = b.PlaceWorker(board.Pos{X: 0, Y: 0}, "uno")
+	b, _ = b.PlaceWorker(board.Pos{X: 4, Y: 4}, "dos")
+	b, _ = b.PlaceWorker(board.Pos{X: 1, Y: 1}, "uno")
+	b, _ = b.PlaceWorker(board.Pos{X: 5, Y: 5}, "dos")
+	return b
+}
+
+// Rows are drawn top to bottom, with workers, domes and markers in their cells
+func TestRenderBoard(t *testing.T) {
+	b := setupBoard()
+	for i := 0; i < board.MaxBuildingHeight; i++ {
+		b, _ = b.AddFloor(board.Pos{X: 2, Y: 0})
+	}
+	b, _ = b.AddFloor(board.Pos{X: 0, Y: 1})
+
+	h := Highlight{Moved: board.Pos{X: 1, Y: 1}, Built: board.Pos{X: 0, Y: 1}}
+	lines := strings.Split(RenderBoard(b, h, false), "\n")
+
+	// header, separator, then two lines and a separator per row
+	row0 := lines[2] + lines[3]
+	row1 := lines[5] + lines[6]
+
+	if !strings.Contains(row0, "uno1") || !strings.Contains(row0, DOME_LABEL) {
+		t.Errorf("Row 0 should have uno1 and a dome: %q", row0)
+	}
+	if !strings.Contains(row1, "1 "+BUILT_MARKER) || !strings.Contains(row1, "0 "+MOVED_MARKER) {
+		t.Errorf("Row 1 should mark the build and the move: %q", row1)
+	}
+	if strings.Contains(RenderBoard(b, h, false), "\x1b[") {
+		t.Error("Board without color should have no escape codes")
+	}
+}
+
+// The score should follow the series, and reset for a new pair of players
+func TestTerminalObserver_Score(t *testing.T) {
+	var out strings.Builder
+	o := NewTerminalObserver("fido", &out, nil)
+
+	o.ReceiveBoard(setupBoard())
+	o.ReceiveTurn(output.MoveBuildJSON{
+		WorkerName: "uno2",
+		MoveDir:    output.Direction{EastWest: output.EAST, NorthSouth: output.PUT},
+		BuildDir:   output.Direction{EastWest: output.PUT, NorthSouth: output.SOUTH},
+	})
+	if o.highlight.Moved != (board.Pos{X: 2, Y: 1}) || o.highlight.Built != (board.Pos{X: 2, Y: 2}) {
+		t.Errorf("Turn highlighted the wrong cells: %+v", o.highlight)
+	}
+
+	o.ReceiveEndgame(rules.GameResult{Winner: "dos", Loser: "uno"})
+	if !strings.HasSuffix(out.String(), "== uno 0 - 1 dos ==\n") {
+		t.Errorf("Wrong score after one game: %q", out.String())
+	}
+
+	o.ReceiveEndgame(rules.GameResult{Winner: "tres", Loser: "dos"})
+	if !strings.HasSuffix(out.String(), "== tres 1 - 0 dos ==\n") {
+		t.Errorf("Score should reset for a new series: %q", out.String())
+	}
+}
+
+// Updates from several Referees at once are drawn one at a time
+func TestTerminalObserver_Concurrent(t *testing.T) {
+	var out strings.Builder
+	o := NewTerminalObserver("fido", &out, nil)
+
+	var wg sync.WaitGroup
+	for _, winner := range []string{"uno", "dos", "tres"} {
+		wg.Add(1)
+		go func(winner string) {
+			defer wg.Done()
+			o.ReceiveBoard(setupBoard())
+			o.ReceiveEndgame(rules.GameResult{Winner: winner, Loser: "cuatro"})
+		}(winner)
+	}
+	wg.Wait()
+
+	if boards := strings.Count(out.String(), "won\n"); boards != 3 {
+		t.Errorf("Expected 3 games drawn, got %v: %s", boards, out.String())
+	}
+}
+
+// A line typed while playing pauses, and "c" plays on
+func TestTerminalObserver_Pause(t *testing.T) {
+	var out strings.Builder
+	o := NewTerminalObserver("fido", &out, strings.NewReader("\nc\n"))
+	o.SetDelay(time.Second)
+
+	o.ReceiveBoard(setupBoard())
+	if !strings.HasSuffix(out.String(), CONTINUE_PROMPT) || !strings.Contains(out.String(), STEP_PROMPT) {
+		t.Errorf("Didn't pause and play on: %q", out.String())
+	}
+}
@@ -3,6 +3,7 @@ package client
 import (
 	board "github.com/CS4500-F18/dare-rebr/Santorini/Common/Board"
 	common "github.com/CS4500-F18/dare-rebr/Santorini/Common/Player"
+	rules "github.com/CS4500-F18/dare-rebr/Santorini/Common/Rules"
 	result "github.com/CS4500-F18/dare-rebr/Santorini/Common/Tournament"
 	strategy "github.com/CS4500-F18/dare-rebr/Santorini/Player/Strategy"
 )
@@ -49,6 +50,22 @@ func (p player) ReceiveTournamentResults(result []result.MatchResult) {
 	//There you go, enjoy.
 }
 
+func (p player) StartSeries(opponent string, games int) {
+	//SetOpponent has already told the strategy who it's up against.
+}
+
+func (p player) StartGame(game int, order int) {
+	//Our strategies don't care who goes first.
+}
+
+func (p player) OpponentTurn(b board.IBoard, t common.Turn) {
+	//Our strategies only look at the board they are given.
+}
+
+func (p player) EndGame(result rules.GameResult) {
+	//Win or lose, on to the next one.
+}
+
 func (p player) Opponent() string {
 	return p.opponent
 }
@@ -0,0 +1,280 @@
+package client
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	board "github.com/CS4500-F18/dare-rebr/Santorini/Common/Board"
+	common "github.com/CS4500-F18/dare-rebr/Santorini/Common/Player"
+	rules "github.com/CS4500-F18/dare-rebr/Santorini/Common/Rules"
+	result "github.com/CS4500-F18/dare-rebr/Santorini/Common/Tournament"
+)
+
+// How long an engine has to start, and to answer each request, by default.
+// It is under the time limit a tournament gives each local player, so a slow
+// engine is stopped before the tournament gives up on it
+const ENGINE_TIMEOUT = 5 * time.Second
+
+//A player whose placements and turns come from an external executable, such
+//as a bot written in another language, which speaks the engine protocol on
+//its stdin and stdout. An engine that misses a deadline, exits, or answers
+//with nonsense is stopped, and every placement and turn it is asked for from
+//then on is one no worker can make
+type enginePlayer struct {
+	name     string
+	opponent string
+	path     string
+	timeout  time.Duration
+
+	//the engine's stdin, and each line it writes, until it exits
+	lock  sync.Mutex
+	cmd   *exec.Cmd
+	input io.WriteCloser
+	lines chan string
+
+	//why the engine was stopped, once it has been
+	err error
+}
+
+//Creates a new player that launches the executable at the given path, giving
+//it the given time to start and to answer each request. Returns an error, and
+//no player, if the engine can't be launched or doesn't answer the handshake
+func EnginePlayer(name string, path string, timeout time.Duration) (common.IPlayer, error) {
+	p := &enginePlayer{name: name, path: path, timeout: timeout}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if err := p.start(); err != nil {
+		p.stop(err)
+		return nil, err
+	}
+	p.send(ENGINE_HELLO)
+	p.await(ENGINE_READY)
+	p.send(ENGINE_NAME, name)
+	if p.err != nil {
+		return nil, p.err
+	}
+	return p, nil
+}
+
+// Launch the engine, reading each line it writes until it exits
+func (p *enginePlayer) start() error {
+	p.cmd = exec.Command(p.path)
+	p.cmd.Stderr = os.Stderr
+	input, err := p.cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	output, err := p.cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := p.cmd.Start(); err != nil {
+		return err
+	}
+
+	p.input = input
+	p.lines = make(chan string)
+	go func() {
+		defer close(p.lines)
+		scanner := bufio.NewScanner(output)
+		for scanner.Scan() {
+			p.lines <- strings.TrimSpace(scanner.Text())
+		}
+	}()
+	return nil
+}
+
+// Stop the engine, if it is still running, for the given reason
+func (p *enginePlayer) stop(err error) {
+	if p.err != nil {
+		return
+	}
+	p.err = err
+	log.Printf("engine: stopped %s: %v", p.name, err)
+	if p.cmd != nil && p.cmd.Process != nil {
+		p.input.Close()
+		p.cmd.Process.Kill()
+		go p.drain()
+	}
+}
+
+//Stop the engine, if it is still running, for a player that won't play after all
+func (p *enginePlayer) Close() error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.stop(errors.New("closed"))
+	return nil
+}
+
+// Throw away whatever else the engine wrote, and reap it
+func (p *enginePlayer) drain() {
+	for range p.lines {
+	}
+	p.cmd.Wait()
+}
+
+// Send the engine a command, unless it has been stopped
+func (p *enginePlayer) send(command string, fields ...interface{}) {
+	if p.err != nil {
+		return
+	}
+	line := strings.TrimSpace(fmt.Sprintln(append([]interface{}{command}, fields...)...))
+	if _, err := io.WriteString(p.input, line+"\n"); err != nil {
+		p.stop(err)
+	}
+}
+
+// The fields after the command of the engine's next line starting with the
+// given command, skipping any others. Stops the engine, and returns false,
+// if it has been stopped, exits, or takes too long
+func (p *enginePlayer) await(command string) ([]string, bool) {
+	if p.err != nil {
+		return nil, false
+	}
+	deadline := time.NewTimer(p.timeout)
+	defer deadline.Stop()
+	for {
+		select {
+		case line, ok := <-p.lines:
+			if !ok {
+				p.stop(fmt.Errorf("exited while we waited for %s", command))
+				return nil, false
+			}
+			if fields := strings.Fields(line); len(fields) > 0 && fields[0] == command {
+				return fields[1:], true
+			}
+		case <-deadline.C:
+			p.stop(fmt.Errorf("no %s within %v", command, p.timeout))
+			return nil, false
+		}
+	}
+}
+
+func (p *enginePlayer) Name() string {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return p.name
+}
+
+func (p *enginePlayer) SetName(newName string) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.name = newName
+	p.send(ENGINE_NAME, newName)
+}
+
+func (p *enginePlayer) SetOpponent(name string) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.opponent = name
+}
+
+func (p *enginePlayer) Opponent() string {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return p.opponent
+}
+
+// Ask the engine where to place the next worker
+func (p *enginePlayer) PlaceWorker(b board.IBoard) board.Pos {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.send(ENGINE_POSITION, FormatPosition(b))
+	p.send(ENGINE_GO, ENGINE_PLACE, p.timeout.Milliseconds())
+	fields, ok := p.await(ENGINE_BESTPLACE)
+	if !ok {
+		return board.Pos{X: -1, Y: -1}
+	}
+	pos, err := parsePos(strings.Join(fields, " "))
+	if err != nil {
+		p.stop(err)
+		return board.Pos{X: -1, Y: -1}
+	}
+	return pos
+}
+
+// Ask the engine for the next turn
+func (p *enginePlayer) NextTurn(b board.IBoard) common.Turn {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.send(ENGINE_POSITION, FormatPosition(b))
+	p.send(ENGINE_GO, ENGINE_TURN, p.timeout.Milliseconds())
+	fields, ok := p.await(ENGINE_BESTTURN)
+	if !ok {
+		fields = []string{ENGINE_NO_TURN}
+	}
+	turn, err := ParseTurn(fields)
+	if err != nil {
+		p.stop(err)
+	}
+	return turn
+}
+
+// Send the engine the results, and let it exit
+func (p *enginePlayer) ReceiveTournamentResults(results []result.MatchResult) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	fields := make([]interface{}, 0)
+	for _, match := range results {
+		fields = append(fields, match.Winner, match.Loser)
+	}
+	p.send(ENGINE_RESULTS, fields...)
+	p.send(ENGINE_QUIT)
+	if p.err != nil {
+		return
+	}
+
+	// Give it as long to exit as it has for any other request
+	exited := make(chan error, 1)
+	go func() {
+		p.input.Close()
+		for range p.lines {
+		}
+		exited <- p.cmd.Wait()
+	}()
+	select {
+	case err := <-exited:
+		p.err = fmt.Errorf("exited (%v)", err)
+	case <-time.After(p.timeout):
+		p.err = fmt.Errorf("didn't exit within %v", p.timeout)
+		log.Printf("engine: stopped %s: %v", p.name, p.err)
+		p.cmd.Process.Kill()
+	}
+}
+
+func (p *enginePlayer) StartSeries(opponent string, games int) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.opponent = opponent
+	p.send(ENGINE_SERIES, opponent, games)
+}
+
+func (p *enginePlayer) StartGame(game int, order int) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.send(ENGINE_GAME, game, order)
+}
+
+func (p *enginePlayer) OpponentTurn(b board.IBoard, t common.Turn) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.send(ENGINE_OPPONENT, FormatTurn(t))
+}
+
+func (p *enginePlayer) EndGame(result rules.GameResult) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.send(ENGINE_GAMEOVER, result.Winner, result.Reason)
+}
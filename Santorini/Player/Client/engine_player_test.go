@@ -0,0 +1,194 @@
+package client
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	board "github.com/CS4500-F18/dare-rebr/Santorini/Common/Board"
+	common "github.com/CS4500-F18/dare-rebr/Santorini/Common/Player"
+	rules "github.com/CS4500-F18/dare-rebr/Santorini/Common/Rules"
+	strategy "github.com/CS4500-F18/dare-rebr/Santorini/Player/Strategy"
+)
+
+// Set to make the test binary an engine instead: "valid" plays by the rules,
+// "slow" never answers a "go", and "mute" never answers at all
+const TEST_ENGINE = "SANTORINI_TEST_ENGINE"
+
+func TestMain(m *testing.M) {
+	if mode := os.Getenv(TEST_ENGINE); mode == "mute" {
+		bufio.NewScanner(os.Stdin).Scan()
+		os.Exit(0)
+	} else if mode != "" {
+		runTestEngine(mode == "slow")
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// An engine playing the valid strategy on stdin and stdout
+func runTestEngine(slow bool) {
+	s := strategy.ValidStrategy("")
+	b := board.IBoard(board.BaseBoard())
+
+	input := bufio.NewScanner(os.Stdin)
+	for input.Scan() {
+		fields := strings.Fields(input.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		switch fields[0] {
+		case ENGINE_HELLO:
+			fmt.Println("info test engine")
+			fmt.Println(ENGINE_READY)
+		case ENGINE_NAME:
+			s.SetName(fields[1])
+		case ENGINE_SERIES:
+			s.SetOpponent(fields[1])
+		case ENGINE_POSITION:
+			b, _ = ParsePosition(fields[1:])
+		case ENGINE_GO:
+			if slow {
+				continue
+			}
+			if fields[1] == ENGINE_PLACE {
+				pos := s.WorkerPlacement(b)
+				fmt.Println(ENGINE_BESTPLACE, pos.X, pos.Y)
+			} else {
+				fmt.Println(ENGINE_BESTTURN, FormatTurn(s.WorkerTurn(b)))
+			}
+		case ENGINE_QUIT:
+			return
+		}
+	}
+}
+
+// A board written as a position reads back as the same board
+func TestPosition(t *testing.T) {
+	b, _ := setupBoard().AddFloor(board.Pos{X: 2, Y: 1})
+	b, _ = b.AddFloor(board.Pos{X: 2, Y: 1})
+
+	position := FormatPosition(b)
+	if expected := "000000/002000/000000/000000/000000/000000 uno1 0 0 dos1 4 4 uno2 1 1 dos2 5 5"; position != expected {
+		t.Errorf("Expected %q, got %q", expected, position)
+	}
+	parsed, err := ParsePosition(strings.Fields(position))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if FormatPosition(parsed) != position {
+		t.Errorf("Read back %q as %q", position, FormatPosition(parsed))
+	}
+
+	for _, bad := range []string{"000000/000000", "000000/000000/000000/000000/000000/00000x", "000000/000000/000000/000000/000000/000000 uno1 0"} {
+		if _, err := ParsePosition(strings.Fields(bad)); err == nil {
+			t.Errorf("Read %q as a position", bad)
+		}
+	}
+}
+
+func TestTurn(t *testing.T) {
+	turns := map[string]common.Turn{
+		"2 1 0 1 1": {WID: 1, MoveTo: board.Pos{X: 1, Y: 0}, BuildAt: board.Pos{X: 1, Y: 1}},
+		"1 3 3":     {WID: 0, MoveTo: board.Pos{X: 3, Y: 3}, BuildAt: board.Pos{X: -1, Y: -1}},
+		"none":      {WID: -1, MoveTo: board.Pos{X: -1, Y: -1}, BuildAt: board.Pos{X: -1, Y: -1}},
+	}
+	for fields, turn := range turns {
+		if FormatTurn(turn) != fields {
+			t.Errorf("Expected %q, got %q", fields, FormatTurn(turn))
+		}
+		if parsed, err := ParseTurn(strings.Fields(fields)); err != nil || parsed != turn {
+			t.Errorf("Read %q as %+v (%v)", fields, parsed, err)
+		}
+	}
+
+	for _, bad := range []string{"3 1 1", "1 1", "1 1 1 1", "one 1 1"} {
+		if turn, err := ParseTurn(strings.Fields(bad)); err == nil || board.ValidWID(turn.WID) {
+			t.Errorf("Read %q as a turn", bad)
+		}
+	}
+}
+
+// The engine's placements and turns are played, and it exits at the end
+func TestEnginePlayer(t *testing.T) {
+	t.Setenv(TEST_ENGINE, "valid")
+	// Long enough for the race detector to let the engine exit
+	p, err := EnginePlayer("uno", os.Args[0], 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.StartSeries("dos", 3)
+	p.StartGame(0, 1)
+
+	b := board.IBoard(board.BaseBoard())
+	b, _ = b.PlaceWorker(board.Pos{X: 0, Y: 0}, "dos")
+	if pos := p.PlaceWorker(b); !rules.CheckPlaceWorker(b, pos) {
+		t.Errorf("Engine placed a worker at %v", pos)
+	}
+
+	turn := p.NextTurn(setupBoard())
+	moved, _, err := turn.Move("uno", setupBoard())
+	if err == nil {
+		_, _, err = turn.Build("uno", moved)
+	}
+	if err != nil {
+		t.Errorf("Engine took an illegal turn %+v: %v", turn, err)
+	}
+
+	p.ReceiveTournamentResults(nil)
+	if err := p.(*enginePlayer).err; err == nil || !strings.Contains(err.Error(), "exited (<nil>)") {
+		t.Errorf("Engine didn't exit: %v", err)
+	}
+}
+
+// An engine that misses a deadline is stopped, and every later request fails
+// at once
+func TestEnginePlayer_Timeout(t *testing.T) {
+	t.Setenv(TEST_ENGINE, "slow")
+	p, err := EnginePlayer("uno", os.Args[0], 200*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	if pos := p.PlaceWorker(board.BaseBoard()); pos.InBounds() {
+		t.Errorf("Slow engine placed a worker at %v", pos)
+	}
+	if turn := p.NextTurn(setupBoard()); board.ValidWID(turn.WID) {
+		t.Errorf("Stopped engine took a turn: %+v", turn)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Took %v to give up on the engine", elapsed)
+	}
+
+}
+
+// Closing an engine stops it, and it plays no more
+func TestEnginePlayer_Close(t *testing.T) {
+	t.Setenv(TEST_ENGINE, "valid")
+	p, err := EnginePlayer("uno", os.Args[0], 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.(io.Closer).Close()
+	if pos := p.PlaceWorker(board.BaseBoard()); pos.InBounds() {
+		t.Errorf("Closed engine placed a worker at %v", pos)
+	}
+}
+
+// An engine that isn't there, or never finishes the handshake, isn't a player
+func TestEnginePlayer_Handshake(t *testing.T) {
+	if p, err := EnginePlayer("uno", filepath.Join(t.TempDir(), "missing"), time.Second); err == nil || p != nil {
+		t.Errorf("Made a player of a missing engine: %v", p)
+	}
+
+	t.Setenv(TEST_ENGINE, "mute")
+	if p, err := EnginePlayer("uno", os.Args[0], 200*time.Millisecond); err == nil || p != nil {
+		t.Errorf("Made a player of an engine that never said %s: %v", ENGINE_READY, p)
+	}
+}
@@ -0,0 +1,170 @@
+package client
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	board "github.com/CS4500-F18/dare-rebr/Santorini/Common/Board"
+	common "github.com/CS4500-F18/dare-rebr/Santorini/Common/Player"
+)
+
+//The text protocol an external engine speaks on its stdin and stdout, one
+//command per line, much like UCI for chess engines. Workers are numbered 1
+//and 2, and positions are "x y". The player sends:
+//
+//  santorini                  once, at launch; answer "santoriniok"
+//  name NAME                  the name you play as
+//  newseries OPPONENT GAMES   a best of GAMES against OPPONENT is starting
+//  newgame GAME ORDER         game GAME (from 0) is starting, you go first if ORDER is 0
+//  position BOARD             the board, before each "go"
+//  go place MS                answer "bestplace X Y" within MS milliseconds
+//  go turn MS                 answer "bestturn WORKER X Y BX BY" within MS
+//                             milliseconds, leaving out the build if the move
+//                             wins, or "bestturn none" to give up
+//  opponent WORKER X Y [BX BY]  the turn your opponent just took
+//  gameover WINNER REASON     the game is over, REASON being the rest of the
+//                             line, e.g. "gameover rex1 fido broke a rule"
+//  results [WINNER LOSER]...  the tournament is over, with every series played
+//  quit                       exit
+//
+//A BOARD is the heights of each row, from y 0, separated by slashes, then each
+//worker's name and position, e.g.
+//
+//  position 000000/010000/000000/000000/000000/000000 fido1 0 0 fido2 1 1 rex1 4 4 rex2 5 5
+//
+//Engines can write any other line, such as "info ...", and it is ignored
+const (
+	ENGINE_HELLO     = "santorini"
+	ENGINE_READY     = "santoriniok"
+	ENGINE_NAME      = "name"
+	ENGINE_SERIES    = "newseries"
+	ENGINE_GAME      = "newgame"
+	ENGINE_POSITION  = "position"
+	ENGINE_GO        = "go"
+	ENGINE_PLACE     = "place"
+	ENGINE_TURN      = "turn"
+	ENGINE_BESTPLACE = "bestplace"
+	ENGINE_BESTTURN  = "bestturn"
+	ENGINE_OPPONENT  = "opponent"
+	ENGINE_GAMEOVER  = "gameover"
+	ENGINE_RESULTS   = "results"
+	ENGINE_QUIT      = "quit"
+
+	// A bestturn that gives up the game
+	ENGINE_NO_TURN = "none"
+)
+
+// The fields of a "position" line describing the given board, without the
+// command itself
+func FormatPosition(b board.IBoard) string {
+	width, height := b.Dimensions()
+	rows := make([]string, 0)
+	for y := 0; y < height; y++ {
+		var row strings.Builder
+		for x := 0; x < width; x++ {
+			floors := 0
+			if tile, err := b.TileAt(board.Pos{X: x, Y: y}); err == nil {
+				floors = tile.FloorCount()
+			}
+			row.WriteString(strconv.Itoa(floors))
+		}
+		rows = append(rows, row.String())
+	}
+
+	fields := []string{strings.Join(rows, "/")}
+	for _, worker := range b.Workers() {
+		fields = append(fields, worker.Name(), strconv.Itoa(worker.Pos().X), strconv.Itoa(worker.Pos().Y))
+	}
+	return strings.Join(fields, " ")
+}
+
+// The board the fields of a "position" line describe
+func ParsePosition(fields []string) (board.IBoard, error) {
+	if len(fields) == 0 || (len(fields)-1)%3 != 0 {
+		return nil, fmt.Errorf("position needs the heights and three fields per worker, got %q", strings.Join(fields, " "))
+	}
+
+	rows := strings.Split(fields[0], "/")
+	if len(rows) != board.NormalBoardSize {
+		return nil, fmt.Errorf("the board has %v rows, not %v", len(rows), board.NormalBoardSize)
+	}
+	tiles := make([]board.ITile, 0)
+	for y, row := range rows {
+		if len(row) != board.NormalBoardSize {
+			return nil, fmt.Errorf("row %q is not %v heights", row, board.NormalBoardSize)
+		}
+		for x, floors := range row {
+			height, err := strconv.Atoi(string(floors))
+			if err != nil || height > board.MaxBuildingHeight {
+				return nil, fmt.Errorf("%q is not a height", floors)
+			}
+			tiles = append(tiles, board.CustomTile(board.Pos{X: x, Y: y}, height))
+		}
+	}
+	b := board.IBoard(board.BoardWithTiles(tiles))
+
+	// Each player's workers are numbered in the order they are placed
+	workers := make(board.WorkerSet, 0)
+	for i := 1; i < len(fields); i += 3 {
+		owner, id, err := board.ParseWorkerName(fields[i])
+		if err != nil {
+			return nil, err
+		}
+		pos, err := parsePos(fields[i+1] + " " + fields[i+2])
+		if err != nil {
+			return nil, err
+		}
+		workers = append(workers, board.NewWorker(pos, owner, id))
+	}
+	sort.Stable(workers)
+	for _, worker := range workers {
+		var err error
+		if b, err = b.PlaceWorker(worker.Pos(), worker.Owner()); err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+// The fields of a "bestturn" or "opponent" line for the given turn
+func FormatTurn(t common.Turn) string {
+	if !board.ValidWID(t.WID) {
+		return ENGINE_NO_TURN
+	}
+	fields := fmt.Sprintf("%v %v %v", t.WID+1, t.MoveTo.X, t.MoveTo.Y)
+	if t.BuildAt.InBounds() {
+		fields += fmt.Sprintf(" %v %v", t.BuildAt.X, t.BuildAt.Y)
+	}
+	return fields
+}
+
+// The turn the fields of a "bestturn" or "opponent" line describe. A turn
+// that wins has its build out of bounds
+func ParseTurn(fields []string) (common.Turn, error) {
+	giveUp := common.Turn{WID: -1, MoveTo: board.Pos{X: -1, Y: -1}, BuildAt: board.Pos{X: -1, Y: -1}}
+	if len(fields) == 1 && fields[0] == ENGINE_NO_TURN {
+		return giveUp, nil
+	}
+	if len(fields) != 3 && len(fields) != 5 {
+		return giveUp, fmt.Errorf("a turn is a worker, a move and optionally a build, got %q", strings.Join(fields, " "))
+	}
+
+	id, err := strconv.Atoi(fields[0])
+	if err != nil || !board.ValidWID(id-1) {
+		return giveUp, fmt.Errorf("%q is not a worker", fields[0])
+	}
+	moveTo, err := parsePos(fields[1] + " " + fields[2])
+	if err != nil {
+		return giveUp, err
+	}
+	turn := common.Turn{WID: id - 1, MoveTo: moveTo, BuildAt: giveUp.BuildAt}
+
+	if len(fields) == 5 {
+		if turn.BuildAt, err = parsePos(fields[3] + " " + fields[4]); err != nil {
+			return giveUp, err
+		}
+	}
+	return turn, nil
+}
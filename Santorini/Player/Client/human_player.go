@@ -0,0 +1,322 @@
+package client
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	board "github.com/CS4500-F18/dare-rebr/Santorini/Common/Board"
+	output "github.com/CS4500-F18/dare-rebr/Santorini/Common/JSON"
+	common "github.com/CS4500-F18/dare-rebr/Santorini/Common/Player"
+	rules "github.com/CS4500-F18/dare-rebr/Santorini/Common/Rules"
+	result "github.com/CS4500-F18/dare-rebr/Santorini/Common/Tournament"
+	obs "github.com/CS4500-F18/dare-rebr/Santorini/Observer"
+)
+
+// What a person types to give up the current game
+const GIVE_UP_CMD = "quit"
+
+// Short names for each Direction a worker can move or build in
+var DIRECTIONS = map[string]output.Direction{
+	"N":  {EastWest: output.PUT, NorthSouth: output.NORTH},
+	"NE": {EastWest: output.EAST, NorthSouth: output.NORTH},
+	"E":  {EastWest: output.EAST, NorthSouth: output.PUT},
+	"SE": {EastWest: output.EAST, NorthSouth: output.SOUTH},
+	"S":  {EastWest: output.PUT, NorthSouth: output.SOUTH},
+	"SW": {EastWest: output.WEST, NorthSouth: output.SOUTH},
+	"W":  {EastWest: output.WEST, NorthSouth: output.PUT},
+	"NW": {EastWest: output.WEST, NorthSouth: output.NORTH},
+}
+
+//A player controlled by a person at a terminal, who is shown each board and
+//their legal moves, and types their placements and turns. Illegal input is
+//explained, and asked for again.
+//
+//  placement: "x y"             e.g. "2 3"
+//  turn:      "worker move"     e.g. "1 NE", then the build when asked
+//         or  "worker move build" e.g. "1 NE S"
+//  give up:   "quit"
+//
+//A remote server's time limits still apply while the person is typing. When
+//the server says what its limit is, each prompt shows the time left
+type humanPlayer struct {
+	name     string
+	opponent string
+
+	input  *bufio.Scanner
+	output io.Writer
+
+	//how long the server gives us to answer, or 0 if we don't know, and when
+	//the request being answered came in
+	limit time.Duration
+	asked time.Time
+}
+
+//Creates a new player that asks a person for each placement and turn,
+//reading from in and writing to out
+func HumanPlayer(name string, in io.Reader, out io.Writer) common.IPlayer {
+	return &humanPlayer{
+		name:   name,
+		input:  bufio.NewScanner(in),
+		output: out,
+	}
+}
+
+func (p *humanPlayer) Name() string {
+	return p.name
+}
+
+func (p *humanPlayer) SetName(newName string) {
+	if newName != p.name {
+		p.printf("You are playing as %s\n", newName)
+	}
+	p.name = newName
+}
+
+func (p *humanPlayer) SetOpponent(name string) {
+	p.opponent = name
+}
+
+func (p *humanPlayer) Opponent() string {
+	return p.opponent
+}
+
+//Tell the person how long they have to answer each request
+func (p *humanPlayer) SetTimeLimit(limit time.Duration) {
+	p.limit = limit
+	p.printf("You have %v to answer each request\n", limit)
+}
+
+// Ask for the position of the next worker to place
+func (p *humanPlayer) PlaceWorker(b board.IBoard) board.Pos {
+	p.asked = time.Now()
+	p.printf("\n%s", obs.RenderBoard(b, obs.NoHighlight(), false))
+	for {
+		line, ok := p.prompt("Place a worker (x y): ")
+		if !ok {
+			return board.Pos{X: -1, Y: -1}
+		}
+
+		pos, err := parsePos(line)
+		if err != nil {
+			p.printf("%s\n", err)
+			continue
+		}
+		if !rules.CheckPlaceWorker(b, pos) {
+			p.printf("You can't place a worker at (%v, %v), it is off the board or taken\n", pos.X, pos.Y)
+			continue
+		}
+		return pos
+	}
+}
+
+// Ask for the next turn, showing which moves are legal
+func (p *humanPlayer) NextTurn(b board.IBoard) common.Turn {
+	giveUp := common.Turn{WID: -1, MoveTo: board.Pos{X: -1, Y: -1}, BuildAt: board.Pos{X: -1, Y: -1}}
+
+	p.asked = time.Now()
+	p.printf("\n%s", obs.RenderBoard(b, obs.NoHighlight(), false))
+	for _, worker := range b.WorkersFor(p.name) {
+		p.printf("%s can move: %s\n", worker.Name(), strings.Join(legalMoves(b, worker.Pos()), " "))
+	}
+
+	for {
+		line, ok := p.prompt("Your turn (worker move [build], or quit): ")
+		if !ok || line == GIVE_UP_CMD {
+			return giveUp
+		}
+
+		turn, needsBuild, err := p.parseTurn(b, line)
+		if err != nil {
+			p.printf("%s\n", err)
+			continue
+		}
+		if !needsBuild {
+			return turn
+		}
+
+		moved, _ := b.Move(p.name, turn.WID, turn.MoveTo)
+		p.printf("You can build: %s\n", strings.Join(legalBuilds(moved, turn.MoveTo), " "))
+		for {
+			line, ok := p.prompt("Build (direction): ")
+			if !ok || line == GIVE_UP_CMD {
+				return giveUp
+			}
+			dir, found := DIRECTIONS[strings.ToUpper(line)]
+			if !found {
+				p.printf("%q is not a direction, use one of N NE E SE S SW W NW\n", line)
+				continue
+			}
+			turn.BuildAt = output.PosFromDirection(turn.MoveTo, dir)
+			if !rules.CheckBuild(moved, turn.MoveTo, turn.BuildAt) {
+				p.printf("You can't build %s of (%v, %v)\n", line, turn.MoveTo.X, turn.MoveTo.Y)
+				continue
+			}
+			return turn
+		}
+	}
+}
+
+// Parse a typed turn, checking it against the rules. Returns whether the turn
+// still needs a build (it has none yet, and the move doesn't win)
+func (p *humanPlayer) parseTurn(b board.IBoard, line string) (common.Turn, bool, error) {
+	turn := common.Turn{BuildAt: board.Pos{X: -1, Y: -1}}
+
+	fields := strings.Fields(line)
+	if len(fields) < 2 || len(fields) > 3 {
+		return turn, false, fmt.Errorf("Type a worker number and a direction, and optionally a build direction, e.g. \"1 NE S\"")
+	}
+
+	id, err := strconv.Atoi(fields[0])
+	if err != nil || !board.ValidWID(id-1) {
+		return turn, false, fmt.Errorf("%q is not a worker, use 1 or 2", fields[0])
+	}
+	turn.WID = id - 1
+
+	worker, err := b.FindWorker(p.name, turn.WID)
+	if err != nil {
+		return turn, false, err
+	}
+
+	moveDir, found := DIRECTIONS[strings.ToUpper(fields[1])]
+	if !found {
+		return turn, false, fmt.Errorf("%q is not a direction, use one of N NE E SE S SW W NW", fields[1])
+	}
+	turn.MoveTo = output.PosFromDirection(worker.Pos(), moveDir)
+
+	if !rules.CheckMove(b, worker.Pos(), turn.MoveTo) {
+		return turn, false, fmt.Errorf("%s can't move %s: the space is off the board, taken, or too high", worker.Name(), fields[1])
+	}
+
+	moved, _ := b.Move(p.name, turn.WID, turn.MoveTo)
+	if rules.CheckWinPostMove(moved, p.name) {
+		return turn, false, nil
+	}
+
+	if len(fields) == 2 {
+		return turn, true, nil
+	}
+
+	buildDir, found := DIRECTIONS[strings.ToUpper(fields[2])]
+	if !found {
+		return turn, false, fmt.Errorf("%q is not a direction, use one of N NE E SE S SW W NW", fields[2])
+	}
+	turn.BuildAt = output.PosFromDirection(turn.MoveTo, buildDir)
+	if !rules.CheckBuild(moved, turn.MoveTo, turn.BuildAt) {
+		return turn, false, fmt.Errorf("%s can't build %s after moving %s", worker.Name(), fields[2], fields[1])
+	}
+	return turn, false, nil
+}
+
+func (p *humanPlayer) ReceiveTournamentResults(results []result.MatchResult) {
+	p.printf("\nThe tournament is over:\n")
+	for _, match := range results {
+		p.printf("  %s beat %s\n", match.Winner, match.Loser)
+	}
+}
+
+func (p *humanPlayer) StartSeries(opponent string, games int) {
+	p.opponent = opponent
+	p.printf("\nStarting a best of %v against %s\n", games, opponent)
+}
+
+func (p *humanPlayer) StartGame(game int, order int) {
+	if order == 0 {
+		p.printf("\nGame %v: you go first\n", game+1)
+	} else {
+		p.printf("\nGame %v: you go second\n", game+1)
+	}
+}
+
+func (p *humanPlayer) OpponentTurn(b board.IBoard, t common.Turn) {
+	worker, err := b.FindWorker(p.opponent, t.WID)
+	if err != nil {
+		return
+	}
+	p.printf("%s moved %s\n", worker.Name(), directionName(worker.Pos(), t.MoveTo))
+	if t.BuildAt.InBounds() {
+		p.printf("%s built %s\n", worker.Name(), directionName(t.MoveTo, t.BuildAt))
+	}
+}
+
+func (p *humanPlayer) EndGame(result rules.GameResult) {
+	if result.Winner == p.name {
+		p.printf("You won! (%s)\n", result.Reason)
+	} else {
+		p.printf("You lost. (%s)\n", result.Reason)
+	}
+}
+
+// Write a prompt, with the time left if there is a limit, and read a trimmed
+// line of input. Returns false once the input has ended
+func (p *humanPlayer) prompt(msg string) (string, bool) {
+	if p.limit > 0 {
+		left := p.limit - time.Since(p.asked)
+		if left < 0 {
+			left = 0
+		}
+		p.printf("[%v left] ", left.Round(time.Second))
+	}
+	p.printf("%s", msg)
+	if !p.input.Scan() {
+		return "", false
+	}
+	return strings.TrimSpace(p.input.Text()), true
+}
+
+func (p *humanPlayer) printf(format string, args ...interface{}) {
+	fmt.Fprintf(p.output, format, args...)
+}
+
+// Parse an "x y" position
+func parsePos(line string) (board.Pos, error) {
+	fields := strings.Fields(line)
+	if len(fields) != 2 {
+		return board.Pos{}, fmt.Errorf("Type a column and a row, e.g. \"2 3\"")
+	}
+	x, errX := strconv.Atoi(fields[0])
+	y, errY := strconv.Atoi(fields[1])
+	if errX != nil || errY != nil {
+		return board.Pos{}, fmt.Errorf("%q is not a pair of numbers", line)
+	}
+	return board.Pos{X: x, Y: y}, nil
+}
+
+// The short names of the directions a worker at the given Pos can move in
+func legalMoves(b board.IBoard, from board.Pos) []string {
+	legal := make([]string, 0)
+	for name, dir := range DIRECTIONS {
+		if rules.CheckMove(b, from, output.PosFromDirection(from, dir)) {
+			legal = append(legal, name)
+		}
+	}
+	sort.Strings(legal)
+	return legal
+}
+
+// The short names of the directions a worker at the given Pos can build in
+func legalBuilds(b board.IBoard, from board.Pos) []string {
+	legal := make([]string, 0)
+	for name, dir := range DIRECTIONS {
+		if rules.CheckBuild(b, from, output.PosFromDirection(from, dir)) {
+			legal = append(legal, name)
+		}
+	}
+	sort.Strings(legal)
+	return legal
+}
+
+// The short name of the direction from one Pos to its neighbor
+func directionName(from, to board.Pos) string {
+	dir := output.DirectionFrom2Pos(from, to)
+	for name, candidate := range DIRECTIONS {
+		if candidate == dir {
+			return name
+		}
+	}
+	return "nowhere"
+}
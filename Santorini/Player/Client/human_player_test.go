@@ -0,0 +1,73 @@
+package client
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	board "github.com/CS4500-F18/dare-rebr/Santorini/Common/Board"
+)
+
+// uno's workers at (0, 0) and (1, 1), dos's at (4, 4) and (5, 5)
+func setupBoard() board.IBoard {
+	b := board.IBoard(board.BaseBoard())
+	b, _ = b.PlaceWorker(board.Pos{X: 0, Y: 0}, "uno")
+	b, _ = b.PlaceWorker(board.Pos{X: 4, Y: 4}, "dos")
+	b, _ = b.PlaceWorker(board.Pos{X: 1, Y: 1}, "uno")
+	b, _ = b.PlaceWorker(board.Pos{X: 5, Y: 5}, "dos")
+	return b
+}
+
+// Taken and malformed placements should be explained, and asked for again
+func TestHumanPlayer_PlaceWorker(t *testing.T) {
+	var out strings.Builder
+	p := HumanPlayer("uno", strings.NewReader("a b\n1 1\n2 3\n"), &out)
+
+	b := board.IBoard(board.BaseBoard())
+	b, _ = b.PlaceWorker(board.Pos{X: 1, Y: 1}, "dos")
+
+	if pos := p.PlaceWorker(b); pos != (board.Pos{X: 2, Y: 3}) {
+		t.Errorf("Expected (2, 3), got %v", pos)
+	}
+	if !strings.Contains(out.String(), "not a pair of numbers") || !strings.Contains(out.String(), "taken") {
+		t.Errorf("Bad placements were not explained: %s", out.String())
+	}
+}
+
+// Illegal turns should be re-prompted, and the build asked for after the move
+func TestHumanPlayer_NextTurn(t *testing.T) {
+	var out strings.Builder
+	p := HumanPlayer("uno", strings.NewReader("3 E\n1 SE\n1 E\nNW\nW\n"), &out)
+
+	turn := p.NextTurn(setupBoard())
+
+	if turn.WID != 0 || turn.MoveTo != (board.Pos{X: 1, Y: 0}) || turn.BuildAt != (board.Pos{X: 0, Y: 0}) {
+		t.Errorf("Wrong turn: %+v", turn)
+	}
+	if !strings.Contains(out.String(), "not a worker") || !strings.Contains(out.String(), "can't move SE") ||
+		!strings.Contains(out.String(), "can't build NW") {
+		t.Errorf("Bad turns were not explained: %s", out.String())
+	}
+}
+
+// Giving up returns a turn that no worker can take
+func TestHumanPlayer_GiveUp(t *testing.T) {
+	var out strings.Builder
+	p := HumanPlayer("uno", strings.NewReader(GIVE_UP_CMD+"\n"), &out)
+
+	if turn := p.NextTurn(setupBoard()); board.ValidWID(turn.WID) {
+		t.Errorf("Giving up should not pick a worker: %+v", turn)
+	}
+}
+
+// Once the server's time limit is known, each prompt shows the time left
+func TestHumanPlayer_TimeLimit(t *testing.T) {
+	var out strings.Builder
+	p := HumanPlayer("uno", strings.NewReader("2 3\n"), &out)
+	p.(*humanPlayer).SetTimeLimit(30 * time.Second)
+
+	p.PlaceWorker(board.BaseBoard())
+	if !strings.Contains(out.String(), "You have 30s") || !strings.Contains(out.String(), "[30s left] Place a worker") {
+		t.Errorf("Time limit wasn't shown: %s", out.String())
+	}
+}
@@ -0,0 +1,263 @@
+package faults
+
+import (
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+)
+
+/*
+  A Proxy is a TCP man-in-the-middle for resilience testing. It sits between
+  clients (e.g. a PlayerRelay) and a server, forwarding bytes both ways, and
+  injects Faults into each direction:
+    - latency, with random jitter
+    - partial writes, splitting messages across several writes
+    - coalescing, gathering several messages into one write
+    - dropped connections, even mid-message
+    - garbage bytes
+  Faults can be changed while connections are open, e.g. to let a client
+  register cleanly before things go wrong
+*/
+
+// Faults injected into the bytes going one way through a Proxy
+type Faults struct {
+	// How long to hold bytes before forwarding them, and up to how much longer
+	// at random
+	Latency time.Duration
+	Jitter  time.Duration
+
+	// Most bytes to forward in one write (0 for no limit), and how long to
+	// wait between the writes a message is split into
+	ChunkSize  int
+	SplitDelay time.Duration
+
+	// How long to gather bytes before forwarding them together in one write
+	Coalesce time.Duration
+
+	// Hang up both sides after forwarding this many bytes, even in the middle
+	// of a message (0 for never)
+	DropAfter int
+
+	// Bytes to inject once, after forwarding GarbageAfter bytes
+	Garbage      []byte
+	GarbageAfter int
+}
+
+type Proxy struct {
+	listener net.Listener
+	target   string
+
+	lock     sync.Mutex
+	toServer Faults
+	toClient Faults
+	// Counts the changes to the faults, so counts of bytes can start over
+	version int
+	random  *rand.Rand
+	conns   map[net.Conn]bool
+}
+
+// Start a proxy on a port of localhost, forwarding each connection to the
+// target address with the given faults each way
+func NewProxy(target string, toServer, toClient Faults) (*Proxy, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Proxy{
+		listener: listener,
+		target:   target,
+		toServer: toServer,
+		toClient: toClient,
+		random:   rand.New(rand.NewSource(time.Now().UnixNano())),
+		conns:    make(map[net.Conn]bool),
+	}
+	go p.accept()
+	return p, nil
+}
+
+// The address clients connect to
+func (p *Proxy) Addr() string {
+	return p.listener.Addr().String()
+}
+
+// The port clients connect to
+func (p *Proxy) Port() int {
+	return p.listener.Addr().(*net.TCPAddr).Port
+}
+
+// Inject the given faults from now on. Byte counts for dropping connections
+// and injecting garbage start over
+func (p *Proxy) SetFaults(toServer, toClient Faults) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.toServer, p.toClient = toServer, toClient
+	p.version++
+}
+
+// Hang up every connection through the proxy
+func (p *Proxy) Drop() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	for conn := range p.conns {
+		conn.Close()
+	}
+}
+
+// Stop accepting connections, and hang up every one open
+func (p *Proxy) Close() error {
+	err := p.listener.Close()
+	p.Drop()
+	return err
+}
+
+func (p *Proxy) accept() {
+	for {
+		client, err := p.listener.Accept()
+		if err != nil {
+			return
+		}
+		server, err := net.Dial("tcp", p.target)
+		if err != nil {
+			client.Close()
+			continue
+		}
+
+		p.lock.Lock()
+		p.conns[client], p.conns[server] = true, true
+		p.lock.Unlock()
+
+		hangUp := func() {
+			p.lock.Lock()
+			defer p.lock.Unlock()
+			client.Close()
+			server.Close()
+			delete(p.conns, client)
+			delete(p.conns, server)
+		}
+		go func() {
+			p.forward(client, server, true)
+			hangUp()
+		}()
+		go func() {
+			p.forward(server, client, false)
+			hangUp()
+		}()
+	}
+}
+
+// The faults to inject one way, and which version of them they are
+func (p *Proxy) faults(toServer bool) (Faults, int) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if toServer {
+		return p.toServer, p.version
+	}
+	return p.toClient, p.version
+}
+
+// How long to hold bytes for, with jitter
+func (p *Proxy) delay(f Faults) time.Duration {
+	if f.Jitter <= 0 {
+		return f.Latency
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return f.Latency + time.Duration(p.random.Int63n(int64(f.Jitter)))
+}
+
+// Forward bytes from one connection to the other, injecting faults, until
+// either hangs up or a fault drops them
+func (p *Proxy) forward(src, dst net.Conn, toServer bool) {
+	chunks := make(chan []byte, 64)
+	done := make(chan bool)
+	defer close(done)
+	go read(src, chunks, done)
+
+	forwarded, injected, version := 0, false, -1
+	for chunk := range chunks {
+		f, current := p.faults(toServer)
+		if current != version {
+			forwarded, injected, version = 0, false, current
+		}
+
+		if f.Coalesce > 0 {
+			chunk = gather(chunk, chunks, f.Coalesce)
+		}
+		time.Sleep(p.delay(f))
+
+		for len(chunk) > 0 {
+			n := len(chunk)
+			if f.Garbage != nil && !injected {
+				if k := f.GarbageAfter - forwarded; k <= 0 {
+					if _, err := dst.Write(f.Garbage); err != nil {
+						return
+					}
+					injected = true
+					continue
+				} else if k < n {
+					n = k
+				}
+			}
+			if f.DropAfter > 0 {
+				if k := f.DropAfter - forwarded; k <= 0 {
+					return
+				} else if k < n {
+					n = k
+				}
+			}
+			if f.ChunkSize > 0 && f.ChunkSize < n {
+				n = f.ChunkSize
+			}
+
+			if _, err := dst.Write(chunk[:n]); err != nil {
+				return
+			}
+			forwarded += n
+			chunk = chunk[n:]
+			if len(chunk) > 0 {
+				time.Sleep(f.SplitDelay)
+			}
+		}
+		if f.DropAfter > 0 && forwarded >= f.DropAfter {
+			return
+		}
+	}
+}
+
+// Pass on each read from the connection, until it hangs up or we're done
+func read(src net.Conn, chunks chan []byte, done chan bool) {
+	defer close(chunks)
+	for {
+		buf := make([]byte, 4096)
+		n, err := src.Read(buf)
+		if n > 0 {
+			select {
+			case chunks <- buf[:n]:
+			case <-done:
+				return
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// The chunk with every other read within the given time
+func gather(chunk []byte, chunks chan []byte, within time.Duration) []byte {
+	timer := time.NewTimer(within)
+	defer timer.Stop()
+	for {
+		select {
+		case more, ok := <-chunks:
+			if !ok {
+				return chunk
+			}
+			chunk = append(chunk, more...)
+		case <-timer.C:
+			return chunk
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package faults
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	referee "github.com/CS4500-F18/dare-rebr/Santorini/Admin/Referee"
+	sandbox "github.com/CS4500-F18/dare-rebr/Santorini/Admin/Sandbox"
+	board "github.com/CS4500-F18/dare-rebr/Santorini/Common/Board"
+	data "github.com/CS4500-F18/dare-rebr/Santorini/Common/JSON"
+	rules "github.com/CS4500-F18/dare-rebr/Santorini/Common/Rules"
+	lib "github.com/CS4500-F18/dare-rebr/Santorini/Lib"
+	client "github.com/CS4500-F18/dare-rebr/Santorini/Player/Client"
+	remote "github.com/CS4500-F18/dare-rebr/Santorini/Remote/Player"
+	relay "github.com/CS4500-F18/dare-rebr/Santorini/Remote/Relay"
+)
+
+// A player behind a relay, registered through a clean proxy with a server
+// that waits the given time for each reply. The relay is done when the
+// connection is
+func playThrough(t *testing.T, timeout time.Duration) (*remote.ProxyPlayer, *Proxy, chan bool) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	proxy, err := NewProxy(l.Addr().String(), Faults{}, Faults{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan bool, 1)
+	registered := make(chan error, 1)
+	go func() {
+		registered <- relay.NewPlayerRelay(client.ValidPlayer("fido")).Connect("127.0.0.1", proxy.Port(), done)
+	}()
+
+	conn, err := l.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	session := lib.NewSession(conn)
+	session.SetTimeout(timeout)
+
+	var reg data.Registration
+	if err := session.Receive(&reg); err != nil {
+		t.Fatal(err)
+	}
+	welcome, err := data.Negotiate(reg.Hello, []string{data.FEATURE_LIFECYCLE}, int(timeout/time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	env, _ := data.NewEnvelope(data.MSG_WELCOME, 0, 0, welcome)
+	if err := session.Send(env); err != nil {
+		t.Fatal(err)
+	}
+	// Faults set from now on miss the handshake
+	if err := <-registered; err != nil {
+		t.Fatal(err)
+	}
+	return remote.NewProxyPlayer(session, reg.Name, welcome), proxy, done
+}
+
+func place(player *remote.ProxyPlayer) (board.Pos, error) {
+	b := board.IBoard(board.BaseBoard())
+	return player.PlaceWorker(b)
+}
+
+func finished(t *testing.T, done chan bool) {
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Error("The relay never noticed the connection was gone")
+	}
+}
+
+// Latency and jitter within the timeout only slow the player down
+func TestProxy_Latency(t *testing.T) {
+	player, proxy, _ := playThrough(t, 2*time.Second)
+	defer proxy.Close()
+
+	proxy.SetFaults(Faults{Latency: 20 * time.Millisecond, Jitter: 50 * time.Millisecond},
+		Faults{Latency: 20 * time.Millisecond, Jitter: 50 * time.Millisecond})
+	for i := 0; i < 3; i++ {
+		if pos, err := place(player); err != nil || !rules.CheckPlaceWorker(board.BaseBoard(), pos) {
+			t.Errorf("Bad placement %+v: %v", pos, err)
+		}
+	}
+}
+
+// A reply that takes longer than the timeout is a timeout
+func TestProxy_Timeout(t *testing.T) {
+	player, proxy, _ := playThrough(t, 200*time.Millisecond)
+	defer proxy.Close()
+
+	proxy.SetFaults(Faults{Latency: time.Second}, Faults{})
+	_, err := place(player)
+	if netErr, ok := err.(net.Error); !ok || !netErr.Timeout() {
+		t.Errorf("Expected a timeout, got %v", err)
+	}
+}
+
+// Messages split into many writes, or gathered into one, decode the same
+func TestProxy_SplitsAndCoalescing(t *testing.T) {
+	player, proxy, _ := playThrough(t, 2*time.Second)
+	defer proxy.Close()
+
+	proxy.SetFaults(Faults{ChunkSize: 1, SplitDelay: time.Millisecond},
+		Faults{ChunkSize: 7, Coalesce: 50 * time.Millisecond})
+	if err := player.StartSeries("rex", 3); err != nil {
+		t.Fatal(err)
+	}
+	if err := player.SetOpponent("rex"); err != nil {
+		t.Fatal(err)
+	}
+	if pos, err := place(player); err != nil || !rules.CheckPlaceWorker(board.BaseBoard(), pos) {
+		t.Errorf("Bad placement %+v: %v", pos, err)
+	}
+}
+
+// A connection dropped mid-message, or all at once, fails the call, and
+// the relay gives up
+func TestProxy_Drop(t *testing.T) {
+	player, proxy, done := playThrough(t, 2*time.Second)
+	proxy.SetFaults(Faults{}, Faults{DropAfter: 10})
+	if _, err := place(player); err == nil {
+		t.Error("Placement succeeded over a dropped connection")
+	}
+	finished(t, done)
+	proxy.Close()
+
+	player, proxy, done = playThrough(t, 2*time.Second)
+	defer proxy.Close()
+	proxy.Drop()
+	if _, err := place(player); err == nil {
+		t.Error("Placement succeeded over a dropped connection")
+	}
+	finished(t, done)
+}
+
+// A player whose replies arrive garbled is caught breaking the rules
+func TestProxy_Garbage(t *testing.T) {
+	player, proxy, _ := playThrough(t, 2*time.Second)
+	defer proxy.Close()
+
+	proxy.SetFaults(Faults{Garbage: []byte("}{not json")}, Faults{})
+	ref := referee.NewReferee("fido", player, "rex", sandbox.NewNormalPlayer(client.ValidPlayer("rex")))
+	results := ref.Play()
+	if len(results) != 1 || results[0].Loser != "fido" || !results[0].BrokenRule {
+		t.Errorf("Expected fido to lose for breaking the rules, got %+v", results)
+	}
+}
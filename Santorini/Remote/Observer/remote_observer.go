@@ -0,0 +1,131 @@
+package remote
+
+import (
+	"encoding/json"
+	"net"
+	"sync"
+	"time"
+
+	sandbox "github.com/CS4500-F18/dare-rebr/Santorini/Admin/Sandbox"
+	board "github.com/CS4500-F18/dare-rebr/Santorini/Common/Board"
+	data "github.com/CS4500-F18/dare-rebr/Santorini/Common/JSON"
+	rules "github.com/CS4500-F18/dare-rebr/Santorini/Common/Rules"
+)
+
+// How many updates may be waiting to be sent to an observer before it is
+// considered too slow, and disconnected
+const OBSERVER_QUEUE_SIZE = 256
+
+//Component representing an Observer's remote connection, that sends each
+//update as JSON to the observer
+//NOTE implements IObserver interface
+//
+//Updates are queued and written on a separate goroutine, so a slow or dead
+//observer never blocks the Referee. An observer that falls more than
+//OBSERVER_QUEUE_SIZE updates behind, or fails a write, is disconnected.
+type RemoteObserver struct {
+	// The observer's name
+	name string
+
+	// The TCP connection to the observer
+	conn net.Conn
+
+	// The timeout for each write, in milliseconds
+	timeout int
+
+	// Updates waiting to be written
+	queue chan data.Observation
+
+	// Guards closed, and sends on the queue
+	lock   sync.Mutex
+	closed bool
+}
+
+// Create a remote Observer over the given connection, writing each update
+// with the given timeout in milliseconds
+func NewRemoteObserver(name string, conn net.Conn, timeout int) *RemoteObserver {
+	o := &RemoteObserver{
+		name:    name,
+		conn:    conn,
+		timeout: timeout,
+		queue:   make(chan data.Observation, OBSERVER_QUEUE_SIZE),
+	}
+	go o.write()
+	return o
+}
+
+// Get the name of this Observer
+func (o *RemoteObserver) Name() string {
+	return o.name
+}
+
+//Receive an updated board
+func (o *RemoteObserver) ReceiveBoard(b board.IBoard) {
+	o.send(data.Observation{Kind: data.OBSERVE_BOARD, Board: b})
+}
+
+//Receive the final Move that wins a Game
+func (o *RemoteObserver) ReceiveWinningMove(move data.MoveJSON) {
+	o.send(data.Observation{Kind: data.OBSERVE_WINNING_MOVE, Move: move})
+}
+
+//Receive a full Turn performed
+func (o *RemoteObserver) ReceiveTurn(turn data.MoveBuildJSON) {
+	o.send(data.Observation{Kind: data.OBSERVE_TURN, Turn: turn})
+}
+
+//Receive an endgame state
+func (o *RemoteObserver) ReceiveEndgame(end rules.GameResult) {
+	o.send(data.Observation{Kind: data.OBSERVE_ENDGAME, Result: end})
+}
+
+// Stop sending updates, and close the connection once every queued update
+// has been written
+func (o *RemoteObserver) Close() error {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	if !o.closed {
+		o.closed = true
+		close(o.queue)
+	}
+	return nil
+}
+
+// Queue an update without blocking, disconnecting the observer if it has
+// fallen too far behind
+func (o *RemoteObserver) send(update data.Observation) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	if o.closed {
+		return
+	}
+
+	select {
+	case o.queue <- update:
+	default:
+		o.closed = true
+		close(o.queue)
+		o.conn.Close()
+	}
+}
+
+// Write queued updates to the connection until the queue is closed,
+// or a write fails
+func (o *RemoteObserver) write() {
+	defer o.conn.Close()
+	encoder := json.NewEncoder(o.conn)
+	duration := time.Duration(o.timeout) * sandbox.TIMEOUT_UNIT
+
+	for update := range o.queue {
+		o.conn.SetWriteDeadline(time.Now().Add(duration))
+		if err := encoder.Encode(update); err != nil {
+			o.Close()
+			// drain whatever is left so senders never see a full queue
+			for range o.queue {
+			}
+			return
+		}
+	}
+}
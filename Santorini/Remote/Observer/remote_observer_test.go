@@ -0,0 +1,62 @@
+package remote
+
+import (
+	"encoding/json"
+	"net"
+	"testing"
+	"time"
+
+	board "github.com/CS4500-F18/dare-rebr/Santorini/Common/Board"
+	data "github.com/CS4500-F18/dare-rebr/Santorini/Common/JSON"
+	rules "github.com/CS4500-F18/dare-rebr/Santorini/Common/Rules"
+)
+
+// An observer that never reads should not block whoever is sending it updates
+func TestRemoteObserver_DeadObserverNeverBlocks(t *testing.T) {
+	server, client := net.Pipe()
+	defer client.Close()
+
+	observer := NewRemoteObserver("fido", server, 10000)
+
+	done := make(chan bool)
+	go func() {
+		for i := 0; i < OBSERVER_QUEUE_SIZE*4; i++ {
+			observer.ReceiveBoard(board.BaseBoard())
+		}
+		done <- true
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Sending updates to a dead observer blocked")
+	}
+}
+
+// Updates should arrive in order, as tagged observation messages
+func TestRemoteObserver_Stream(t *testing.T) {
+	server, client := net.Pipe()
+	defer client.Close()
+
+	observer := NewRemoteObserver("fido", server, 10000)
+	observer.ReceiveBoard(board.BaseBoard())
+	observer.ReceiveEndgame(rules.GameResult{Winner: "uno", Loser: "dos", Reason: rules.WINNING_MOVE_MSG})
+	observer.Close()
+
+	decoder := json.NewDecoder(client)
+	expected := []string{data.OBSERVE_BOARD, data.OBSERVE_ENDGAME}
+	for _, kind := range expected {
+		var update data.Observation
+		if err := decoder.Decode(&update); err != nil {
+			t.Fatal(err)
+		}
+		if update.Kind != kind {
+			t.Errorf("Expected a %s update, got %s", kind, update.Kind)
+		}
+	}
+
+	var extra data.Observation
+	if err := decoder.Decode(&extra); err == nil {
+		t.Error("Connection should close once all updates are sent")
+	}
+}
@@ -0,0 +1,99 @@
+package remote
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	lib "github.com/CS4500-F18/dare-rebr/Santorini/Lib"
+)
+
+// When a dropped connection becomes a forfeit:
+//  - the player doesn't reconnect within Grace, or
+//  - the player has already dropped MaxDrops times (0 for no limit)
+// A zero Grace forfeits every dropped connection at once
+type ForfeitRule struct {
+	Grace    time.Duration
+	MaxDrops int
+}
+
+// Proxies whose players may reconnect with a session token, issued at
+// registration, and resume where they left off
+type Reconnector struct {
+	rule ForfeitRule
+
+	lock    sync.Mutex
+	proxies map[string]*ProxyPlayer
+}
+
+// Create a Reconnector that forfeits players by the given rule
+func NewReconnector(rule ForfeitRule) *Reconnector {
+	return &Reconnector{rule: rule, proxies: make(map[string]*ProxyPlayer)}
+}
+
+// The rule players are forfeited by
+func (r *Reconnector) Rule() ForfeitRule {
+	return r.rule
+}
+
+// Issue a new session token to the proxy, with which its player can resume
+func (r *Reconnector) Track(p *ProxyPlayer) (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(buf)
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.proxies[token] = p
+	p.token, p.reconnector, p.resumed = token, r, make(chan *lib.Session, 1)
+	return token, nil
+}
+
+// Whether the given token can still be resumed
+func (r *Reconnector) Has(token string) bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	_, found := r.proxies[token]
+	return found
+}
+
+// Hand a new session to the proxy holding the given token, returning the
+// proxy, or false if the token is unknown or its player has been forfeited
+func (r *Reconnector) Resume(token string, session *lib.Session) (*ProxyPlayer, bool) {
+	r.lock.Lock()
+	p, found := r.proxies[token]
+	r.lock.Unlock()
+	if !found {
+		return nil, false
+	}
+
+	p.resume(session)
+	return p, true
+}
+
+// Stop accepting the given token
+func (r *Reconnector) Forget(token string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	delete(r.proxies, token)
+}
+
+// Whether an error from a session means the connection was lost, rather
+// than the player being too slow or sending garbage
+func disconnected(err error) bool {
+	if netErr, ok := err.(net.Error); ok {
+		return !netErr.Timeout()
+	}
+	return err == io.EOF || err == io.ErrUnexpectedEOF || err == io.ErrClosedPipe
+}
+
+// Whether an error from a session means the player took too long to reply
+func timedOut(err error) bool {
+	netErr, ok := err.(net.Error)
+	return ok && netErr.Timeout()
+}
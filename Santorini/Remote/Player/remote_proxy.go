@@ -2,13 +2,15 @@ package remote
 
 import (
 	"encoding/json"
-	"net"
+	"fmt"
+	"sync"
 	"time"
 
 	sandbox "github.com/CS4500-F18/dare-rebr/Santorini/Admin/Sandbox"
 	board "github.com/CS4500-F18/dare-rebr/Santorini/Common/Board"
 	data "github.com/CS4500-F18/dare-rebr/Santorini/Common/JSON"
 	iplayer "github.com/CS4500-F18/dare-rebr/Santorini/Common/Player"
+	rules "github.com/CS4500-F18/dare-rebr/Santorini/Common/Rules"
 	result "github.com/CS4500-F18/dare-rebr/Santorini/Common/Tournament"
 	lib "github.com/CS4500-F18/dare-rebr/Santorini/Lib"
 )
@@ -17,86 +19,135 @@ import (
 //that will send JSON data to a player
 //NOTE implements IPlayer interface
 type ProxyPlayer struct {
-	//The TCP connection to the player
-	conn net.Conn
+	//The session over the player's TCP connection, which bounds
+	//each message by the timeout. Replaced if the player reconnects
+	session     *lib.Session
+	sessionLock sync.Mutex
 
-	// The timeout for TCP communication
-	timeout int
+	// The opponent in the current series, needed to describe their turns
+	opponent string
+
+	// The name the player registered with
+	name string
+
+	// Whether the player speaks the untagged protocol, rather than Envelopes
+	legacy bool
+
+	// What was agreed on in the handshake
+	welcome data.Welcome
+
+	// The Seq of the last message sent, and the number of games started
+	seq  int
+	game int
+
+	// The last request sent, replayed if the player reconnects before replying
+	pending interface{}
+
+	// The token the player can resume with, where its new sessions arrive,
+	// and how many times it has dropped. Without a Reconnector, every
+	// dropped connection is final
+	token       string
+	reconnector *Reconnector
+	resumed     chan *lib.Session
+	drops       int
 }
 
-// Create a proxy Player over the given connection with the
-// given timeout in milliseconds
-func NewProxyPlayer(conn net.Conn, timeout int) ProxyPlayer {
-	return ProxyPlayer{conn: conn, timeout: timeout}
+// Create a proxy Player over the given session, for a player
+// that registered with the given name and was sent the given Welcome
+func NewProxyPlayer(session *lib.Session, name string, welcome data.Welcome) *ProxyPlayer {
+	return &ProxyPlayer{session: session, name: name, welcome: welcome}
 }
 
-// Get the name of this Player
-func (p ProxyPlayer) Name() (string, error) {
-	_, decoder := lib.JSONStreams(p.conn)
+// Create a proxy Player for a player that registered with a bare name,
+// and so is sent bare, untagged messages instead of Envelopes
+func NewLegacyProxyPlayer(session *lib.Session, name string) *ProxyPlayer {
+	welcome := data.Welcome{Features: data.LEGACY_FEATURES, Variant: data.STANDARD_VARIANT}
+	return &ProxyPlayer{session: session, name: name, legacy: true, welcome: welcome}
+}
 
-	var name string
-	err := p.getWithTimeout(p.timeout, &name, decoder)
-	if err != nil {
-		return "", err
-	}
-	return name, nil
+// Get the name this Player registered with
+func (p *ProxyPlayer) Name() (string, error) {
+	return p.name, nil
 }
 
 //SetName tells a Player what their assigned Name is, given
 //that it may be non-unique
-func (p ProxyPlayer) SetName(newName string) error {
-	encoder, _ := lib.JSONStreams(p.conn)
-	rename := data.Rename{Name: newName}
-	return encoder.Encode(rename)
+func (p *ProxyPlayer) SetName(newName string) error {
+	p.name = newName
+	if p.legacy {
+		return p.send(data.MSG_RENAME, &data.Rename{Name: newName})
+	}
+	return p.send(data.MSG_RENAME, newName)
 }
 
 //PlaceWorker gets the location to place your next worker
-func (p ProxyPlayer) PlaceWorker(b board.IBoard) (board.Pos, error) {
-	encoder, decoder := lib.JSONStreams(p.conn)
+func (p *ProxyPlayer) PlaceWorker(b board.IBoard) (board.Pos, error) {
+	invalid := board.Pos{X: -1, Y: -1}
 
 	// Send Workers
-	workers := b.Workers()
-	if err := encoder.Encode(workers); err != nil {
-		return board.Pos{X: -1, Y: -1}, err
-	} else {
-		// Listen for response
-		var pos board.Pos
-		err := p.getWithTimeout(p.timeout, &pos, decoder)
-		return pos, err
+	if err := p.send(data.MSG_PLACE_REQUEST, b.Workers()); err != nil {
+		return invalid, err
+	}
+
+	// Listen for response
+	reply, err := p.receive(data.MSG_PLACEMENT)
+	if err != nil {
+		if timedOut(err) {
+			sandbox.TIMEOUTS.Inc("PlaceWorker()")
+		}
+		return invalid, err
 	}
+	var pos board.Pos
+	err = reply.Decode(&pos)
+	return pos, err
 }
 
 //NextTurn gets the next turn, including which worker ID to act on
-func (p ProxyPlayer) NextTurn(b board.IBoard) (iplayer.Turn, error) {
-	encoder, decoder := lib.JSONStreams(p.conn)
-
+func (p *ProxyPlayer) NextTurn(b board.IBoard) (iplayer.Turn, error) {
 	invalid := iplayer.Turn{-1, board.Pos{X: -1, Y: -1}, board.Pos{X: -1, Y: -1}}
 
-	if err := encoder.Encode(b); err != nil {
-		return iplayer.Turn{}, err
-	} else {
-		// Listen for response
-		var iface interface{}
-		err := p.getWithTimeout(p.timeout, &iface, decoder)
-		if err != nil {
-			return invalid, err
+	if err := p.send(data.MSG_TURN_REQUEST, b); err != nil {
+		return invalid, err
+	}
+
+	// Listen for response
+	reply, err := p.receive(data.MSG_TURN, data.MSG_GIVE_UP)
+	if err != nil {
+		if timedOut(err) {
+			sandbox.TIMEOUTS.Inc("NextTurn()")
 		}
-		buf, _ := json.Marshal(iface)
+		return invalid, err
+	}
 
-		if turn, err := p.TryGiveUp(buf); err == nil {
+	if p.legacy {
+		// Untagged, so try each kind of reply in turn
+		if turn, err := p.TryGiveUp(reply.Payload); err == nil {
 			return turn, nil
-		} else if turn, err := p.TryMoveTurn(b, buf); err == nil {
+		} else if turn, err := p.TryMoveTurn(b, reply.Payload); err == nil {
 			return turn, nil
-		} else if turn, err := p.TryMoveBuildTurn(b, buf); err == nil {
+		} else if turn, err := p.TryMoveBuildTurn(b, reply.Payload); err == nil {
 			return turn, nil
 		} else {
 			return invalid, err
 		}
 	}
+
+	if reply.Type == data.MSG_GIVE_UP {
+		if !p.welcome.Has(data.FEATURE_RESIGN) {
+			return invalid, fmt.Errorf("%s resigned without negotiating %s", p.name, data.FEATURE_RESIGN)
+		}
+		return invalid, nil
+	} else if turn, err := p.TryMoveBuildTurn(b, reply.Payload); err == nil {
+		return turn, nil
+	} else if turn, err := p.TryMoveTurn(b, reply.Payload); err == nil {
+		return turn, nil
+	} else {
+		return invalid, err
+	}
 }
 
 // Receive an attempted give-up from a Player
-func (p ProxyPlayer) TryGiveUp(buf []byte) (iplayer.Turn, error) {
+func (p *ProxyPlayer) TryGiveUp(buf []byte) (iplayer.Turn, error) {
 	turn := iplayer.Turn{-1, board.Pos{-1, -1}, board.Pos{-1, -1}}
 
 	var str string
@@ -108,7 +159,7 @@ func (p ProxyPlayer) TryGiveUp(buf []byte) (iplayer.Turn, error) {
 }
 
 // Attempt to decode into a move/build turn
-func (p ProxyPlayer) TryMoveBuildTurn(b board.IBoard, buf []byte) (iplayer.Turn, error) {
+func (p *ProxyPlayer) TryMoveBuildTurn(b board.IBoard, buf []byte) (iplayer.Turn, error) {
 	turn := iplayer.Turn{-1, board.Pos{-1, -1}, board.Pos{-1, -1}}
 
 	var mbt data.MoveBuildTurn
@@ -122,7 +173,7 @@ func (p ProxyPlayer) TryMoveBuildTurn(b board.IBoard, buf []byte) (iplayer.Turn,
 }
 
 // Attempt to decode into a solely-move turn
-func (p ProxyPlayer) TryMoveTurn(b board.IBoard, buf []byte) (iplayer.Turn, error) {
+func (p *ProxyPlayer) TryMoveTurn(b board.IBoard, buf []byte) (iplayer.Turn, error) {
 	turn := iplayer.Turn{-1, board.Pos{X: -1, Y: -1}, board.Pos{X: -1, Y: -1}}
 
 	var mt data.MoveTurn
@@ -136,25 +187,178 @@ func (p ProxyPlayer) TryMoveTurn(b board.IBoard, buf []byte) (iplayer.Turn, erro
 }
 
 //Opponent informs the Player of the opponent they are playing
-func (p ProxyPlayer) SetOpponent(name string) error {
-	encoder, _ := lib.JSONStreams(p.conn)
-	return encoder.Encode(name)
+func (p *ProxyPlayer) SetOpponent(name string) error {
+	p.opponent = name
+	return p.send(data.MSG_OPPONENT, name)
+}
+
+//StartSeries informs the Player of a new series against the given opponent
+func (p *ProxyPlayer) StartSeries(opponent string, games int) error {
+	p.opponent = opponent
+	return p.lifecycle(data.MSG_SERIES_START, data.SeriesStart{Opponent: opponent, Games: games})
+}
+
+//StartGame informs the Player of a new game, and whether they go first
+func (p *ProxyPlayer) StartGame(game int, order int) error {
+	p.game++
+	return p.lifecycle(data.MSG_GAME_START, data.GameStart{Game: game, Order: order})
+}
+
+//OpponentTurn informs the Player of the Turn their opponent took on the given Board
+func (p *ProxyPlayer) OpponentTurn(b board.IBoard, t iplayer.Turn) error {
+	return p.lifecycle(data.MSG_OPPONENT_TURN, data.OpponentTurn{Player: p.opponent, Board: b, Turn: t})
+}
+
+//EndGame informs the Player of how a game ended
+func (p *ProxyPlayer) EndGame(result rules.GameResult) error {
+	return p.lifecycle(data.MSG_GAME_END, data.GameEnd{Result: result})
+}
+
+// Send a lifecycle notification, only to a player that asked for them. A
+// player speaking the untagged protocol never does (see LEGACY_FEATURES)
+func (p *ProxyPlayer) lifecycle(kind string, payload interface{}) error {
+	if !p.welcome.Has(data.FEATURE_LIFECYCLE) {
+		return nil
+	}
+	return p.send(kind, payload)
+}
+
+//Waiting tells a registered Player how registration is going, if it asked to
+//hear. A dropped connection isn't waited for here, only when there is a request
+func (p *ProxyPlayer) Waiting(status data.WaitingRoom) error {
+	if !p.welcome.Has(data.FEATURE_WAITING_ROOM) {
+		return nil
+	}
+	env, err := data.NewEnvelope(data.MSG_WAITING, 0, 0, status)
+	if err != nil {
+		return err
+	}
+	return p.current().Send(env)
 }
 
 // Get the results of a tournament
-func (p ProxyPlayer) ReceiveTournamentResult(result result.TournamentResult) error {
-	defer p.conn.Close()
-	encoder, _ := lib.JSONStreams(p.conn)
-	err := encoder.Encode(result)
-	return err
+func (p *ProxyPlayer) ReceiveTournamentResult(result result.TournamentResult) error {
+	if p.reconnector != nil {
+		p.reconnector.Forget(p.token)
+	}
+	defer p.current().Close()
+	return p.send(data.MSG_RESULTS, result)
+}
+
+// Send a message of the given type to the player, wrapped in an Envelope
+// unless the player speaks the legacy protocol
+func (p *ProxyPlayer) send(kind string, payload interface{}) error {
+	var msg interface{} = payload
+	if !p.legacy {
+		p.seq++
+		env, err := data.NewEnvelope(kind, p.game, p.seq, payload)
+		if err != nil {
+			return err
+		}
+		msg = env
+	}
+
+	p.pending = msg
+	for {
+		err := p.current().Send(msg)
+		if err == nil || !disconnected(err) || !p.reconnect() {
+			return err
+		}
+	}
+}
+
+// Receive the player's reply to the last message sent, which must be one of
+// the given types. A legacy reply is returned as the untyped payload of an Envelope.
+// If the player reconnects while we wait, the request is sent again
+func (p *ProxyPlayer) receive(kinds ...string) (data.Envelope, error) {
+	var env data.Envelope
+	for {
+		var err error
+		if p.legacy {
+			var raw json.RawMessage
+			err = p.current().Receive(&raw)
+			env = data.Envelope{Payload: raw}
+		} else {
+			err = p.current().Receive(&env)
+		}
+
+		if err == nil {
+			break
+		} else if !disconnected(err) {
+			return env, err
+		} else if err := p.replay(); err != nil {
+			return env, err
+		}
+	}
+
+	if p.legacy {
+		return env, nil
+	}
+	return env, env.Expect(p.seq, kinds...)
+}
+
+// Wait for the player to reconnect, then send the pending request again
+func (p *ProxyPlayer) replay() error {
+	for {
+		if !p.reconnect() {
+			return fmt.Errorf("%s disconnected", p.name)
+		}
+		err := p.current().Send(p.pending)
+		if err == nil || !disconnected(err) {
+			return err
+		}
+	}
 }
 
-// Generic accessor with a timeout on the TCP connection
-func (p ProxyPlayer) getWithTimeout(timeout int, target interface{}, decoder *json.Decoder) error {
-	duration := time.Duration(timeout) * sandbox.TIMEOUT_UNIT
-	p.conn.SetDeadline(time.Now().Add(duration)) // Stop I/O after duration
-	defer p.conn.SetDeadline(time.Time{})        // Zero value -- no timeout
+// Wait for the player to reconnect after a dropped connection, within the
+// grace window. Returns false if the drop is a forfeit
+func (p *ProxyPlayer) reconnect() bool {
+	p.current().Close()
+	if p.reconnector == nil {
+		return false
+	}
+
+	rule := p.reconnector.Rule()
+	p.drops++
+	if rule.MaxDrops > 0 && p.drops > rule.MaxDrops {
+		p.reconnector.Forget(p.token)
+		return false
+	}
+
+	select {
+	case session := <-p.resumed:
+		p.sessionLock.Lock()
+		p.session = session
+		p.sessionLock.Unlock()
+		return true
+	case <-time.After(rule.Grace):
+		p.reconnector.Forget(p.token)
+		return false
+	}
+}
+
+// Take over from the current session with a new one, from the player
+// reconnecting. The current session is closed, so any wait on it ends
+func (p *ProxyPlayer) resume(session *lib.Session) {
+	select {
+	case old := <-p.resumed:
+		// Superseded before it was picked up
+		old.Close()
+	default:
+	}
+	select {
+	case p.resumed <- session:
+	default:
+		// Another session got there first
+		session.Close()
+		return
+	}
+	p.current().Close()
+}
 
-	err := decoder.Decode(target)
-	return err
+// The current session to the player
+func (p *ProxyPlayer) current() *lib.Session {
+	p.sessionLock.Lock()
+	defer p.sessionLock.Unlock()
+	return p.session
 }
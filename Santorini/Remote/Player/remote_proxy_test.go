@@ -0,0 +1,225 @@
+package remote
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net"
+	"testing"
+	"time"
+
+	ref "github.com/CS4500-F18/dare-rebr/Santorini/Admin/Referee"
+	sandbox "github.com/CS4500-F18/dare-rebr/Santorini/Admin/Sandbox"
+	board "github.com/CS4500-F18/dare-rebr/Santorini/Common/Board"
+	data "github.com/CS4500-F18/dare-rebr/Santorini/Common/JSON"
+	iplayer "github.com/CS4500-F18/dare-rebr/Santorini/Common/Player"
+	rules "github.com/CS4500-F18/dare-rebr/Santorini/Common/Rules"
+	result "github.com/CS4500-F18/dare-rebr/Santorini/Common/Tournament"
+	client "github.com/CS4500-F18/dare-rebr/Santorini/Player/Client"
+	lib "github.com/CS4500-F18/dare-rebr/Santorini/Lib"
+	relay "github.com/CS4500-F18/dare-rebr/Santorini/Remote/Relay"
+)
+
+func TestProxyPlayer_Envelopes(t *testing.T) {
+	playRemotely(t, relay.NewPlayerRelay(client.ValidPlayer("fido")), false)
+}
+
+func TestProxyPlayer_Legacy(t *testing.T) {
+	playRemotely(t, relay.NewLegacyPlayerRelay(client.ValidPlayer("fido")), true)
+}
+
+// A Player behind a relay should register, place, take a turn and finish,
+// speaking whichever protocol it registered with
+func playRemotely(t *testing.T, r relay.PlayerRelay, legacy bool) {
+	server, conn := net.Pipe()
+	done := make(chan bool, 1)
+	go r.ListenAndRespond(conn, done)
+
+	session := lib.NewSession(server)
+	session.SetTimeout(10 * time.Second)
+
+	var reg data.Registration
+	if err := session.Receive(&reg); err != nil {
+		t.Fatal(err)
+	}
+	if reg.Name != "fido" || reg.Tagged == legacy {
+		t.Fatalf("Wrong registration: %+v", reg)
+	}
+
+	proxy := NewLegacyProxyPlayer(session, reg.Name)
+	if reg.Tagged {
+		welcome, err := data.Negotiate(reg.Hello, data.SUPPORTED_FEATURES, 1000)
+		if err != nil {
+			t.Fatal(err)
+		}
+		env, _ := data.NewEnvelope(data.MSG_WELCOME, 0, 0, welcome)
+		session.Send(env)
+		proxy = NewProxyPlayer(session, reg.Name, welcome)
+	}
+	if err := proxy.SetOpponent("rex"); err != nil {
+		t.Fatal(err)
+	}
+
+	b := board.IBoard(board.BaseBoard())
+	pos, err := proxy.PlaceWorker(b)
+	if err != nil || !rules.CheckPlaceWorker(b, pos) {
+		t.Fatalf("Bad placement %+v: %v", pos, err)
+	}
+
+	b, _ = b.PlaceWorker(board.Pos{X: 0, Y: 0}, "fido")
+	b, _ = b.PlaceWorker(board.Pos{X: 5, Y: 5}, "rex")
+	b, _ = b.PlaceWorker(board.Pos{X: 2, Y: 2}, "fido")
+	b, _ = b.PlaceWorker(board.Pos{X: 5, Y: 0}, "rex")
+	turn, err := proxy.NextTurn(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.FindWorker("fido", turn.WID); err != nil {
+		t.Fatalf("Turn with no worker: %+v", turn)
+	}
+
+	games := []result.MatchResult{result.NewMatchResult("fido", "rex", false, nil)}
+	if err := proxy.ReceiveTournamentResult(result.TournamentResult{Games: games}); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("Relay never finished")
+	}
+}
+
+// A tagged reply to an older request is rejected
+func TestProxyPlayer_StaleReply(t *testing.T) {
+	server, conn := net.Pipe()
+	proxy := NewProxyPlayer(lib.NewSession(server), "fido", data.Welcome{Version: data.PROTOCOL_VERSION})
+
+	go func() {
+		var req data.Envelope
+		decoder := json.NewDecoder(conn)
+		decoder.Decode(&req)
+		reply, _ := data.NewEnvelope(data.MSG_PLACEMENT, req.Game, req.Seq-1, board.Pos{X: 0, Y: 0})
+		json.NewEncoder(conn).Encode(reply)
+	}()
+
+	if _, err := proxy.PlaceWorker(board.BaseBoard()); err == nil {
+		t.Error("Reply with the wrong sequence number was accepted")
+	}
+}
+
+// Without the lifecycle feature, lifecycle notifications are never sent
+func TestProxyPlayer_NoLifecycle(t *testing.T) {
+	server, conn := net.Pipe()
+	proxy := NewProxyPlayer(lib.NewSession(server), "fido", data.Welcome{Version: data.PROTOCOL_VERSION})
+
+	sent := make(chan data.Envelope, 1)
+	go func() {
+		var env data.Envelope
+		json.NewDecoder(conn).Decode(&env)
+		sent <- env
+		io.Copy(ioutil.Discard, conn)
+	}()
+	defer conn.Close()
+
+	if err := proxy.StartGame(0, 0); err != nil {
+		t.Fatal(err)
+	}
+	proxy.SetOpponent("rex")
+
+	if env := <-sent; env.Type != data.MSG_OPPONENT {
+		t.Errorf("Sent a %s message before the opponent", env.Type)
+	}
+}
+
+// A player speaking the untagged protocol is never sent lifecycle
+// notifications, which it can't tell from other messages
+func TestProxyPlayer_LegacyNoLifecycle(t *testing.T) {
+	server, conn := net.Pipe()
+	proxy := NewLegacyProxyPlayer(lib.NewSession(server), "fido")
+
+	sent := make(chan json.RawMessage, 1)
+	go func() {
+		var msg json.RawMessage
+		json.NewDecoder(conn).Decode(&msg)
+		sent <- msg
+		io.Copy(ioutil.Discard, conn)
+	}()
+	defer conn.Close()
+
+	proxy.StartSeries("rex", 3)
+	proxy.StartGame(0, 0)
+	proxy.OpponentTurn(board.BaseBoard(), iplayer.Turn{})
+	proxy.EndGame(rules.GameResult{Winner: "fido", Loser: "rex"})
+	proxy.SetOpponent("rex")
+
+	if msg := <-sent; string(msg) != `"rex"` {
+		t.Errorf("Sent %s before the opponent", msg)
+	}
+}
+
+// A client written before Envelopes, guessing what each message is in the
+// order the original relay did, plays a whole series and only stops at the
+// results
+func TestProxyPlayer_LegacySeries(t *testing.T) {
+	server, conn := net.Pipe()
+	proxy := NewLegacyProxyPlayer(lib.NewSession(server), "fido")
+
+	finished := make(chan bool, 1)
+	go func() {
+		defer conn.Close()
+		r := relay.NewLegacyPlayerRelay(client.ValidPlayer("fido"))
+		session := lib.NewSession(conn)
+		for {
+			var buf json.RawMessage
+			if session.Receive(&buf) != nil {
+				return
+			}
+			if r.TryRename(buf) == nil || r.TryOpponent(buf) == nil ||
+				r.TryPlacement(session, buf) == nil || r.TryBoard(session, buf) == nil {
+				continue
+			} else if r.TryResult(buf) == nil {
+				finished <- true
+				return
+			}
+		}
+	}()
+
+	rex := sandbox.NewTimeoutPlayer(sandbox.TIMEOUT_DEFAULT, client.ValidPlayer("rex"))
+	for _, game := range ref.NewReferee("fido", proxy, "rex", rex).BestOf(3) {
+		if game.BrokenRule {
+			t.Fatalf("Series ended irregularly: %+v", game)
+		}
+	}
+	select {
+	case <-finished:
+		t.Fatal("Relay took a message for the results")
+	default:
+	}
+
+	games := []result.MatchResult{result.NewMatchResult("fido", "rex", false, nil)}
+	if err := proxy.ReceiveTournamentResult(result.TournamentResult{Games: games}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-finished:
+	case <-time.After(10 * time.Second):
+		t.Fatal("Relay never finished")
+	}
+}
+
+// A player who doesn't reconnect within the grace window forfeits
+func TestProxyPlayer_GraceExpires(t *testing.T) {
+	server, conn := net.Pipe()
+	proxy := NewProxyPlayer(lib.NewSession(server), "fido", data.Welcome{Version: data.PROTOCOL_VERSION})
+	reconnector := NewReconnector(ForfeitRule{Grace: 50 * time.Millisecond})
+	token, _ := reconnector.Track(proxy)
+	conn.Close()
+
+	if _, err := proxy.PlaceWorker(board.BaseBoard()); err == nil {
+		t.Error("Placement from a disconnected player")
+	}
+	if reconnector.Has(token) {
+		t.Error("Forfeited player can still resume")
+	}
+}
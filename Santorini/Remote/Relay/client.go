@@ -0,0 +1,663 @@
+package remote
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	board "github.com/CS4500-F18/dare-rebr/Santorini/Common/Board"
+	data "github.com/CS4500-F18/dare-rebr/Santorini/Common/JSON"
+	iplayer "github.com/CS4500-F18/dare-rebr/Santorini/Common/Player"
+	result "github.com/CS4500-F18/dare-rebr/Santorini/Common/Tournament"
+	lib "github.com/CS4500-F18/dare-rebr/Santorini/Lib"
+)
+
+/*
+  The Client is the library a bot uses to play on a server: it connects,
+  registers, and then reads each message from the server in turn, calling the
+  bot's handler for it and sending back the reply to each request, until the
+  tournament is over
+*/
+
+// How long a Client waits before trying to connect again, at first. Each wait
+// is twice the last, up to MAX_BACKOFF
+const (
+	CONNECT_BACKOFF = 250 * time.Millisecond
+	MAX_BACKOFF     = 5 * time.Second
+)
+
+// How long to wait between attempts to reconnect to a server
+const RESUME_RETRY = 250 * time.Millisecond
+
+// Returned by Serve once the Connection has been closed
+var ErrClientClosed = errors.New("Client connection closed")
+
+// What a Client does with each message from the server. PlaceWorker and
+// NextTurn answer the server's requests, and must be set. The rest are only
+// told what happened, and are skipped if nil
+type Handlers struct {
+	// Where to place the next worker, among the workers placed so far
+	PlaceWorker func(b board.IBoard) board.Pos
+
+	// The next turn on the board. A turn with none of our workers gives up,
+	// and one without a build in bounds is a lone (winning) move
+	NextTurn func(b board.IBoard) iplayer.Turn
+
+	// The server has given us a new name
+	Rename func(name string)
+
+	// The name of our next opponent
+	Opponent func(name string)
+
+	// Registration is still open, and the tournament hasn't started
+	Waiting func(room data.WaitingRoom)
+
+	SeriesStart  func(start data.SeriesStart)
+	GameStart    func(start data.GameStart)
+	OpponentTurn func(turn data.OpponentTurn)
+	GameEnd      func(end data.GameEnd)
+
+	// The tournament is over, and these are the results
+	Results func(results []result.MatchResult)
+
+	// The server has accepted us, and agreed to this
+	Welcome func(welcome data.Welcome)
+}
+
+// A player that wants to know how long it has to answer each request, such as
+// a person, who can then be told
+type timeLimited interface {
+	SetTimeLimit(limit time.Duration)
+}
+
+// Handlers that pass every message on to the given player
+func PlayerHandlers(p iplayer.IPlayer) Handlers {
+	var welcome func(data.Welcome)
+	if limited, ok := p.(timeLimited); ok {
+		welcome = func(w data.Welcome) {
+			if w.TimeLimit > 0 {
+				limited.SetTimeLimit(time.Duration(w.TimeLimit) * time.Millisecond)
+			}
+		}
+	}
+	return Handlers{
+		PlaceWorker: p.PlaceWorker,
+		NextTurn:    p.NextTurn,
+		Rename:      p.SetName,
+		Opponent:    p.SetOpponent,
+		SeriesStart: func(start data.SeriesStart) {
+			p.StartSeries(start.Opponent, start.Games)
+		},
+		GameStart: func(start data.GameStart) {
+			p.StartGame(start.Game, start.Order)
+		},
+		OpponentTurn: func(turn data.OpponentTurn) {
+			p.OpponentTurn(turn.Board, turn.Turn)
+		},
+		GameEnd: func(end data.GameEnd) {
+			p.EndGame(end.Result)
+		},
+		Results: p.ReceiveTournamentResults,
+		Welcome: welcome,
+	}
+}
+
+type Client struct {
+	// The name to register with
+	name     string
+	handlers Handlers
+
+	// Whether to speak the untagged protocol, rather than Envelopes
+	legacy bool
+
+	// Proof we may use the name, or nil
+	auth *data.Auth
+
+	// The TLS settings to connect with, or nil for plain TCP
+	tls *tls.Config
+
+	// Directory to record a transcript of each connection in, or "" for none
+	transcripts string
+
+	// The lobby to join, or "" for the server's default
+	lobby string
+
+	// How many times to try connecting, and how long to wait after the
+	// first failure
+	attempts int
+	backoff  time.Duration
+}
+
+// Create an unconnected Client that registers with the given name, and
+// answers the server with the given handlers
+func NewClient(name string, handlers Handlers) Client {
+	return Client{name: name, handlers: handlers, attempts: 1, backoff: CONNECT_BACKOFF}
+}
+
+// A copy of this Client that speaks the untagged protocol, for servers that
+// predate Envelopes
+func (c Client) WithLegacy() Client {
+	c.legacy = true
+	return c
+}
+
+// A copy of this Client that authenticates with the given Auth when it
+// registers (only over the tagged protocol)
+func (c Client) WithAuth(auth data.Auth) Client {
+	c.auth = &auth
+	return c
+}
+
+// A copy of this Client that connects over TLS with the given settings
+func (c Client) WithTLS(config *tls.Config) Client {
+	c.tls = config
+	return c
+}
+
+// A copy of this Client that records a transcript of every message over each
+// connection to its own file in the given directory
+func (c Client) WithTranscripts(dir string) Client {
+	c.transcripts = dir
+	return c
+}
+
+// A copy of this Client that joins the named lobby, on a server hosting
+// several (only over the tagged protocol)
+func (c Client) WithLobby(lobby string) Client {
+	c.lobby = lobby
+	return c
+}
+
+// A copy of this Client that tries to connect the given number of times,
+// waiting the given time after the first failure and twice as long after each
+// one since. A server that rejects us isn't tried again
+func (c Client) WithRetry(attempts int, backoff time.Duration) Client {
+	c.attempts, c.backoff = attempts, backoff
+	return c
+}
+
+// Connect to the server and play until the tournament is over, returning its
+// results, or the reason we couldn't
+func (c Client) Run(host string, port int) ([]result.MatchResult, error) {
+	conn, err := c.Connect(host, port)
+	if err != nil {
+		return nil, err
+	}
+	return conn.Serve()
+}
+
+// Connect to the server and register, trying again as many times as we were
+// told to. Returns once the server has accepted us
+func (c Client) Connect(host string, port int) (*Connection, error) {
+	if err := c.check(); err != nil {
+		return nil, err
+	}
+
+	address := net.JoinHostPort(host, strconv.Itoa(port))
+	var connection *Connection
+	err := retry(c.attempts, c.backoff, func() error {
+		conn, err := c.dial(address, 0)
+		if err == nil {
+			connection, err = c.Register(conn, address)
+		}
+		return err
+	})
+	return connection, err
+}
+
+// Call the given function until it succeeds, up to the given number of times,
+// waiting the given time after the first failure and twice as long after each
+// one since. A Rejection isn't tried again
+func retry(attempts int, backoff time.Duration, try func() error) error {
+	wait := backoff
+	for attempt := 1; ; attempt++ {
+		err := try()
+		if err == nil {
+			return nil
+		}
+
+		if _, rejected := err.(data.Rejection); rejected || attempt >= attempts {
+			return err
+		}
+		time.Sleep(wait)
+		if wait *= 2; wait > MAX_BACKOFF {
+			wait = MAX_BACKOFF
+		}
+	}
+}
+
+// Register over a connection that is already open, to the given host:port as
+// it was dialed (so TLS can check the host name), where we resume if it drops.
+// With no address we don't resume
+func (c Client) Register(conn net.Conn, address string) (*Connection, error) {
+	if err := c.check(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	connection := c.over(lib.NewSession(conn))
+	connection.address = address
+	welcome, err := connection.register("")
+	if err != nil {
+		connection.session.Close()
+		return nil, err
+	}
+	connection.welcome = welcome
+	if c.handlers.Welcome != nil {
+		c.handlers.Welcome(welcome)
+	}
+	return connection, nil
+}
+
+// An error if the handlers can't answer the server's requests
+func (c Client) check() error {
+	if c.handlers.PlaceWorker == nil || c.handlers.NextTurn == nil {
+		return errors.New("A Client needs handlers for placements and turns")
+	}
+	return nil
+}
+
+// Connect to the server, recording a transcript if asked to
+func (c Client) dial(address string, timeout time.Duration) (net.Conn, error) {
+	conn, err := lib.Dial(address, c.tls, timeout)
+	if err != nil || c.transcripts == "" {
+		return conn, err
+	}
+	// Better to play unrecorded than not at all
+	conn, _ = lib.RecordConnTo(conn, c.transcripts, c.name+"-"+address)
+	return conn, nil
+}
+
+// A Connection over the given session, before registering
+func (c Client) over(session *lib.Session) *Connection {
+	return &Connection{client: c, name: c.name, session: session, closed: make(chan bool)}
+}
+
+// A Client's registration with a server, from when the server accepts it
+// until the tournament is over
+type Connection struct {
+	client Client
+
+	// Where to reconnect to, or "" if we can't
+	address string
+
+	// What the server agreed to
+	welcome data.Welcome
+
+	// The name we play as, which the server may change
+	name string
+
+	// The session in use, replaced each time we resume
+	lock    sync.Mutex
+	session *lib.Session
+
+	closed    chan bool
+	closeOnce sync.Once
+}
+
+// What the server agreed to when we registered
+func (c *Connection) Welcome() data.Welcome {
+	return c.welcome
+}
+
+// The name we play as, after any renaming by the server
+func (c *Connection) Name() string {
+	return c.name
+}
+
+// Read each message from the server, calling its handler and replying to
+// requests, until the tournament results arrive. If the connection drops and
+// the server agreed to resume, reconnect and carry on where we left off.
+// Returns the results, or why we stopped without them: the connection was
+// lost for good, the server sent something we can't make sense of, or the
+// Connection was closed
+func (c *Connection) Serve() ([]result.MatchResult, error) {
+	for {
+		results, lost, err := c.listen()
+		c.current().Close()
+		if err == nil {
+			return results, nil
+		}
+
+		select {
+		case <-c.closed:
+			return nil, ErrClientClosed
+		default:
+		}
+		if !lost {
+			return nil, err
+		}
+		session := c.resume()
+		if session == nil {
+			return nil, fmt.Errorf("Lost connection to the server: %v", err)
+		}
+		c.lock.Lock()
+		c.session = session
+		c.lock.Unlock()
+	}
+}
+
+// Hang up, ending Serve
+func (c *Connection) Close() error {
+	c.closeOnce.Do(func() {
+		close(c.closed)
+	})
+	return c.current().Close()
+}
+
+func (c *Connection) current() *lib.Session {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.session
+}
+
+// Register with the server, resuming the session with the given token if any,
+// and return what it agreed to
+func (c *Connection) register(token string) (data.Welcome, error) {
+	if c.client.legacy {
+		welcome := data.Welcome{Features: data.LEGACY_FEATURES, Variant: data.STANDARD_VARIANT}
+		return welcome, c.session.Send(c.name)
+	}
+
+	var welcome data.Welcome
+	reg := data.Registration{
+		Role:   data.PLAYER_ROLE,
+		Name:   c.name,
+		Tagged: true,
+		Hello:  data.NewHello(data.PLAYER_ROLE, c.name),
+	}
+	reg.Hello.Token = token
+	reg.Hello.Auth = c.client.auth
+	reg.Hello.Lobby = c.client.lobby
+	if err := c.session.Send(reg); err != nil {
+		return welcome, err
+	}
+	return awaitWelcome(c.session)
+}
+
+// Read what the server made of our registration: the Welcome, or the
+// Rejection as an error
+func awaitWelcome(session *lib.Session) (data.Welcome, error) {
+	var welcome data.Welcome
+	var env data.Envelope
+	if err := session.Receive(&env); err != nil {
+		return welcome, err
+	}
+	switch env.Type {
+	case data.MSG_WELCOME:
+		err := env.Decode(&welcome)
+		return welcome, err
+	case data.MSG_REJECT:
+		var rejection data.Rejection
+		if err := env.Decode(&rejection); err != nil {
+			return welcome, err
+		}
+		return welcome, rejection
+	default:
+		return welcome, fmt.Errorf("Expected a welcome, got a %s message", env.Type)
+	}
+}
+
+// Reconnect to the server with the session token it gave us, trying until the
+// grace window has passed. Returns nil if we can't resume
+func (c *Connection) resume() *lib.Session {
+	if c.welcome.Token == "" || c.address == "" {
+		return nil
+	}
+
+	deadline := time.Now().Add(time.Duration(c.welcome.Grace) * time.Millisecond)
+	for time.Now().Before(deadline) {
+		conn, err := c.client.dial(c.address, RESUME_RETRY)
+		if err == nil {
+			resumed := c.client.over(lib.NewSession(conn))
+			resumed.name = c.name
+			_, err := resumed.register(c.welcome.Token)
+			if err == nil {
+				return resumed.session
+			}
+			resumed.session.Close()
+			if _, rejected := err.(data.Rejection); rejected {
+				return nil
+			}
+		}
+
+		select {
+		case <-c.closed:
+			return nil
+		case <-time.After(RESUME_RETRY):
+		}
+	}
+	return nil
+}
+
+// Handle messages on the current session until the results arrive. Returns
+// the results, or an error and whether it was the connection that failed
+func (c *Connection) listen() ([]result.MatchResult, bool, error) {
+	session := c.current()
+	for {
+		var buf json.RawMessage
+		if err := session.Receive(&buf); err != nil {
+			return nil, true, err
+		}
+
+		var results []result.MatchResult
+		var finished bool
+		var err error
+		if c.client.legacy {
+			results, finished, err = c.guess(session, buf)
+		} else {
+			var env data.Envelope
+			if err = json.Unmarshal(buf, &env); err == nil {
+				results, finished, err = c.dispatch(session, env)
+			}
+		}
+
+		if _, failed := err.(sendError); failed {
+			return nil, true, err
+		} else if err != nil || finished {
+			return results, false, err
+		}
+	}
+}
+
+// An error sending a reply, which means the connection has failed
+type sendError struct {
+	error
+}
+
+func (c *Connection) send(session *lib.Session, msg interface{}) error {
+	if err := session.Send(msg); err != nil {
+		return sendError{err}
+	}
+	return nil
+}
+
+// Act on a single Envelope from the server, replying if it is a request.
+// Returns the results, and true, if it was the tournament results
+func (c *Connection) dispatch(session *lib.Session, env data.Envelope) ([]result.MatchResult, bool, error) {
+	if env.Version != data.PROTOCOL_VERSION {
+		return nil, false, fmt.Errorf("Unsupported protocol version: %v", env.Version)
+	}
+
+	switch env.Type {
+	case data.MSG_RENAME:
+		var name string
+		if err := env.Decode(&name); err != nil {
+			return nil, false, err
+		}
+		c.rename(name)
+
+	case data.MSG_OPPONENT:
+		var name string
+		if err := env.Decode(&name); err != nil {
+			return nil, false, err
+		}
+		if c.client.handlers.Opponent != nil {
+			c.client.handlers.Opponent(name)
+		}
+
+	case data.MSG_PLACE_REQUEST:
+		var workers []board.Worker
+		if err := env.Decode(&workers); err != nil {
+			return nil, false, err
+		}
+		reply, err := env.Reply(data.MSG_PLACEMENT, c.client.handlers.PlaceWorker(boardWithWorkers(workers)))
+		if err != nil {
+			return nil, false, err
+		}
+		return nil, false, c.send(session, reply)
+
+	case data.MSG_TURN_REQUEST:
+		b := board.BaseBoard()
+		if err := env.Decode(&b); err != nil {
+			return nil, false, err
+		}
+		reply, err := env.Reply(c.nextTurn(b))
+		if err != nil {
+			return nil, false, err
+		}
+		return nil, false, c.send(session, reply)
+
+	case data.MSG_SERIES_START, data.MSG_GAME_START, data.MSG_OPPONENT_TURN, data.MSG_GAME_END:
+		return nil, false, c.lifecycle(env.Payload)
+
+	case data.MSG_RESULTS:
+		results, err := c.results(env.Payload)
+		return results, err == nil, err
+
+	case data.MSG_WAITING:
+		var room data.WaitingRoom
+		if err := env.Decode(&room); err != nil {
+			return nil, false, err
+		}
+		if c.client.handlers.Waiting != nil {
+			c.client.handlers.Waiting(room)
+		}
+
+	default:
+		return nil, false, fmt.Errorf("Unknown message type: %s", env.Type)
+	}
+	return nil, false, nil
+}
+
+// Work out what an untagged message is, and act on it like dispatch
+func (c *Connection) guess(session *lib.Session, buf []byte) ([]result.MatchResult, bool, error) {
+	if err := c.lifecycle(buf); err == nil {
+		return nil, false, nil
+	}
+
+	var rename data.Rename
+	if err := json.Unmarshal(buf, &rename); err == nil {
+		c.rename(rename.Name)
+		return nil, false, nil
+	}
+
+	var opponent string
+	if err := json.Unmarshal(buf, &opponent); err == nil {
+		if c.client.handlers.Opponent != nil {
+			c.client.handlers.Opponent(opponent)
+		}
+		return nil, false, nil
+	}
+
+	var workers []board.Worker
+	if err := json.Unmarshal(buf, &workers); err == nil {
+		return nil, false, c.send(session, c.client.handlers.PlaceWorker(boardWithWorkers(workers)))
+	}
+
+	b := board.BaseBoard()
+	if err := json.Unmarshal(buf, &b); err == nil {
+		return nil, false, c.send(session, c.untaggedTurn(b))
+	}
+
+	if results, err := c.results(buf); err == nil {
+		return results, true, nil
+	}
+	return nil, false, fmt.Errorf("Unrecognized message: %s", buf)
+}
+
+// Take the name the server gave us
+func (c *Connection) rename(name string) {
+	c.name = name
+	if c.client.handlers.Rename != nil {
+		c.client.handlers.Rename(name)
+	}
+}
+
+// Pass a lifecycle notification on to its handler, or return an error if the
+// bytes aren't one
+func (c *Connection) lifecycle(buf []byte) error {
+	keyword, err := data.LifecycleKeyword(buf)
+	if err != nil {
+		return err
+	}
+
+	h := c.client.handlers
+	switch keyword {
+	case data.SERIES_START:
+		var start data.SeriesStart
+		if err = json.Unmarshal(buf, &start); err == nil && h.SeriesStart != nil {
+			h.SeriesStart(start)
+		}
+
+	case data.GAME_START:
+		var start data.GameStart
+		if err = json.Unmarshal(buf, &start); err == nil && h.GameStart != nil {
+			h.GameStart(start)
+		}
+
+	case data.OPPONENT_TURN:
+		var turn data.OpponentTurn
+		if err = json.Unmarshal(buf, &turn); err == nil && h.OpponentTurn != nil {
+			h.OpponentTurn(turn)
+		}
+
+	case data.GAME_END:
+		var end data.GameEnd
+		if err = json.Unmarshal(buf, &end); err == nil && h.GameEnd != nil {
+			h.GameEnd(end)
+		}
+	}
+	return err
+}
+
+// Pass the tournament results on to their handler, or return an error if the
+// bytes aren't them
+func (c *Connection) results(buf []byte) ([]result.MatchResult, error) {
+	var results [][]string
+	if err := json.Unmarshal(buf, &results); err != nil {
+		return nil, err
+	}
+	matches := matchResults(results)
+	if c.client.handlers.Results != nil {
+		c.client.handlers.Results(matches)
+	}
+	return matches, nil
+}
+
+// Get the next turn from the handler, as the type of message and the JSON
+// payload to send. Giving up has no payload
+func (c *Connection) nextTurn(b board.IBoard) (string, interface{}) {
+	turn := c.client.handlers.NextTurn(b)
+
+	if _, err := b.FindWorker(c.name, turn.WID); err != nil {
+		return data.MSG_GIVE_UP, nil
+	} else if !turn.BuildAt.InBounds() {
+		return data.MSG_TURN, data.MoveFromTurn(c.name, b, turn)
+	} else {
+		return data.MSG_TURN, data.MoveBuildFromTurn(c.name, b, turn)
+	}
+}
+
+// Get the next turn from the handler, as the JSON to send untagged, where
+// there's no type of message and giving up is sending our name
+func (c *Connection) untaggedTurn(b board.IBoard) interface{} {
+	if kind, turn := c.nextTurn(b); kind != data.MSG_GIVE_UP {
+		return turn
+	}
+	return c.name
+}
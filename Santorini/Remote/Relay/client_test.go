@@ -0,0 +1,234 @@
+package remote
+
+import (
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	board "github.com/CS4500-F18/dare-rebr/Santorini/Common/Board"
+	data "github.com/CS4500-F18/dare-rebr/Santorini/Common/JSON"
+	iplayer "github.com/CS4500-F18/dare-rebr/Santorini/Common/Player"
+	lib "github.com/CS4500-F18/dare-rebr/Santorini/Lib"
+	client "github.com/CS4500-F18/dare-rebr/Santorini/Player/Client"
+)
+
+// A server that welcomes one client, then sends it each message in turn,
+// passing every reply it gets to the test
+type scriptedServer struct {
+	listener net.Listener
+	replies  chan data.Envelope
+}
+
+func listenScripted(t *testing.T, messages ...data.Envelope) *scriptedServer {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &scriptedServer{listener: l, replies: make(chan data.Envelope, len(messages))}
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		session := lib.NewSession(conn)
+		defer session.Close()
+
+		var reg data.Registration
+		if session.Receive(&reg) != nil {
+			return
+		}
+		welcome, _ := data.NewEnvelope(data.MSG_WELCOME, 0, 0, data.Welcome{Version: data.PROTOCOL_VERSION})
+		session.Send(welcome)
+
+		for _, msg := range messages {
+			session.Send(msg)
+			if msg.Type == data.MSG_PLACE_REQUEST || msg.Type == data.MSG_TURN_REQUEST {
+				var reply data.Envelope
+				if session.Receive(&reply) != nil {
+					return
+				}
+				s.replies <- reply
+			}
+		}
+		// Wait for the client to hang up
+		var ignored interface{}
+		session.Receive(&ignored)
+	}()
+	return s
+}
+
+func (s *scriptedServer) port() int {
+	return s.listener.Addr().(*net.TCPAddr).Port
+}
+
+func envelope(t *testing.T, kind string, seq int, payload interface{}) data.Envelope {
+	env, err := data.NewEnvelope(kind, 0, seq, payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return env
+}
+
+// Each message reaches its handler, requests are answered, and the client
+// stops with the results
+func TestClient_Run(t *testing.T) {
+	workers := []board.Worker{board.NewWorker(board.Pos{X: 0, Y: 0}, "rex", 0).(board.Worker)}
+	b := setupBoard()
+	server := listenScripted(t,
+		envelope(t, data.MSG_WAITING, 1, data.WaitingRoom{Players: 1, MinPlayers: 2}),
+		envelope(t, data.MSG_RENAME, 2, "uno"),
+		envelope(t, data.MSG_SERIES_START, 3, data.SeriesStart{Opponent: "dos", Games: 3}),
+		envelope(t, data.MSG_PLACE_REQUEST, 4, workers),
+		envelope(t, data.MSG_TURN_REQUEST, 5, b),
+		envelope(t, data.MSG_RESULTS, 6, [][]string{{"uno", "dos"}}),
+	)
+	defer server.listener.Close()
+
+	// The server renames fido to uno before asking for a turn
+	var welcomed, waited, series bool
+	player := client.ValidPlayer("uno")
+	handlers := Handlers{
+		PlaceWorker: player.PlaceWorker,
+		NextTurn:    player.NextTurn,
+		Waiting:     func(room data.WaitingRoom) { waited = room.MinPlayers == 2 },
+		SeriesStart: func(start data.SeriesStart) { series = start.Opponent == "dos" },
+		Welcome:     func(welcome data.Welcome) { welcomed = welcome.Version == data.PROTOCOL_VERSION },
+	}
+
+	results, err := NewClient("fido", handlers).Run("127.0.0.1", server.port())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Winner != "uno" || results[0].Loser != "dos" {
+		t.Errorf("Wrong results: %+v", results)
+	}
+	if !welcomed || !waited || !series {
+		t.Errorf("Handlers weren't called: welcome %v, waiting %v, series %v", welcomed, waited, series)
+	}
+
+	if placement := <-server.replies; placement.Type != data.MSG_PLACEMENT || placement.Seq != 4 {
+		t.Errorf("Wrong reply to the placement request: %+v", placement)
+	}
+	if turn := <-server.replies; turn.Type != data.MSG_TURN || turn.Seq != 5 {
+		t.Errorf("Wrong reply to the turn request: %+v", turn)
+	}
+}
+
+// A turn without a worker of ours gives up, with no payload
+func TestClient_GiveUp(t *testing.T) {
+	server := listenScripted(t,
+		envelope(t, data.MSG_TURN_REQUEST, 1, setupBoard()),
+		envelope(t, data.MSG_RESULTS, 2, [][]string{}),
+	)
+	defer server.listener.Close()
+
+	handlers := PlayerHandlers(client.ValidPlayer("fido"))
+	handlers.NextTurn = func(b board.IBoard) iplayer.Turn { return iplayer.Turn{WID: 9} }
+	if _, err := NewClient("fido", handlers).Run("127.0.0.1", server.port()); err != nil {
+		t.Fatal(err)
+	}
+	if reply := <-server.replies; reply.Type != data.MSG_GIVE_UP || len(reply.Payload) != 0 {
+		t.Errorf("Wrong reply to the turn request: %+v", reply)
+	}
+}
+
+// We resume at the address as dialed, host name and all, not the one it
+// resolved to
+func TestClient_ResumeAddress(t *testing.T) {
+	server := listenScripted(t)
+	defer server.listener.Close()
+
+	conn, err := NewClient("fido", PlayerHandlers(client.ValidPlayer("fido"))).Connect("localhost", server.port())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if want := "localhost:" + strconv.Itoa(server.port()); conn.address != want {
+		t.Errorf("Would resume at %q, not %q", conn.address, want)
+	}
+}
+
+// A message the client can't make sense of ends it with an error
+func TestClient_ProtocolError(t *testing.T) {
+	server := listenScripted(t, envelope(t, "surprise", 1, nil))
+	defer server.listener.Close()
+
+	if _, err := NewClient("fido", PlayerHandlers(client.ValidPlayer("fido"))).Run("127.0.0.1", server.port()); err == nil {
+		t.Error("Expected an error for an unknown message")
+	}
+}
+
+// Connecting backs off and tries again until the server is there, and Close
+// ends Serve
+func TestClient_Retry(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := l.Addr().(*net.TCPAddr).Port
+	l.Close()
+
+	c := NewClient("fido", PlayerHandlers(client.ValidPlayer("fido")))
+	if _, err := c.Connect("127.0.0.1", port); err == nil {
+		t.Fatal("Connected to nothing")
+	}
+
+	started := make(chan *scriptedServer, 1)
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		l, err := net.Listen("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(port)))
+		if err != nil {
+			started <- nil
+			return
+		}
+		server := &scriptedServer{listener: l}
+		started <- server
+		go func() {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			session := lib.NewSession(conn)
+			var reg data.Registration
+			session.Receive(&reg)
+			welcome, _ := data.NewEnvelope(data.MSG_WELCOME, 0, 0, data.Welcome{Version: data.PROTOCOL_VERSION})
+			session.Send(welcome)
+		}()
+	}()
+
+	conn, err := c.WithRetry(5, 50*time.Millisecond).Connect("127.0.0.1", port)
+	if server := <-started; server == nil {
+		t.Skip("Port was taken before the server could listen again")
+	} else {
+		defer server.listener.Close()
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	served := make(chan error, 1)
+	go func() {
+		_, err := conn.Serve()
+		served <- err
+	}()
+	conn.Close()
+	select {
+	case err := <-served:
+		if err != ErrClientClosed {
+			t.Errorf("Expected the client to be closed, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Serve didn't stop")
+	}
+}
+
+// uno's workers at (0, 0) and (1, 1), dos's at (4, 4) and (5, 5)
+func setupBoard() board.IBoard {
+	b := board.IBoard(board.BaseBoard())
+	b, _ = b.PlaceWorker(board.Pos{X: 0, Y: 0}, "uno")
+	b, _ = b.PlaceWorker(board.Pos{X: 4, Y: 4}, "dos")
+	b, _ = b.PlaceWorker(board.Pos{X: 1, Y: 1}, "uno")
+	b, _ = b.PlaceWorker(board.Pos{X: 5, Y: 5}, "dos")
+	return b
+}
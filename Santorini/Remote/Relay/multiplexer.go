@@ -0,0 +1,142 @@
+package remote
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	data "github.com/CS4500-F18/dare-rebr/Santorini/Common/JSON"
+	lib "github.com/CS4500-F18/dare-rebr/Santorini/Lib"
+)
+
+// Several Clients playing over a single connection to the server. Each
+// registers and plays on a channel of its own, its messages tagged with its
+// player ID, just as it would over a connection to itself. The Clients' own
+// TLS, transcript, lobby and retry settings are not used: the Multiplexer's
+// are, for the connection they share
+type Multiplexer struct {
+	clients []Client
+
+	// The TLS settings to connect with, or nil for plain TCP
+	tls *tls.Config
+
+	// Directory to record a transcript of the connection in, or "" for none
+	transcripts string
+
+	// The lobby every Client joins, or "" for the server's default
+	lobby string
+
+	// How many times to try connecting, and how long to wait after the
+	// first failure
+	attempts int
+	backoff  time.Duration
+}
+
+// Create an unconnected Multiplexer for the given Clients
+func NewMultiplexer(clients ...Client) Multiplexer {
+	return Multiplexer{clients: clients, attempts: 1, backoff: CONNECT_BACKOFF}
+}
+
+// A copy of this Multiplexer that connects over TLS with the given settings
+func (m Multiplexer) WithTLS(config *tls.Config) Multiplexer {
+	m.tls = config
+	return m
+}
+
+// A copy of this Multiplexer that records a transcript of every message over
+// the connection to a file in the given directory
+func (m Multiplexer) WithTranscripts(dir string) Multiplexer {
+	m.transcripts = dir
+	return m
+}
+
+// A copy of this Multiplexer whose Clients all join the named lobby, on a
+// server hosting several
+func (m Multiplexer) WithLobby(lobby string) Multiplexer {
+	m.lobby = lobby
+	return m
+}
+
+// A copy of this Multiplexer that tries to connect the given number of times,
+// backing off like a Client. A server that rejects us isn't tried again
+func (m Multiplexer) WithRetry(attempts int, backoff time.Duration) Multiplexer {
+	m.attempts, m.backoff = attempts, backoff
+	return m
+}
+
+// Connect to the server, then register every Client over the connection, in
+// order. Returns once the server has accepted them all. Clients can't resume
+// over a multiplexed connection, so if it drops, they all stop
+func (m Multiplexer) Connect(host string, port int) (*Multiplexed, error) {
+	address := net.JoinHostPort(host, strconv.Itoa(port))
+	var mux *lib.Mux
+	err := retry(m.attempts, m.backoff, func() error {
+		var err error
+		mux, err = m.dial(address)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	multiplexed := &Multiplexed{mux: mux, connections: make([]*Connection, 0)}
+	for _, client := range m.clients {
+		channel, err := mux.Open()
+		if err == nil {
+			var connection *Connection
+			if connection, err = client.Register(channel, ""); err == nil {
+				multiplexed.connections = append(multiplexed.connections, connection)
+				continue
+			}
+		}
+		mux.Close()
+		return nil, fmt.Errorf("Failed to register %s: %v", client.name, err)
+	}
+	return multiplexed, nil
+}
+
+// Connect to the server and ask it to multiplex the connection
+func (m Multiplexer) dial(address string) (*lib.Mux, error) {
+	conn, err := lib.Dial(address, m.tls, 0)
+	if err != nil {
+		return nil, err
+	}
+	if m.transcripts != "" {
+		// Better to play unrecorded than not at all
+		conn, _ = lib.RecordConnTo(conn, m.transcripts, data.MULTIPLEX_ROLE+"-"+address)
+	}
+
+	session := lib.NewSession(conn)
+	reg := data.Registration{
+		Role:   data.MULTIPLEX_ROLE,
+		Tagged: true,
+		Hello:  data.NewHello(data.MULTIPLEX_ROLE, ""),
+	}
+	reg.Hello.Lobby = m.lobby
+	if err := session.Send(reg); err == nil {
+		_, err = awaitWelcome(session)
+	}
+	if err != nil {
+		session.Close()
+		return nil, err
+	}
+	return lib.NewMux(session, conn.RemoteAddr()), nil
+}
+
+// Clients registered over one connection, each until the tournament is over
+type Multiplexed struct {
+	mux         *lib.Mux
+	connections []*Connection
+}
+
+// The Connection of each Client, in the order they were given
+func (m *Multiplexed) Connections() []*Connection {
+	return m.connections
+}
+
+// Hang up the connection, and with it every Client still playing
+func (m *Multiplexed) Close() error {
+	return m.mux.Close()
+}
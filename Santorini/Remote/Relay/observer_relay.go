@@ -0,0 +1,79 @@
+package remote
+
+import (
+	"crypto/tls"
+	"net"
+	"strconv"
+
+	data "github.com/CS4500-F18/dare-rebr/Santorini/Common/JSON"
+	lib "github.com/CS4500-F18/dare-rebr/Santorini/Lib"
+	obs "github.com/CS4500-F18/dare-rebr/Santorini/Observer"
+)
+
+/*
+  The Observer Relay is the client-side TCP component for an Observer: it
+  registers with the server as an observer, then passes every update it
+  receives on to the wrapped IObserver
+*/
+
+type ObserverRelay struct {
+	observer obs.IObserver
+
+	// The TLS settings to connect with, or nil for plain TCP
+	tls *tls.Config
+}
+
+//create an unconnected ObserverRelay given an observer.
+func NewObserverRelay(o obs.IObserver) ObserverRelay {
+	return ObserverRelay{observer: o}
+}
+
+//a copy of this ObserverRelay that connects over TLS with the given settings
+func (r ObserverRelay) WithTLS(config *tls.Config) ObserverRelay {
+	r.tls = config
+	return r
+}
+
+//attempts to connect to the IP and port, returns an error if the connection cannot be established.
+func (r ObserverRelay) Connect(host string, port int, done chan bool) error {
+	address := net.JoinHostPort(host, strconv.Itoa(port))
+	conn, err := lib.Dial(address, r.tls, 0)
+	if err != nil {
+		return err
+	}
+
+	encoder, _ := lib.JSONStreams(conn)
+	registration := data.Registration{Role: data.OBSERVER_ROLE, Name: r.observer.Name()}
+	if err := encoder.Encode(registration); err != nil {
+		conn.Close()
+		return err
+	}
+
+	go r.Listen(conn, done)
+	return nil
+}
+
+//pass each update from the server to the observer, until the server hangs up
+func (r ObserverRelay) Listen(conn net.Conn, done chan bool) {
+	_, decoder := lib.JSONStreams(conn)
+	defer conn.Close()
+
+	for {
+		var update data.Observation
+		if err := decoder.Decode(&update); err != nil {
+			done <- true
+			return
+		}
+
+		switch update.Kind {
+		case data.OBSERVE_BOARD:
+			r.observer.ReceiveBoard(update.Board)
+		case data.OBSERVE_TURN:
+			r.observer.ReceiveTurn(update.Turn)
+		case data.OBSERVE_WINNING_MOVE:
+			r.observer.ReceiveWinningMove(update.Move)
+		case data.OBSERVE_ENDGAME:
+			r.observer.ReceiveEndgame(update.Result)
+		}
+	}
+}
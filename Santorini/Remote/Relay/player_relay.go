@@ -1,13 +1,15 @@
 package remote
 
 import (
+	"crypto/tls"
 	"encoding/json"
 	"net"
-	"strconv"
+	"time"
 
 	board "github.com/CS4500-F18/dare-rebr/Santorini/Common/Board"
 	data "github.com/CS4500-F18/dare-rebr/Santorini/Common/JSON"
 	iplayer "github.com/CS4500-F18/dare-rebr/Santorini/Common/Player"
+	result "github.com/CS4500-F18/dare-rebr/Santorini/Common/Tournament"
 	lib "github.com/CS4500-F18/dare-rebr/Santorini/Lib"
 )
 
@@ -24,96 +26,120 @@ import (
 
 type PlayerRelay struct {
 	player iplayer.IPlayer
+
+	// How to connect and register, without the player's name and handlers
+	client Client
 }
 
 type IRelay interface {
 	Connect(host string, port int, err chan bool) error
-	Register(*json.Encoder) error
-	SendPlacement(*json.Encoder, board.IBoard) error
-	SendTurn(*json.Encoder, board.IBoard) error
+	Register(*lib.Session) (data.Welcome, error)
+	SendPlacement(*lib.Session, board.IBoard) error
+	SendTurn(*lib.Session, board.IBoard) error
 	ListenAndRespond(net.Conn, chan bool)
 }
 
 //create an unconnected PlayerRelay given a player.
 func NewPlayerRelay(p iplayer.IPlayer) PlayerRelay {
-	return PlayerRelay{player: p}
+	return PlayerRelay{player: p, client: NewClient("", Handlers{})}
+}
+
+//create an unconnected PlayerRelay given a player, that speaks the
+//untagged protocol for servers that predate Envelopes
+func NewLegacyPlayerRelay(p iplayer.IPlayer) PlayerRelay {
+	return PlayerRelay{player: p, client: NewClient("", Handlers{}).WithLegacy()}
+}
+
+//a copy of this PlayerRelay that authenticates with the given Auth when it
+//registers (only over the tagged protocol)
+func (r PlayerRelay) WithAuth(auth data.Auth) PlayerRelay {
+	r.client = r.client.WithAuth(auth)
+	return r
+}
+
+//a copy of this PlayerRelay that connects over TLS with the given settings
+func (r PlayerRelay) WithTLS(config *tls.Config) PlayerRelay {
+	r.client = r.client.WithTLS(config)
+	return r
+}
+
+//a copy of this PlayerRelay that records a transcript of every message over
+//each connection to its own file in the given directory
+func (r PlayerRelay) WithTranscripts(dir string) PlayerRelay {
+	r.client = r.client.WithTranscripts(dir)
+	return r
+}
+
+//a copy of this PlayerRelay that joins the named lobby, on a server hosting
+//several (only over the tagged protocol)
+func (r PlayerRelay) WithLobby(lobby string) PlayerRelay {
+	r.client = r.client.WithLobby(lobby)
+	return r
 }
 
-//attempts to connect to the IP and port, returns an error if the connection cannot be established.
+//a copy of this PlayerRelay that tries to connect the given number of times,
+//backing off between attempts (see Client.WithRetry)
+func (r PlayerRelay) WithRetry(attempts int, backoff time.Duration) PlayerRelay {
+	r.client = r.client.WithRetry(attempts, backoff)
+	return r
+}
+
+//the Client that plays this relay's player, under its current name
+func (r PlayerRelay) Client() Client {
+	c := r.client
+	c.name, c.handlers = r.player.Name(), PlayerHandlers(r.player)
+	return c
+}
+
+//attempts to connect to the IP and port and register, returns an error if the
+//connection cannot be established or the server rejects this player. Once
+//registered, the player plays in the background until the tournament is
+//over, or the server goes away, then done is sent true
 func (r PlayerRelay) Connect(host string, port int, done chan bool) error {
-	address := net.JoinHostPort(host, strconv.Itoa(port))
-	conn, err := net.Dial("tcp", address)
+	conn, err := r.Client().Connect(host, port)
 	if err != nil {
 		return err
-	} else {
-		go r.ListenAndRespond(conn, done)
-		return nil
 	}
+	go func() {
+		conn.Serve()
+		done <- true
+	}()
+	return nil
 }
 
-//register this remote player with the server
-func (r PlayerRelay) Register(encoder *json.Encoder) error {
-	if err := encoder.Encode(r.player.Name()); err != nil {
-		return err
-	} else {
-		return nil
-	}
+//register this remote player with the server, returning what the server agreed
+//to. Speaking the tagged protocol, this offers a Hello and waits for the
+//server's Welcome; a legacy server agrees to everything it has without asking
+func (r PlayerRelay) Register(session *lib.Session) (data.Welcome, error) {
+	return r.Client().over(session).register("")
 }
 
 //sends a placement action using a the strategy of the playerRelay's wrapped IPayer
-func (r PlayerRelay) SendPlacement(encoder *json.Encoder, b board.IBoard) error {
-	placement := r.player.PlaceWorker(b)
-	if err := encoder.Encode(placement); err != nil {
-		return err
-	} else {
-		return nil
-	}
+func (r PlayerRelay) SendPlacement(session *lib.Session, b board.IBoard) error {
+	return session.Send(r.player.PlaceWorker(b))
 }
 
 // sends a turn action over TCP using the strategy of the playerRelay's wrapped IPayer
-func (r PlayerRelay) SendTurn(encoder *json.Encoder, b board.IBoard) error {
-	turn := r.player.NextTurn(b)
-
-	turnJSON := data.MoveBuildFromTurn(r.player.Name(), b, turn)
-	if err := encoder.Encode(turnJSON); err != nil {
-		return err
-	} else {
-		return nil
-	}
+// A turn without a worker of ours gives up, and a turn without a build is a lone move
+func (r PlayerRelay) SendTurn(session *lib.Session, b board.IBoard) error {
+	return session.Send(r.Client().over(session).untaggedTurn(b))
 }
 
-//switch over datatype based on what response we get.
-/*Can get:
-  - String  (this player's new name)
-  - Placements (Worker placements on the board to use in placement)
-  - Board (Board to enact a Turn on)
-  - Tournament results (Game's over, we're done here folks)
-*/
+//register over a connection the server is already on, then answer its
+//requests until the tournament is over or the connection drops, which we
+//can't resume without knowing where it was dialed. Sends done true either way
 func (r PlayerRelay) ListenAndRespond(conn net.Conn, done chan bool) {
-	encoder, decoder := lib.JSONStreams(conn)
-	defer conn.Close()
-	r.Register(encoder)
-
-	for {
-		if !decoder.More() {
-			continue
-		} else { // We have something to work with
-			buf, _ := r.GetData(decoder)
-
-			if err := r.TryRename(buf); err == nil {
-				continue
-			} else if err := r.TryOpponent(buf); err == nil {
-				continue
-			} else if err := r.TryPlacement(encoder, buf); err == nil {
-				continue
-			} else if err := r.TryBoard(encoder, buf); err == nil {
-				continue
-			} else if err := r.TryResult(buf); err == nil {
-				done <- true
-				return
-			}
-		}
+	if connection, err := r.Client().Register(conn, ""); err == nil {
+		connection.Serve()
 	}
+	done <- true
+}
+
+//act on a single Envelope from the server, replying if it is a request.
+//Returns whether it was the tournament results, which end the connection
+func (r PlayerRelay) Dispatch(session *lib.Session, env data.Envelope) (bool, error) {
+	_, finished, err := r.Client().over(session).dispatch(session, env)
+	return finished, err
 }
 
 func (r PlayerRelay) GetData(decoder *json.Decoder) ([]byte, error) {
@@ -123,6 +149,12 @@ func (r PlayerRelay) GetData(decoder *json.Decoder) ([]byte, error) {
 	return json.Marshal(obj)
 }
 
+//tries to marshal the given bytes data into a lifecycle notification,
+//if successful, pass the notification on to this player.
+func (r PlayerRelay) TryLifecycle(buf []byte) error {
+	return r.Client().over(nil).lifecycle(buf)
+}
+
 //tries to marshal the given bytes data into a name request,
 //if successful, set name for this player.
 func (r PlayerRelay) TryRename(buf []byte) error {
@@ -149,17 +181,12 @@ func (r PlayerRelay) TryOpponent(buf []byte) error {
 
 //tries to marshal the given bytes data into a list of worker placements,
 //if successful, send the next placement for this player.
-func (r PlayerRelay) TryPlacement(encoder *json.Encoder, buf []byte) error {
+func (r PlayerRelay) TryPlacement(session *lib.Session, buf []byte) error {
 	// Worker placements
 	var workers []board.Worker
 	err := json.Unmarshal(buf, &workers)
 	if err == nil {
-		interfaces := make([]board.IWorker, 0)
-		for _, worker := range workers {
-			interfaces = append(interfaces, board.IWorker(worker))
-		}
-		withWorkers := board.BoardWithWorkers(interfaces)
-		err = r.SendPlacement(encoder, withWorkers)
+		err = r.SendPlacement(session, boardWithWorkers(workers))
 		if err != nil {
 			return err
 		}
@@ -169,12 +196,12 @@ func (r PlayerRelay) TryPlacement(encoder *json.Encoder, buf []byte) error {
 
 //tries to marshal the given bytes data into a board data strcture,
 //if that works, send the next player turn.
-func (r PlayerRelay) TryBoard(encoder *json.Encoder, buf []byte) error {
+func (r PlayerRelay) TryBoard(session *lib.Session, buf []byte) error {
 	// Board to enact a Turn on
 	b := board.BaseBoard()
 	err := json.Unmarshal(buf, &b)
 	if err == nil {
-		err = r.SendTurn(encoder, b)
+		err = r.SendTurn(session, b)
 		if err != nil {
 			return err
 		}
@@ -182,8 +209,36 @@ func (r PlayerRelay) TryBoard(encoder *json.Encoder, buf []byte) error {
 	return err
 }
 
+//tries to marshal the given bytes data into the tournament results,
+//if successful, pass the results on to this player.
 func (r PlayerRelay) TryResult(buf []byte) error {
-	var results []interface{}
+	var results [][]string
 	err := json.Unmarshal(buf, &results)
+	if err == nil {
+		r.player.ReceiveTournamentResults(matchResults(results))
+	}
 	return err
 }
+
+//a board with only the given workers placed on it
+func boardWithWorkers(workers []board.Worker) board.IBoard {
+	interfaces := make([]board.IWorker, 0)
+	for _, worker := range workers {
+		interfaces = append(interfaces, board.IWorker(worker))
+	}
+	return board.BoardWithWorkers(interfaces)
+}
+
+//convert the results sent by the server into match results
+//(a "winner" and "loser" name, with an optional "irregular" marker)
+func matchResults(results [][]string) []result.MatchResult {
+	matches := make([]result.MatchResult, 0)
+	for _, r := range results {
+		if len(r) < 2 {
+			continue
+		}
+		irregular := len(r) > 2 && r[2] == "irregular"
+		matches = append(matches, result.NewMatchResult(r[0], r[1], irregular, nil))
+	}
+	return matches
+}
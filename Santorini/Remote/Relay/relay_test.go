@@ -41,7 +41,7 @@ func TestConn_TryPlacement(t *testing.T) {
 	// create a relay with a player
 	var b_buf []byte
 	rw := bytes.NewBuffer(b_buf)
-	enc, _ := lib.JSONStreams(rw)
+	session := lib.NewSession(rw)
 
 	//make player and player relay
 	player := client.ValidPlayer("player1")
@@ -59,7 +59,7 @@ func TestConn_TryPlacement(t *testing.T) {
 	data, err := relay.GetData(dec)
 
 	// call TryPlacement with an encoder you can read from
-	e := relay.TryPlacement(enc, data)
+	e := relay.TryPlacement(session, data)
 	if e != nil {
 		t.Errorf("try placement failed")
 	}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	config "github.com/CS4500-F18/dare-rebr/Santorini/Admin/Tournament/Config"
+	iplayer "github.com/CS4500-F18/dare-rebr/Santorini/Common/Player"
+	lib "github.com/CS4500-F18/dare-rebr/Santorini/Lib"
+	client "github.com/CS4500-F18/dare-rebr/Santorini/Player/Client"
+	replay "github.com/CS4500-F18/dare-rebr/Santorini/Remote/Replay"
+)
+
+// How long to wait for each message before giving up
+const TIMEOUT = 10 * time.Second
+
+const USAGE = `usage:
+  xreplay relay TRANSCRIPT [good|breaker|infinite]
+      play a transcript the server recorded back to a player (good by default)
+  xreplay server TRANSCRIPT HOST:PORT
+      play a transcript a client recorded back to a server`
+
+func main() {
+	if len(os.Args) < 3 {
+		fail(USAGE)
+	}
+
+	entries, err := lib.LoadTranscript(os.Args[2])
+	if err != nil {
+		fail(err.Error())
+	}
+
+	switch {
+	case os.Args[1] == "relay" && len(os.Args) <= 4:
+		kind := config.VALID
+		if len(os.Args) == 4 {
+			kind = os.Args[3]
+		}
+		reg, err := replay.Registration(entries)
+		if err != nil {
+			fail(err.Error())
+		}
+		player := newPlayer(kind, reg.Name)
+		if player == nil {
+			fail(USAGE)
+		}
+		err = replay.IntoRelay(entries, player, TIMEOUT)
+		report(len(entries), err)
+
+	case os.Args[1] == "server" && len(os.Args) == 4:
+		report(len(entries), replay.IntoServer(entries, os.Args[3], TIMEOUT))
+
+	default:
+		fail(USAGE)
+	}
+}
+
+// A player of the given kind, as in a client config, or nil
+func newPlayer(kind, name string) iplayer.IPlayer {
+	switch kind {
+	case config.VALID:
+		return client.ValidPlayer(name)
+	case config.BROKEN:
+		return client.BrokenPlayer(name)
+	case config.INFINITE:
+		return client.InfinitePlacementPlayer(name)
+	}
+	return nil
+}
+
+func report(entries int, err error) {
+	if err != nil {
+		fail(err.Error())
+	}
+	fmt.Printf("Replayed all %d entries as recorded\n", entries)
+}
+
+func fail(message string) {
+	fmt.Fprintln(os.Stderr, message)
+	os.Exit(1)
+}
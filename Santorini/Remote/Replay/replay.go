@@ -0,0 +1,74 @@
+package replay
+
+import (
+	"errors"
+	"net"
+	"time"
+
+	data "github.com/CS4500-F18/dare-rebr/Santorini/Common/JSON"
+	iplayer "github.com/CS4500-F18/dare-rebr/Santorini/Common/Player"
+	lib "github.com/CS4500-F18/dare-rebr/Santorini/Lib"
+	relay "github.com/CS4500-F18/dare-rebr/Santorini/Remote/Relay"
+)
+
+/*
+  Replay reproduces a failure recorded in a transcript offline:
+    - a transcript the server recorded is played back to a PlayerRelay, as if
+      from the server, to see what the player behind the relay does now
+    - a transcript a client recorded is played back to a server, as if from
+      the client, to see what its ProxyPlayer makes of it
+  Either way, the first message that differs from the recording is returned as
+  a lib.Divergence
+*/
+
+// Session tokens are new every time
+var IGNORED = []string{"token"}
+
+// The registration a server recorded its client sending first
+func Registration(entries []lib.TranscriptEntry) (data.Registration, error) {
+	var reg data.Registration
+	for _, entry := range entries {
+		if entry.Direction == lib.RECEIVED && entry.Message != nil {
+			err := reg.UnmarshalJSON(entry.Message)
+			return reg, err
+		}
+	}
+	return reg, errors.New("The transcript has no registration")
+}
+
+// Play a transcript the server recorded back to the given player behind a
+// relay, speaking the protocol and authenticating the way the recorded client
+// did, waiting at most the timeout for each of its messages
+func IntoRelay(entries []lib.TranscriptEntry, player iplayer.IPlayer, timeout time.Duration) error {
+	reg, err := Registration(entries)
+	if err != nil {
+		return err
+	}
+
+	r := relay.NewLegacyPlayerRelay(player)
+	if reg.Tagged {
+		r = relay.NewPlayerRelay(player)
+		if reg.Hello.Auth != nil {
+			r = r.WithAuth(*reg.Hello.Auth)
+		}
+	}
+
+	server, client := net.Pipe()
+	defer server.Close()
+	go r.ListenAndRespond(client, make(chan bool, 1))
+
+	return lib.NewReplayer(entries).WithTimeout(timeout).Ignoring(IGNORED...).Replay(server)
+}
+
+// Play a transcript a client recorded back to the server at the given
+// address, waiting at most the timeout for each of its messages. Waiting
+// room updates depend on when clients join, so they aren't compared
+func IntoServer(entries []lib.TranscriptEntry, address string, timeout time.Duration) error {
+	conn, err := lib.Dial(address, nil, timeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	return lib.NewReplayer(entries).WithTimeout(timeout).Ignoring(IGNORED...).Skipping(data.MSG_WAITING).Replay(conn)
+}
@@ -0,0 +1,86 @@
+package replay
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+
+	board "github.com/CS4500-F18/dare-rebr/Santorini/Common/Board"
+	data "github.com/CS4500-F18/dare-rebr/Santorini/Common/JSON"
+	result "github.com/CS4500-F18/dare-rebr/Santorini/Common/Tournament"
+	lib "github.com/CS4500-F18/dare-rebr/Santorini/Lib"
+	client "github.com/CS4500-F18/dare-rebr/Santorini/Player/Client"
+	proxy "github.com/CS4500-F18/dare-rebr/Santorini/Remote/Player"
+	relay "github.com/CS4500-F18/dare-rebr/Santorini/Remote/Relay"
+)
+
+type buffer struct{ bytes.Buffer }
+
+func (b *buffer) Close() error { return nil }
+
+// Record the server's side of a player placing a worker, taking a turn and
+// being sent the results
+func record(t *testing.T) []lib.TranscriptEntry {
+	server, conn := net.Pipe()
+	done := make(chan bool, 1)
+	go relay.NewPlayerRelay(client.ValidPlayer("fido")).ListenAndRespond(conn, done)
+
+	transcript := &buffer{}
+	session := lib.NewSession(lib.RecordConn(server, transcript))
+	session.SetTimeout(10 * time.Second)
+
+	var reg data.Registration
+	if err := session.Receive(&reg); err != nil {
+		t.Fatal(err)
+	}
+	welcome, err := data.Negotiate(reg.Hello, data.SUPPORTED_FEATURES, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	env, _ := data.NewEnvelope(data.MSG_WELCOME, 0, 0, welcome)
+	session.Send(env)
+
+	player := proxy.NewProxyPlayer(session, reg.Name, welcome)
+	player.SetOpponent("rex")
+	b := board.IBoard(board.BaseBoard())
+	if _, err := player.PlaceWorker(b); err != nil {
+		t.Fatal(err)
+	}
+	b, _ = b.PlaceWorker(board.Pos{X: 0, Y: 0}, "fido")
+	b, _ = b.PlaceWorker(board.Pos{X: 5, Y: 5}, "rex")
+	b, _ = b.PlaceWorker(board.Pos{X: 2, Y: 2}, "fido")
+	b, _ = b.PlaceWorker(board.Pos{X: 5, Y: 0}, "rex")
+	if _, err := player.NextTurn(b); err != nil {
+		t.Fatal(err)
+	}
+	games := []result.MatchResult{result.NewMatchResult("fido", "rex", false, nil)}
+	player.ReceiveTournamentResult(result.TournamentResult{Games: games})
+	<-done
+	session.Close()
+
+	entries, err := lib.ReadTranscript(&transcript.Buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return entries
+}
+
+func TestIntoRelay(t *testing.T) {
+	entries := record(t)
+	if len(entries) != 8 {
+		t.Fatalf("Expected 8 messages recorded, got %d: %+v", len(entries), entries)
+	}
+	if reg, err := Registration(entries); err != nil || reg.Name != "fido" || !reg.Tagged {
+		t.Errorf("Wrong registration %+v: %v", reg, err)
+	}
+
+	if err := IntoRelay(entries, client.ValidPlayer("fido"), 10*time.Second); err != nil {
+		t.Errorf("The same player diverged: %v", err)
+	}
+
+	err := IntoRelay(entries, client.BrokenPlayer("fido"), 10*time.Second)
+	if divergence, ok := err.(lib.Divergence); !ok || divergence.Index != 6 {
+		t.Errorf("Expected a broken player's turn to diverge, got %v", err)
+	}
+}
@@ -0,0 +1,345 @@
+package remote
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+
+	archive "github.com/CS4500-F18/dare-rebr/Santorini/Admin/Archive"
+	tournament "github.com/CS4500-F18/dare-rebr/Santorini/Admin/Tournament"
+)
+
+/*
+  The Admin API lets an operator inspect and control a running server over
+  HTTP, on localhost only. It serves:
+    - GET  /tournament  everything below at once
+    - GET  /players     the players in the tournament (or registered for it so
+                        far), and those kicked out
+    - GET  /standings   every player's wins and losses so far
+    - GET  /games       the series being played, with the latest board
+    - POST /start       stop registration and start with whoever has registered
+    - POST /kick?name=  kick a player out once their series is over
+    - POST /abort       stop the tournament once the series being played is over
+  Everything answers 503 between tournaments. On a server hosting several
+  lobbies, each of these takes ?lobby= to pick one (the first by default), and
+    - GET  /lobbies     every lobby, and how its tournament is going
+  With an archive, it also serves:
+    - GET  /archive/tournaments?n=    the n most recent tournaments (all by default)
+    - GET  /archive/recent?n=         the n most recent series
+    - GET  /archive/games?player=     every game the player played, newest first
+    - GET  /archive/games?series=     every game of the series
+    - GET  /archive/head-to-head?a=&b= how a has done against b
+    - GET  /archive/players?name=     every player's record, or just the named one's
+*/
+
+// What the Admin API needs of a tournament manager
+type tournamentControl interface {
+	Snapshot() tournament.Snapshot
+	Started() bool
+	Kick(name string) error
+	Abort()
+}
+
+// A tournament the server is registering players for or running
+type round struct {
+	tournament tournamentControl
+
+	// Closed to stop registration
+	start     chan bool
+	startOnce sync.Once
+
+	// The players registered so far
+	lock       sync.Mutex
+	registered []string
+}
+
+func newRound(t tournamentControl) *round {
+	return &round{tournament: t, start: make(chan bool), registered: make([]string, 0)}
+}
+
+// Note the players registered so far
+func (r *round) register(players []string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.registered = players
+}
+
+// The tournament's state, with the players registered so far until it starts
+func (r *round) snapshot() tournament.Snapshot {
+	snapshot := r.tournament.Snapshot()
+	if !snapshot.Started {
+		r.lock.Lock()
+		snapshot.Players = r.registered
+		r.lock.Unlock()
+	}
+	return snapshot
+}
+
+// Stop registration, starting with whoever has registered
+func (r *round) startNow() {
+	r.startOnce.Do(func() {
+		close(r.start)
+	})
+}
+
+type Admin struct {
+	listener net.Listener
+
+	//The tournament running in each lobby, by name ("" on a server without
+	//lobbies), and the lobbies' names in the order they were first seen
+	lock    sync.Mutex
+	rounds  map[string]*round
+	lobbies []string
+	archive *archive.Archive
+}
+
+// How a lobby's tournament is going, in brief
+type lobbyStatus struct {
+	Name    string `json:"name"`
+	Running bool   `json:"running"`
+	Started bool   `json:"started"`
+	Players int    `json:"players"`
+	Games   int    `json:"games"`
+}
+
+// Start serving the Admin API on the given port of localhost (0 for any)
+func ListenAdmin(port int) (*Admin, error) {
+	listener, err := net.Listen("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(port)))
+	if err != nil {
+		return nil, err
+	}
+
+	a := &Admin{listener: listener, rounds: make(map[string]*round), lobbies: make([]string, 0)}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/tournament", a.get(func(s tournament.Snapshot) interface{} { return s }))
+	mux.HandleFunc("/players", a.get(func(s tournament.Snapshot) interface{} {
+		return map[string][]string{"players": s.Players, "kicked": s.Kicked}
+	}))
+	mux.HandleFunc("/standings", a.get(func(s tournament.Snapshot) interface{} { return s.Standings }))
+	mux.HandleFunc("/games", a.get(func(s tournament.Snapshot) interface{} { return s.Games }))
+	mux.HandleFunc("/lobbies", a.serveLobbies)
+	mux.HandleFunc("/start", a.post(a.serveStart))
+	mux.HandleFunc("/kick", a.post(a.serveKick))
+	mux.HandleFunc("/abort", a.post(a.serveAbort))
+	mux.HandleFunc("/archive/tournaments", a.query(func(ar *archive.Archive, r *http.Request) (interface{}, int) {
+		return ar.RecentTournaments(count(r)), http.StatusOK
+	}))
+	mux.HandleFunc("/archive/recent", a.query(func(ar *archive.Archive, r *http.Request) (interface{}, int) {
+		return ar.Recent(count(r)), http.StatusOK
+	}))
+	mux.HandleFunc("/archive/games", a.query(serveGames))
+	mux.HandleFunc("/archive/head-to-head", a.query(func(ar *archive.Archive, r *http.Request) (interface{}, int) {
+		first, second := r.FormValue("a"), r.FormValue("b")
+		if first == "" || second == "" {
+			return "Missing player names a and b", http.StatusBadRequest
+		}
+		return ar.HeadToHead(first, second), http.StatusOK
+	}))
+	mux.HandleFunc("/archive/players", a.query(func(ar *archive.Archive, r *http.Request) (interface{}, int) {
+		name := r.FormValue("name")
+		if name == "" {
+			return ar.Players(), http.StatusOK
+		} else if player, ok := ar.Player(name); ok {
+			return player, http.StatusOK
+		}
+		return "No player named " + name, http.StatusNotFound
+	}))
+	go http.Serve(listener, mux)
+
+	return a, nil
+}
+
+// The address the API is served on
+func (a *Admin) Addr() string {
+	return a.listener.Addr().String()
+}
+
+// Stop serving the API
+func (a *Admin) Close() error {
+	return a.listener.Close()
+}
+
+// Answer queries on the given archive from now on
+func (a *Admin) ServeArchive(ar *archive.Archive) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.archive = ar
+}
+
+// Control the given tournament in the named lobby from now on, or none if nil
+func (a *Admin) track(lobby string, r *round) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	if _, ok := a.rounds[lobby]; !ok {
+		a.lobbies = append(a.lobbies, lobby)
+	}
+	a.rounds[lobby] = r
+}
+
+// The tournament in the lobby the request picks, or nil, answering 404 if
+// there is no such lobby, or 503 if its tournament isn't running
+func (a *Admin) round(w http.ResponseWriter, req *http.Request) *round {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	lobby := req.FormValue("lobby")
+	if lobby == "" && len(a.lobbies) > 0 {
+		lobby = a.lobbies[0]
+	}
+	current, ok := a.rounds[lobby]
+	if !ok && len(a.lobbies) > 0 {
+		http.Error(w, "No lobby named "+lobby, http.StatusNotFound)
+	} else if current == nil {
+		http.Error(w, "No tournament is running", http.StatusServiceUnavailable)
+	}
+	return current
+}
+
+func (a *Admin) serveLobbies(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Use GET", http.StatusMethodNotAllowed)
+		return
+	}
+	a.lock.Lock()
+	statuses := make([]lobbyStatus, 0)
+	rounds := make([]*round, 0)
+	for _, name := range a.lobbies {
+		statuses = append(statuses, lobbyStatus{Name: name})
+		rounds = append(rounds, a.rounds[name])
+	}
+	a.lock.Unlock()
+
+	for i, current := range rounds {
+		if current != nil {
+			snapshot := current.snapshot()
+			statuses[i].Running, statuses[i].Started = true, snapshot.Started
+			statuses[i].Players, statuses[i].Games = len(snapshot.Players), len(snapshot.Games)
+		}
+	}
+	buf, err := json.Marshal(statuses)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(buf)
+}
+
+// A handler serving the part of the tournament's Snapshot the view picks
+func (a *Admin) get(view func(tournament.Snapshot) interface{}) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Use GET", http.StatusMethodNotAllowed)
+			return
+		}
+		current := a.round(w, r)
+		if current == nil {
+			return
+		}
+
+		buf, err := json.Marshal(view(current.snapshot()))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(buf)
+	}
+}
+
+// A handler acting on the tournament, on POST only
+func (a *Admin) post(action func(http.ResponseWriter, *http.Request, *round)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Use POST", http.StatusMethodNotAllowed)
+			return
+		}
+		if current := a.round(w, r); current != nil {
+			action(w, r, current)
+		}
+	}
+}
+
+// A handler answering a query on the archive with the query's answer, as
+// JSON, or with an error message for any status but 200
+func (a *Admin) query(answer func(*archive.Archive, *http.Request) (interface{}, int)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Use GET", http.StatusMethodNotAllowed)
+			return
+		}
+		a.lock.Lock()
+		ar := a.archive
+		a.lock.Unlock()
+		if ar == nil {
+			http.Error(w, "No archive is kept", http.StatusNotFound)
+			return
+		}
+
+		body, status := answer(ar, r)
+		if status != http.StatusOK {
+			http.Error(w, body.(string), status)
+			return
+		}
+		buf, err := json.Marshal(body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(buf)
+	}
+}
+
+// The games a player played, or those of a series
+func serveGames(ar *archive.Archive, r *http.Request) (interface{}, int) {
+	if player := r.FormValue("player"); player != "" {
+		return ar.GamesBy(player), http.StatusOK
+	}
+	series, err := strconv.Atoi(r.FormValue("series"))
+	if err != nil {
+		return "Give a player or a series", http.StatusBadRequest
+	}
+	return ar.SeriesGames(series), http.StatusOK
+}
+
+// The n asked for in a query, or 0 for all
+func count(r *http.Request) int {
+	n, _ := strconv.Atoi(r.FormValue("n"))
+	return n
+}
+
+func (a *Admin) serveStart(w http.ResponseWriter, r *http.Request, current *round) {
+	if current.tournament.Started() {
+		http.Error(w, "The tournament has already started", http.StatusConflict)
+		return
+	}
+	current.startNow()
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (a *Admin) serveKick(w http.ResponseWriter, r *http.Request, current *round) {
+	name := r.FormValue("name")
+	if name == "" {
+		http.Error(w, "Missing player name", http.StatusBadRequest)
+		return
+	}
+	if !current.tournament.Started() {
+		http.Error(w, "The tournament hasn't started", http.StatusConflict)
+		return
+	}
+	if err := current.tournament.Kick(name); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (a *Admin) serveAbort(w http.ResponseWriter, r *http.Request, current *round) {
+	current.tournament.Abort()
+	current.startNow()
+	w.WriteHeader(http.StatusAccepted)
+}
@@ -0,0 +1,195 @@
+package remote
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	archive "github.com/CS4500-F18/dare-rebr/Santorini/Admin/Archive"
+	tournament "github.com/CS4500-F18/dare-rebr/Santorini/Admin/Tournament"
+	config "github.com/CS4500-F18/dare-rebr/Santorini/Admin/Tournament/Config"
+	result "github.com/CS4500-F18/dare-rebr/Santorini/Common/Tournament"
+	client "github.com/CS4500-F18/dare-rebr/Santorini/Player/Client"
+)
+
+// A tournament that only remembers what it was asked to do
+type fakeTournament struct {
+	started bool
+	kicked  string
+	aborted bool
+}
+
+func (f *fakeTournament) Snapshot() tournament.Snapshot {
+	return tournament.Snapshot{
+		Started:   f.started,
+		Players:   []string{"fido", "rex"},
+		Standings: []tournament.Standing{{Name: "fido", Wins: 1}, {Name: "rex", Losses: 1}},
+	}
+}
+
+func (f *fakeTournament) Started() bool {
+	return f.started
+}
+
+func (f *fakeTournament) Kick(name string) error {
+	f.kicked = name
+	return nil
+}
+
+func (f *fakeTournament) Abort() {
+	f.aborted = true
+}
+
+func listenAdmin(t *testing.T) *Admin {
+	admin, err := ListenAdmin(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if host, _, _ := net.SplitHostPort(admin.Addr()); host != "127.0.0.1" {
+		t.Errorf("Admin API served on %s, not localhost", host)
+	}
+	return admin
+}
+
+func TestAdmin(t *testing.T) {
+	admin := listenAdmin(t)
+	defer admin.Close()
+	url := "http://" + admin.Addr()
+
+	if resp, err := http.Get(url + "/players"); err != nil || resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 with no tournament, got %+v: %v", resp, err)
+	}
+
+	fake := &fakeTournament{started: true}
+	admin.track("", newRound(fake))
+
+	resp, err := http.Get(url + "/standings")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var standings []tournament.Standing
+	json.NewDecoder(resp.Body).Decode(&standings)
+	resp.Body.Close()
+	if len(standings) != 2 || standings[0].Name != "fido" || standings[0].Wins != 1 {
+		t.Errorf("Wrong standings: %+v", standings)
+	}
+
+	if resp, _ := http.Get(url + "/kick?name=rex"); resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("Kicked with a GET: %v", resp.Status)
+	}
+	if resp, _ := http.Post(url+"/kick?name=rex", "", nil); resp.StatusCode != http.StatusAccepted || fake.kicked != "rex" {
+		t.Errorf("Kick failed: %v", resp.Status)
+	}
+	if resp, _ := http.Post(url+"/start", "", nil); resp.StatusCode != http.StatusConflict {
+		t.Errorf("Started a tournament already under way: %v", resp.Status)
+	}
+	if resp, _ := http.Post(url+"/abort", "", nil); resp.StatusCode != http.StatusAccepted || !fake.aborted {
+		t.Errorf("Abort failed: %v", resp.Status)
+	}
+}
+
+// Starting now plays whoever has registered, even below the minimum
+func TestAdmin_StartNow(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	listener := config.NewListener(l)
+	defer listener.Close()
+	admin := listenAdmin(t)
+	defer admin.Close()
+
+	c := config.NewRemoteConfig(5, 0, 60, 5000).WithListener(listener)
+	served := make(chan []result.TournamentResult, 1)
+	go func() {
+		served <- NewServer().WithAdmin(admin).serve(c, false, make(chan os.Signal))
+	}()
+
+	join(t, l, client.ValidPlayer("fido"))
+	join(t, l, client.BrokenPlayer("rex"))
+
+	// Wait for both to reach the waiting room
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		var players map[string][]string
+		if resp, err := http.Get("http://" + admin.Addr() + "/players"); err == nil {
+			json.NewDecoder(resp.Body).Decode(&players)
+			resp.Body.Close()
+		}
+		if len(players["players"]) == 2 {
+			break
+		} else if time.Now().After(deadline) {
+			t.Fatalf("Players never registered: %v", players)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if resp, err := http.Post("http://"+admin.Addr()+"/start", "", nil); err != nil || resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("Couldn't start the tournament: %+v, %v", resp, err)
+	}
+
+	select {
+	case results := <-served:
+		if len(results) != 1 || results[0].Aborted || len(results[0].Kicked) != 1 {
+			t.Errorf("Wrong results: %+v", results)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("Tournament never finished")
+	}
+}
+
+// The archive is queried even between tournaments, and only if there is one
+func TestAdmin_Archive(t *testing.T) {
+	admin := listenAdmin(t)
+	defer admin.Close()
+	url := "http://" + admin.Addr()
+
+	if resp, err := http.Get(url + "/archive/recent"); err != nil || resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected 404 with no archive, got %+v: %v", resp, err)
+	}
+
+	dir, err := ioutil.TempDir("", "archive")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	ar, err := archive.Open(filepath.Join(dir, "archive.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ar.Close()
+	id, _ := ar.BeginTournament([]string{"fido", "rex"})
+	ar.RecordSeries(archive.Series{Tournament: id, Players: [2]string{"fido", "rex"}, Winner: "fido", Loser: "rex"},
+		[]archive.Game{{Winner: "fido", Loser: "rex"}})
+	admin.ServeArchive(ar)
+
+	resp, err := http.Get(url + "/archive/games?player=rex")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var games []archive.Game
+	json.NewDecoder(resp.Body).Decode(&games)
+	resp.Body.Close()
+	if len(games) != 1 || games[0].Winner != "fido" || games[0].Players[1] != "rex" {
+		t.Errorf("Wrong games: %+v", games)
+	}
+
+	resp, err = http.Get(url + "/archive/head-to-head?a=rex&b=fido")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var record archive.HeadToHead
+	json.NewDecoder(resp.Body).Decode(&record)
+	resp.Body.Close()
+	if record.Series != 1 || record.SeriesLosses != 1 {
+		t.Errorf("Wrong head to head: %+v", record)
+	}
+
+	if resp, _ := http.Get(url + "/archive/games"); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected 400 for games of no one, got %v", resp.Status)
+	}
+}
@@ -1,15 +1,28 @@
 package remote
 
 import (
+	"crypto/tls"
 	"errors"
 	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
+	archive "github.com/CS4500-F18/dare-rebr/Santorini/Admin/Archive"
 	sandbox "github.com/CS4500-F18/dare-rebr/Santorini/Admin/Sandbox"
 	tournament "github.com/CS4500-F18/dare-rebr/Santorini/Admin/Tournament"
 	config "github.com/CS4500-F18/dare-rebr/Santorini/Admin/Tournament/Config"
 	result "github.com/CS4500-F18/dare-rebr/Santorini/Common/Tournament"
+	lib "github.com/CS4500-F18/dare-rebr/Santorini/Lib"
+	proxy "github.com/CS4500-F18/dare-rebr/Santorini/Remote/Player"
 )
 
+// How many games each series is, unless a lobby says otherwise
+const GAMES_DEFAULT = 3
+
 // An integer (0 or 1) representing a boolean
 type IntBool bool
 
@@ -39,32 +52,492 @@ type ServerConfig struct {
 
 	//true if repeat, false if no repeat
 	Repeat int `json:"repeat"`
+
+	//local players to play in every tournament alongside the remote clients,
+	//e.g. [["good", "housebot", ""]] (optional)
+	HousePlayers []config.StaticPlayer `json:"house players"`
+
+	//local players to top every tournament up with, in order, when too few
+	//remote clients arrive to reach "min players" (optional)
+	ReservePlayers []config.StaticPlayer `json:"reserve players"`
+
+	//1 to run a ladder players join and leave at will, pairing them as they
+	//become idle, instead of tournaments (optional)
+	Ladder IntBool `json:"ladder"`
+
+	//file to log every message to and from clients in, or "-" for STDERR (optional)
+	Log string `json:"log"`
+
+	//directory to record a transcript of each connection in, to replay when debugging (optional)
+	Transcripts string `json:"transcripts"`
+
+	//most players to accept, starting as soon as they have all joined, 0 for no limit (optional)
+	MaxPlayers int `json:"max players"`
+
+	//seconds after which registration closes, starting even without the minimum players (optional)
+	Deadline int `json:"registration deadline"`
+
+	//seconds a dropped player has to reconnect before forfeiting, 0 to forfeit at once (optional)
+	ReconnectGrace int `json:"reconnect grace"`
+
+	//how many times a player may drop before forfeiting, 0 for no limit (optional)
+	MaxDrops int `json:"max drops"`
+
+	//credentials file of the names only authenticated players may use (optional)
+	Credentials string `json:"credentials"`
+
+	//certificate and key files to accept only TLS connections with, and a CA
+	//file to require and verify client certificates against (optional)
+	TLS *lib.TLSFiles `json:"tls"`
+
+	//file to append each completed tournament's results to, or "-" to log them to STDERR (optional)
+	Results string `json:"results"`
+
+	//port on localhost to serve the admin API on, 0 for none (optional)
+	AdminPort int `json:"admin port"`
+
+	//file to checkpoint each tournament to after every series, resuming the one
+	//checkpointed there on starting, if the server died before finishing it (optional)
+	Checkpoint string `json:"checkpoint"`
+
+	//file to archive every tournament, series and game in, moves and all (optional)
+	Archive string `json:"archive"`
+
+	//port on localhost to serve Prometheus metrics on at /metrics, 0 for none (optional)
+	MetricsPort int `json:"metrics port"`
+
+	//lobbies to host at once on the port, each with its own tournaments; with
+	//none, the server hosts one tournament at a time with the settings above (optional)
+	Lobbies []LobbyConfig `json:"lobbies"`
 }
 
-// An empty structure representing a Server
-type server struct{}
+// Configuration JSON for one lobby of a server hosting several. Settings left
+// out (or 0) are the server's
+type LobbyConfig struct {
+	//the name clients ask for to join the lobby; the first lobby is the default
+	Name string `json:"name"`
+
+	//games in each series, 3 if left out
+	Games int `json:"games"`
+
+	//milliseconds each player has to reply to each request
+	Timeout int `json:"timeout"`
+
+	MinPlayers int     `json:"min players"`
+	WaitingFor int     `json:"waiting for"`
+	MaxPlayers int     `json:"max players"`
+	Deadline   int     `json:"registration deadline"`
+	Repeat     int     `json:"repeat"`
+	Ladder     IntBool `json:"ladder"`
+}
+
+// The lobby's settings, with the server's in place of any left out
+func (l LobbyConfig) withDefaults(cfg ServerConfig) LobbyConfig {
+	fill := func(setting *int, fallback int) {
+		if *setting == 0 {
+			*setting = fallback
+		}
+	}
+	fill(&l.Games, GAMES_DEFAULT)
+	fill(&l.Timeout, sandbox.TIMEOUT_DEFAULT)
+	fill(&l.MinPlayers, cfg.MinPlayers)
+	fill(&l.WaitingFor, cfg.WaitingFor)
+	fill(&l.MaxPlayers, cfg.MaxPlayers)
+	fill(&l.Deadline, cfg.Deadline)
+	fill(&l.Repeat, cfg.Repeat)
+	l.Ladder = l.Ladder || cfg.Ladder
+	return l
+}
+
+// A Server, where it hands the results of its tournaments, the admin API
+// controlling them, and where it checkpoints them, if anywhere. On a server
+// hosting several lobbies, each runs its own copy, naming its lobby
+type server struct {
+	sinks      []ResultSink
+	admin      *Admin
+	checkpoint string
+	archive    *archive.Archive
+	lobby      string
+	games      int
+
+	// Local players in every tournament, and those to top it up with
+	house, reserve []config.StaticPlayer
+}
 
 // Create a new generic server
 func NewServer() server {
 	return server{}
 }
 
+// A copy of this server that also hands each completed tournament's results
+// to the given sink
+func (serv server) WithSink(sink ResultSink) server {
+	serv.sinks = append(append(make([]ResultSink, 0), serv.sinks...), sink)
+	return serv
+}
+
+// A copy of this server that checkpoints each tournament to the file at the
+// given path, and resumes the one checkpointed there when it starts
+func (serv server) WithCheckpoint(path string) server {
+	serv.checkpoint = path
+	return serv
+}
+
+// A copy of this server that archives every tournament, series and game
+func (serv server) WithArchive(ar *archive.Archive) server {
+	serv.archive = ar
+	return serv
+}
+
+// A copy of this server running the named lobby's tournaments
+func (serv server) WithLobby(name string) server {
+	serv.lobby = name
+	return serv
+}
+
+// A copy of this server playing the given number of games each series
+func (serv server) WithGames(games int) server {
+	serv.games = games
+	return serv
+}
+
+// How many games each series is
+func (serv server) gamesPerSeries() int {
+	if serv.games <= 0 {
+		return GAMES_DEFAULT
+	}
+	return serv.games
+}
+
+// A copy of this server playing the given local players in every tournament,
+// alongside the remote clients, and topping each up with the reserve players
+// when too few remote clients arrive
+func (serv server) WithHouse(house, reserve []config.StaticPlayer) server {
+	serv.house, serv.reserve = house, reserve
+	return serv
+}
+
+// The tournament of the remote clients the config registers, with the house
+// players alongside and reserves topping it up to the config's minimum. With
+// house players, fewer remote clients are needed, and with reserves just one
+func (serv server) tournamentConfig(c config.RemoteConfig) config.TournamentConfig {
+	if len(serv.house) == 0 && len(serv.reserve) == 0 {
+		return c
+	}
+	min := c.MinPlayers()
+	remoteMin := min - len(serv.house)
+	if len(serv.reserve) > 0 || remoteMin < 1 {
+		remoteMin = 1
+	}
+	return config.NewHybridConfig(c.WithMinPlayers(remoteMin), config.StaticConfig{Players: serv.house}).
+		WithReserve(config.StaticConfig{Players: serv.reserve}, min)
+}
+
+// A copy of this server whose tournaments the given admin API controls
+func (serv server) WithAdmin(admin *Admin) server {
+	serv.admin = admin
+	return serv
+}
+
 // Starts a new server from the given configuration, then returns a slice of
-// tournament results from the tournaments run
+// tournament results from the tournaments run: just one, unless it repeats
+// until it is sent SIGINT or SIGTERM. Panics if the credentials file can't
+// be loaded, rather than let anyone claim reserved names, if the TLS files
+// can't be, rather than fall back to plain TCP, if the port, results file,
+// archive, admin port or metrics port can't be opened, or if two lobbies
+// have the same name (or none)
 func (serv server) Start(cfg ServerConfig) []result.TournamentResult {
-	remoteConfig := config.NewRemoteConfig(cfg.MinPlayers, cfg.Port, cfg.WaitingFor, sandbox.TIMEOUT_DEFAULT)
+	remoteConfig := config.NewRemoteConfig(cfg.MinPlayers, cfg.Port, cfg.WaitingFor, sandbox.TIMEOUT_DEFAULT).
+		WithMaxPlayers(cfg.MaxPlayers).
+		WithDeadline(cfg.Deadline).
+		WithTranscripts(cfg.Transcripts)
+	if logger := openLog(cfg.Log); logger != nil {
+		remoteConfig = remoteConfig.WithLogger(logger)
+	}
+	if cfg.Credentials != "" {
+		credentials, err := config.LoadCredentials(cfg.Credentials)
+		if err != nil {
+			panic(err)
+		}
+		remoteConfig = remoteConfig.WithCredentials(credentials)
+	}
+	var tlsConfig *tls.Config
+	if cfg.TLS != nil {
+		var err error
+		if tlsConfig, err = cfg.TLS.ServerConfig(); err != nil {
+			panic(err)
+		}
+		remoteConfig = remoteConfig.WithTLS(tlsConfig)
+	}
+	if cfg.Results == "-" {
+		serv = serv.WithSink(NewLogSink(log.New(os.Stderr, "", log.LstdFlags)))
+	} else if cfg.Results != "" {
+		sink, err := NewFileSink(cfg.Results)
+		if err != nil {
+			panic(err)
+		}
+		defer sink.(io.Closer).Close()
+		serv = serv.WithSink(sink)
+	}
+	if cfg.Archive != "" {
+		ar, err := archive.Open(cfg.Archive)
+		if err != nil {
+			panic(err)
+		}
+		defer ar.Close()
+		serv = serv.WithArchive(ar)
+	}
+	if cfg.AdminPort > 0 {
+		admin, err := ListenAdmin(cfg.AdminPort)
+		if err != nil {
+			panic(err)
+		}
+		defer admin.Close()
+		if serv.archive != nil {
+			admin.ServeArchive(serv.archive)
+		}
+		serv = serv.WithAdmin(admin)
+	}
+	if cfg.Checkpoint != "" {
+		serv = serv.WithCheckpoint(cfg.Checkpoint)
+	}
+	serv = serv.WithHouse(cfg.HousePlayers, cfg.ReservePlayers)
+	if cfg.MetricsPort > 0 {
+		metrics, err := lib.ServeMetrics(lib.METRICS, cfg.MetricsPort)
+		if err != nil {
+			panic(err)
+		}
+		defer metrics.Close()
+	}
+	remoteConfig = remoteConfig.WithForfeitRule(proxy.ForfeitRule{
+		Grace:    time.Duration(cfg.ReconnectGrace) * time.Second,
+		MaxDrops: cfg.MaxDrops,
+	})
+
+	listener, err := config.Listen(cfg.Port, tlsConfig)
+	if err != nil {
+		panic(err)
+	}
+	defer listener.Close()
+
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	if len(cfg.Lobbies) > 0 {
+		return serv.serveLobbies(cfg, remoteConfig, config.NewRouter(listener), signals)
+	}
+	if cfg.Ladder {
+		return []result.TournamentResult{serv.serveLadder(remoteConfig.WithListener(listener), signals)}
+	}
+	return serv.serve(remoteConfig.WithListener(listener), cfg.Repeat == 1, signals)
+}
+
+// Run every lobby at once, each with its own settings on top of the given
+// RemoteConfig, registering its clients through the router, and each with
+// its own checkpoint if the server has one. Every signal goes to every lobby.
+// Returns every lobby's results once they have all finished
+func (serv server) serveLobbies(cfg ServerConfig, c config.RemoteConfig, router *config.Router, signals <-chan os.Signal) []result.TournamentResult {
+	type lobby struct {
+		serv    server
+		config  config.RemoteConfig
+		repeat  bool
+		ladder  bool
+		signals chan os.Signal
+	}
+
+	lobbies := make([]lobby, 0)
+	for _, l := range cfg.Lobbies {
+		if l.Name == "" {
+			panic(errors.New("Every lobby needs a name"))
+		}
+		for _, other := range lobbies {
+			if other.serv.lobby == l.Name {
+				panic(fmt.Errorf("Two lobbies named %s", l.Name))
+			}
+		}
+		l = l.withDefaults(cfg)
+
+		lobbyServ := serv.WithLobby(l.Name).WithGames(l.Games)
+		if serv.checkpoint != "" {
+			lobbyServ = lobbyServ.WithCheckpoint(serv.checkpoint + "." + l.Name)
+		}
+		lobbyConfig := c.WithPlayers(l.MinPlayers, l.WaitingFor).
+			WithTimeout(l.Timeout).
+			WithMaxPlayers(l.MaxPlayers).
+			WithDeadline(l.Deadline).
+			WithLobby(l.Name).
+			WithListener(router.Lobby(l.Name))
+		lobbies = append(lobbies, lobby{lobbyServ, lobbyConfig, l.Repeat == 1, bool(l.Ladder), make(chan os.Signal, 2)})
+	}
+
+	// The admin API lists the lobbies in order, the default first
+	if serv.admin != nil {
+		for _, l := range lobbies {
+			serv.admin.track(l.serv.lobby, nil)
+		}
+	}
+
+	finished := make(chan []result.TournamentResult, len(lobbies))
+	for _, l := range lobbies {
+		go func(l lobby) {
+			if l.ladder {
+				finished <- []result.TournamentResult{l.serv.serveLadder(l.config, l.signals)}
+			} else {
+				finished <- l.serv.serve(l.config, l.repeat, l.signals)
+			}
+		}(l)
+	}
+
 	results := make([]result.TournamentResult, 0)
+	for running := len(lobbies); running > 0; {
+		select {
+		case r := <-finished:
+			results = append(results, r...)
+			running--
+
+		case sig := <-signals:
+			for _, l := range lobbies {
+				select {
+				case l.signals <- sig:
+				default:
+				}
+			}
+		}
+	}
+	return results
+}
+
+// Run a ladder until a signal, or the admin API, stops it: registration
+// closes at once, and the ladder once the series being played are over. Its
+// results are handed to the sinks. Ladders aren't checkpointed
+func (serv server) serveLadder(c config.RemoteConfig, signals <-chan os.Signal) result.TournamentResult {
+	ladder := tournament.NewLadder(serv.gamesPerSeries()).WithArchive(serv.archive).WithReservedNames(c.ReservedNames())
+	current := newRound(ladder)
+	if serv.admin != nil {
+		serv.admin.track(serv.lobby, current)
+		defer serv.admin.track(serv.lobby, nil)
+	}
 
-	if cfg.Repeat == 1 {
+	players, observers := c.WithCancel(current.start).WithRegistrations(current.register).Stream()
+	finished := make(chan result.TournamentResult, 1)
+	go func() {
+		finished <- ladder.Run(players, observers)
+	}()
+
+	for {
+		select {
+		case r := <-finished:
+			current.startNow()
+			serv.record(r)
+			return r
+
+		case <-signals:
+			current.startNow()
+			ladder.Abort()
+		}
+	}
+}
+
+// Run tournaments one after another, just one unless repeating, handing each
+// completed result to the sinks. The first signal stops registration, and
+// lets the tournament under way finish; a second aborts it after the series
+// being played. A checkpointed tournament is resumed first, once every player
+// in it has registered again. Returns every result, aborted or not
+func (serv server) serve(c config.RemoteConfig, repeat bool, signals <-chan os.Signal) []result.TournamentResult {
+	results := make([]result.TournamentResult, 0)
+	for first := true; ; first = false {
+		manager := tournament.NewManager(serv.gamesPerSeries()).WithCheckpoint(serv.checkpoint).WithArchive(serv.archive)
+		roundConfig := c
+		if first {
+			if checkpoint := serv.resume(); checkpoint != nil {
+				manager.Resume(checkpoint)
+				// The house players are back without registering
+				roundConfig = roundConfig.WithMaxPlayers(len(checkpoint.Players) - len(serv.house))
+			}
+		}
+		current := newRound(manager)
+		if serv.admin != nil {
+			serv.admin.track(serv.lobby, current)
+		}
+
+		finished := make(chan result.TournamentResult, 1)
+		go func() {
+			finished <- manager.RunWithConfig(serv.tournamentConfig(roundConfig.WithCancel(current.start).WithRegistrations(current.register)))
+		}()
+
+		stopping := false
+		var r result.TournamentResult
+	waiting:
 		for {
-			manager := tournament.NewManager(3)
-			manager.RunWithConfig(remoteConfig)
+			select {
+			case r = <-finished:
+				break waiting
+
+			case <-signals:
+				if !stopping {
+					stopping = true
+					current.startNow()
+					if !manager.Started() {
+						// Don't play with whoever happened to have registered
+						manager.Abort()
+					}
+				} else {
+					manager.Abort()
+				}
+			}
+		}
+
+		if serv.admin != nil {
+			serv.admin.track(serv.lobby, nil)
+		}
+		results = append(results, r)
+		if !r.Aborted {
+			serv.record(r)
+		}
+		if stopping || !repeat {
+			return results
 		}
-	} else {
-		manager := tournament.NewManager(3)
-		result := manager.RunWithConfig(remoteConfig)
-		results = append(results, result)
 	}
+}
 
-	return results
+// The checkpoint of a tournament the server didn't finish, or nil. One that
+// can't be read is moved aside (adding ".bad") for someone to look at, and a
+// new tournament started
+func (serv server) resume() *tournament.Checkpoint {
+	if serv.checkpoint == "" {
+		return nil
+	}
+	checkpoint, err := tournament.LoadCheckpoint(serv.checkpoint)
+	if err != nil {
+		log.Printf("Failed to load checkpoint, starting a new tournament: %v", err)
+		os.Rename(serv.checkpoint, serv.checkpoint+".bad")
+		return nil
+	}
+	return checkpoint
+}
+
+// Hand a completed tournament's results to every sink
+func (serv server) record(r result.TournamentResult) {
+	for _, sink := range serv.sinks {
+		if err := sink.Record(r); err != nil {
+			log.Printf("Failed to record tournament results: %v", err)
+		}
+	}
+}
+
+// A logger writing to the given file ("-" for STDERR), or nil for no
+// file or one that can't be opened
+func openLog(path string) *log.Logger {
+	if path == "" {
+		return nil
+	} else if path == "-" {
+		return log.New(os.Stderr, "", log.LstdFlags)
+	}
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil
+	}
+	return log.New(file, "", log.LstdFlags)
 }
@@ -0,0 +1,338 @@
+package remote
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	tournament "github.com/CS4500-F18/dare-rebr/Santorini/Admin/Tournament"
+	config "github.com/CS4500-F18/dare-rebr/Santorini/Admin/Tournament/Config"
+	iplayer "github.com/CS4500-F18/dare-rebr/Santorini/Common/Player"
+	result "github.com/CS4500-F18/dare-rebr/Santorini/Common/Tournament"
+	client "github.com/CS4500-F18/dare-rebr/Santorini/Player/Client"
+	relay "github.com/CS4500-F18/dare-rebr/Santorini/Remote/Relay"
+)
+
+// Connect a player to the server, trying again while it's between tournaments
+func join(t *testing.T, l net.Listener, player iplayer.IPlayer) {
+	joinWith(t, l, relay.NewPlayerRelay(player))
+}
+
+// Connect a player's relay to the server, trying again while it's between
+// tournaments
+func joinWith(t *testing.T, l net.Listener, r relay.PlayerRelay) {
+	host, port, _ := net.SplitHostPort(l.Addr().String())
+	portNum, _ := strconv.Atoi(port)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		err := r.Connect(host, portNum, make(chan bool, 1))
+		if err == nil {
+			return
+		} else if time.Now().After(deadline) {
+			t.Fatal(err)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// A repeating server plays one tournament after another on the same
+// listener, recording each, until a signal stops it
+func TestServe_Repeat(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	listener := config.NewListener(l)
+	defer listener.Close()
+
+	recorded := make(chan result.TournamentResult, 2)
+	serv := NewServer().WithSink(SinkFunc(func(r result.TournamentResult) error {
+		recorded <- r
+		return nil
+	}))
+	c := config.NewRemoteConfig(2, 0, 60, 5000).WithMaxPlayers(2).WithListener(listener)
+
+	signals := make(chan os.Signal, 1)
+	served := make(chan []result.TournamentResult, 1)
+	go func() {
+		served <- serv.serve(c, true, signals)
+	}()
+
+	// The broken players lose at once, so the tournaments are quick
+	for _, names := range [][]string{{"fido", "rex"}, {"spot", "rover"}} {
+		join(t, l, client.ValidPlayer(names[0]))
+		join(t, l, client.BrokenPlayer(names[1]))
+		select {
+		case r := <-recorded:
+			if len(r.Games) != 1 || len(r.Kicked) != 1 || r.Aborted {
+				t.Errorf("Wrong results: %+v", r)
+			}
+		case <-time.After(10 * time.Second):
+			t.Fatal("Tournament never recorded")
+		}
+	}
+
+	// Nobody is playing, so the tournament waiting for players is abandoned
+	signals <- syscall.SIGTERM
+	select {
+	case results := <-served:
+		if len(results) != 3 || !results[2].Aborted {
+			t.Errorf("Wrong results: %+v", results)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("Server didn't stop")
+	}
+	select {
+	case r := <-recorded:
+		t.Errorf("Recorded an aborted tournament: %+v", r)
+	default:
+	}
+}
+
+// House players play alongside the remote clients, and reserves top the field
+// up once registration closes
+func TestServe_House(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	listener := config.NewListener(l)
+	defer listener.Close()
+
+	house := []config.StaticPlayer{{Kind: config.BROKEN, Name: "rex"}}
+	reserve := []config.StaticPlayer{{Kind: config.BROKEN, Name: "fido"}, {Kind: config.BROKEN, Name: "spot"}}
+	serv := NewServer().WithHouse(house, reserve)
+	c := config.NewRemoteConfig(3, 0, 1, 5000).WithListener(listener)
+	served := make(chan []result.TournamentResult, 1)
+	go func() {
+		served <- serv.serve(c, false, make(chan os.Signal))
+	}()
+
+	join(t, l, client.ValidPlayer("fido"))
+	select {
+	case results := <-served:
+		// The series between the two kicked players isn't played
+		if len(results) != 1 || len(results[0].Games) != 2 || len(results[0].Kicked) != 2 {
+			t.Fatalf("Wrong results: %+v", results)
+		}
+		// The reserve named fido gives way to the client
+		if kicked := results[0].Kicked; kicked[0] != "rex" || kicked[1] != "fidoa" {
+			t.Errorf("Wrong players kicked: %v", kicked)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("Tournament never finished")
+	}
+}
+
+// Two players over one multiplexed connection register and play as if each
+// had a connection of its own, and both are sent the results
+func TestServe_Multiplex(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	listener := config.NewListener(l)
+	defer listener.Close()
+
+	c := config.NewRemoteConfig(2, 0, 60, 5000).WithMaxPlayers(2).WithListener(listener)
+	served := make(chan []result.TournamentResult, 1)
+	go func() {
+		served <- NewServer().WithGames(1).serve(c, false, make(chan os.Signal))
+	}()
+
+	clients := make([]relay.Client, 0)
+	for _, name := range []string{"fido", "rex"} {
+		clients = append(clients, relay.NewClient(name, relay.PlayerHandlers(client.ValidPlayer(name))))
+	}
+	_, port, _ := net.SplitHostPort(l.Addr().String())
+	portNum, _ := strconv.Atoi(port)
+	multiplexed, err := relay.NewMultiplexer(clients...).Connect("127.0.0.1", portNum)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer multiplexed.Close()
+
+	finished := make(chan []result.MatchResult, 2)
+	for _, conn := range multiplexed.Connections() {
+		go func(conn *relay.Connection) {
+			results, err := conn.Serve()
+			if err != nil {
+				t.Errorf("%s stopped playing: %v", conn.Name(), err)
+			}
+			finished <- results
+		}(conn)
+	}
+	for i := 0; i < 2; i++ {
+		select {
+		case results := <-finished:
+			if len(results) != 1 {
+				t.Errorf("Wrong results: %+v", results)
+			}
+		case <-time.After(10 * time.Second):
+			t.Fatal("Players were never sent the results")
+		}
+	}
+
+	results := <-served
+	if len(results) != 1 || len(results[0].Kicked) != 0 {
+		t.Errorf("Wrong results: %+v", results)
+	}
+}
+
+// A ladder pairs players as they connect, and records its results once a
+// signal stops it
+func TestServeLadder(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	listener := config.NewListener(l)
+	defer listener.Close()
+	admin := listenAdmin(t)
+	defer admin.Close()
+
+	recorded := make(chan result.TournamentResult, 1)
+	serv := NewServer().WithAdmin(admin).WithSink(SinkFunc(func(r result.TournamentResult) error {
+		recorded <- r
+		return nil
+	}))
+	c := config.NewRemoteConfig(2, 0, 60, 5000).WithListener(listener)
+
+	signals := make(chan os.Signal, 1)
+	go serv.serveLadder(c, signals)
+
+	join(t, l, client.ValidPlayer("fido"))
+	join(t, l, client.BrokenPlayer("rex"))
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		var standings []tournament.Standing
+		if resp, err := http.Get("http://" + admin.Addr() + "/standings"); err == nil {
+			json.NewDecoder(resp.Body).Decode(&standings)
+			resp.Body.Close()
+		}
+		if len(standings) == 2 && standings[1].Losses == 1 {
+			break
+		} else if time.Now().After(deadline) {
+			t.Fatalf("Series never played: %+v", standings)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	signals <- syscall.SIGTERM
+	select {
+	case r := <-recorded:
+		if len(r.Games) != 1 || r.Games[0].Winner != "fido" || len(r.Kicked) != 1 || r.Aborted {
+			t.Errorf("Wrong results: %+v", r)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("Ladder never recorded")
+	}
+}
+
+// Each lobby runs its own tournaments with its own settings, side by side
+func TestServeLobbies(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	listener := config.NewListener(l)
+	defer listener.Close()
+	admin := listenAdmin(t)
+	defer admin.Close()
+
+	cfg := ServerConfig{MinPlayers: 2, WaitingFor: 60, MaxPlayers: 2, Lobbies: []LobbyConfig{
+		{Name: "main"},
+		{Name: "blitz", Games: 1, MaxPlayers: 3, Timeout: 1000},
+	}}
+	c := config.NewRemoteConfig(cfg.MinPlayers, 0, cfg.WaitingFor, 5000)
+	served := make(chan []result.TournamentResult, 1)
+	go func() {
+		served <- NewServer().WithAdmin(admin).serveLobbies(cfg, c, config.NewRouter(listener), make(chan os.Signal))
+	}()
+
+	joinWith(t, l, relay.NewPlayerRelay(client.ValidPlayer("fido")).WithLobby("blitz"))
+	join(t, l, client.ValidPlayer("rex"))
+	joinWith(t, l, relay.NewPlayerRelay(client.BrokenPlayer("spot")).WithLobby("blitz"))
+
+	// Main has only one of its two players, blitz two of its three
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		var lobbies []lobbyStatus
+		if resp, err := http.Get("http://" + admin.Addr() + "/lobbies"); err == nil {
+			json.NewDecoder(resp.Body).Decode(&lobbies)
+			resp.Body.Close()
+		}
+		if len(lobbies) == 2 && lobbies[0].Name == "main" && lobbies[0].Players == 1 && lobbies[1].Players == 2 {
+			break
+		} else if time.Now().After(deadline) {
+			t.Fatalf("Wrong lobbies: %+v", lobbies)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if resp, _ := http.Get("http://" + admin.Addr() + "/players?lobby=bullet"); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected 404 for a lobby that isn't there, got %v", resp.Status)
+	}
+
+	joinWith(t, l, relay.NewPlayerRelay(client.BrokenPlayer("rover")).WithLobby("blitz"))
+	join(t, l, client.BrokenPlayer("max"))
+
+	select {
+	case results := <-served:
+		if len(results) != 2 {
+			t.Fatalf("Wrong results: %+v", results)
+		}
+		for _, r := range results {
+			if len(r.Games) == 0 {
+				t.Fatalf("Wrong results: %+v", r)
+			}
+			// Blitz plays single games, but main best of 3
+			if r.Games[0].Winner == "fido" && (len(r.Games) != 2 || len(r.Games[0].GameResults) != 1) {
+				t.Errorf("Wrong results in blitz: %+v", r)
+			} else if r.Games[0].Winner == "rex" && (len(r.Games) != 1 || len(r.Kicked) != 1) {
+				t.Errorf("Wrong results in main: %+v", r)
+			}
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("Lobbies never finished")
+	}
+}
+
+func TestFileSink(t *testing.T) {
+	dir, err := ioutil.TempDir("", "results")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := dir + "/results.json"
+
+	sink, err := NewFileSink(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	games := []result.MatchResult{result.NewMatchResult("fido", "rex", false, nil)}
+	sink.Record(result.TournamentResult{Games: games})
+	sink.Record(result.TournamentResult{Kicked: []string{"rex"}})
+
+	buf, _ := ioutil.ReadFile(path)
+	lines := strings.Split(strings.TrimSpace(string(buf)), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[0], `"results":[["fido","rex"]]`) ||
+		!strings.Contains(lines[1], `"kicked":["rex"]`) {
+		t.Errorf("Wrong records: %s", buf)
+	}
+
+	if err := sink.(io.Closer).Close(); err != nil {
+		t.Errorf("Failed to close: %v", err)
+	} else if sink.Record(result.TournamentResult{}) == nil {
+		t.Error("Recorded after closing")
+	}
+}
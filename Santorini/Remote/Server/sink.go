@@ -0,0 +1,92 @@
+package remote
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	result "github.com/CS4500-F18/dare-rebr/Santorini/Common/Tournament"
+)
+
+// Where the result of each completed tournament goes
+type ResultSink interface {
+	Record(result.TournamentResult) error
+}
+
+// A function that records results, e.g. to hand them on elsewhere
+type SinkFunc func(result.TournamentResult) error
+
+func (f SinkFunc) Record(r result.TournamentResult) error {
+	return f(r)
+}
+
+// A completed tournament, as the file and log sinks write it
+type record struct {
+	Finished time.Time               `json:"finished"`
+	Results  result.TournamentResult `json:"results"`
+	Kicked   []string                `json:"kicked"`
+}
+
+func newRecord(r result.TournamentResult) record {
+	kicked := r.Kicked
+	if kicked == nil {
+		kicked = make([]string, 0)
+	}
+	return record{Finished: time.Now(), Results: r, Kicked: kicked}
+}
+
+// Appends each result to a file, one JSON object per line
+type fileSink struct {
+	lock sync.Mutex
+	file *os.File
+}
+
+// A sink appending each result to the file at the given path. It is an
+// io.Closer, to close the file once nothing more will be recorded
+func NewFileSink(path string) (ResultSink, error) {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &fileSink{file: file}, nil
+}
+
+func (s *fileSink) Record(r result.TournamentResult) error {
+	buf, err := json.Marshal(newRecord(r))
+	if err != nil {
+		return err
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	_, err = s.file.Write(append(buf, '\n'))
+	return err
+}
+
+// Close the file. Nothing more can be recorded
+func (s *fileSink) Close() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.file.Close()
+}
+
+// Logs each result
+type logSink struct {
+	logger *log.Logger
+}
+
+// A sink logging each result to the given logger
+func NewLogSink(logger *log.Logger) ResultSink {
+	return logSink{logger: logger}
+}
+
+func (s logSink) Record(r result.TournamentResult) error {
+	buf, err := json.Marshal(newRecord(r))
+	if err != nil {
+		return err
+	}
+	s.logger.Printf("tournament finished: %s", buf)
+	return nil
+}